@@ -2,90 +2,136 @@ package jobattributes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/khaledhikmat/yt-extractor/service/audio"
-	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
-	"github.com/khaledhikmat/yt-extractor/service/config"
+	"github.com/khaledhikmat/yt-extractor/job"
 	"github.com/khaledhikmat/yt-extractor/service/data"
 	"github.com/khaledhikmat/yt-extractor/service/lgr"
-	"github.com/khaledhikmat/yt-extractor/service/storage"
-	"github.com/khaledhikmat/yt-extractor/service/transcription"
 	"github.com/khaledhikmat/yt-extractor/service/youtube"
 	"github.com/khaledhikmat/yt-extractor/utils"
 )
 
+// videosBatchSize is the number of videos written to the database in one statement (i.e. a Youtube API page)
+const videosBatchSize = 50
+
+// stage refreshes the channel videos attributes (and statistics) from Youtube
+type stage struct {
+	svcs job.Services
+	// insertedIDs are the new videos that the automation webhook is notified about
+	insertedIDs []int64
+}
+
 func Processor(ctx context.Context,
-	channelID string,
+	_ string,
 	jobID int64,
 	pageSize int,
 	errorStream chan error,
-	cfgsvc config.IService,
-	datasvc data.IService,
-	ytsvc youtube.IService,
-	_ audio.IService,
-	_ storage.IService,
-	_ cloudconvert.IService,
-	_ transcription.IService) {
-
-	// Update job state to running
-	job, err := datasvc.RetrieveJobByID(jobID)
-	if err != nil {
-		errorStream <- err
-		return
+	svcs job.Services) {
+	job.Run(ctx, jobID, pageSize, errorStream, svcs.Data, &stage{svcs: svcs})
+}
+
+// Select retrieves the videos from Youtube and inserts (or updates) them into the database in batches
+func (s *stage) Select(ctx context.Context, j *data.Job, pageSize int) ([]data.Video, error) {
+	ytvideos, err := s.svcs.Youtube.RetrieveVideos(j.ChannelID, pageSize)
+	videos := toVideos(j.ChannelID, ytvideos)
+
+	upserted := []data.Video{}
+	for batch := range slices.Chunk(videos, videosBatchSize) {
+		classifyErr := s.classifyShorts(ctx, j.ChannelID, batch)
+		if classifyErr != nil {
+			err = errors.Join(err, classifyErr)
+		}
+
+		results, upsertErr := s.svcs.Data.NewVideos(ctx, batch)
+		if upsertErr != nil {
+			err = errors.Join(err, upsertErr)
+			continue
+		}
+
+		for _, result := range results {
+			i := slices.IndexFunc(batch, func(video data.Video) bool {
+				return video.VideoID == result.VideoID
+			})
+			batch[i].ID = result.ID
+			upserted = append(upserted, batch[i])
+
+			// If the video was inserted, add the ID to the list so we can notify the automation webhook
+			if result.Inserted {
+				s.insertedIDs = append(s.insertedIDs, result.ID)
+			}
+		}
 	}
-	job.State = data.JobStateRunning
-	err = datasvc.UpdateJob(&job)
+
+	return upserted, err
+}
+
+// classifyShorts classifies the new videos and the videos that were never classified as Shorts or not.
+// A video does not stop (or start) being a Short so the stored videos are not probed again on every refresh.
+// The videos that fail classification are left unclassified so that the next refresh retries them.
+func (s *stage) classifyShorts(ctx context.Context, channelID string, videos []data.Video) error {
+	videoIDs := []string{}
+	for _, video := range videos {
+		videoIDs = append(videoIDs, video.VideoID)
+	}
+
+	stored, err := s.svcs.Data.RetrieveVideosByIDs(ctx, channelID, videoIDs)
 	if err != nil {
-		errorStream <- err
-		return
+		return err
 	}
 
-	errors := 0
-	ytvideos := []youtube.Video{}
-	finalState := data.JobStateCompleted
-
-	defer func() {
-		// Update job state to completed
-		now := time.Now()
-		job.State = finalState
-		job.Videos = int64(len(ytvideos))
-		job.Errors = int64(errors)
-		job.CompletedAt = &now
-		err = datasvc.UpdateJob(&job)
-		if err != nil {
-			errorStream <- err
-			return
+	classified := map[string]data.Video{}
+	for _, video := range stored {
+		if video.ShortClassifiedAt != nil {
+			classified[video.VideoID] = video
 		}
-	}()
-
-	// Retrieve videos from Youtube
-	ytvideos, err = ytsvc.RetrieveVideos(channelID, pageSize)
-	if err != nil {
-		errorStream <- err
-		errors++
 	}
 
-	// Insert/update videos into the database
-	insertedIDs := []int64{}
-	for _, ytvideo := range ytvideos {
+	for i, video := range videos {
+		if storedVideo, ok := classified[video.VideoID]; ok {
+			videos[i].Short = storedVideo.Short
+			videos[i].ShortClassifiedAt = storedVideo.ShortClassifiedAt
+			continue
+		}
 
-		// If the context is cancelled, exit the loop
-		// But execute the defer block first
-		select {
-		case <-ctx.Done():
-			finalState = data.JobStateCancelled
-			return
-		default:
+		if video.Availability.IsGone() {
+			continue
 		}
 
-		video := data.Video{
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		short, err := s.svcs.Youtube.IsShort(ctx, video.VideoID, video.Duration)
+		if err != nil {
+			lgr.Logger.Debug("classifyShorts",
+				slog.String("event", "shortClassificationFailed"),
+				slog.String("videoId", video.VideoID),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+
+		now := time.Now().UTC()
+		videos[i].Short = short
+		videos[i].ShortClassifiedAt = &now
+	}
+
+	return nil
+}
+
+// toVideos converts the Youtube videos to database videos
+func toVideos(channelID string, ytvideos []youtube.Video) []data.Video {
+	videos := []data.Video{}
+	for _, ytvideo := range ytvideos {
+		videos = append(videos, data.Video{
 			ChannelID: channelID,
 			VideoID:   ytvideo.ID,
 			VideoURL:  ytvideo.URL,
@@ -106,35 +152,46 @@ func Processor(ctx context.Context,
 				likes, _ := strconv.ParseInt(ytvideo.Likes, 10, 64)
 				return likes
 			}(),
-			Duration: utils.ExtractDurationInSecs(ytvideo.Duration),
-			Short:    ytvideo.Short,
-		}
+			Duration:             utils.ExtractDurationInSecs(ytvideo.Duration),
+			Description:          ytvideo.Description,
+			Tags:                 ytvideo.Tags,
+			Thumbnails:           ytvideo.Thumbnails,
+			CategoryID:           ytvideo.CategoryID,
+			DefaultAudioLanguage: ytvideo.DefaultAudioLanguage,
+			Caption:              ytvideo.Caption,
+			LiveBroadcastContent: ytvideo.LiveBroadcastContent,
+			PrivacyStatus:        ytvideo.PrivacyStatus,
+			Availability:         ytvideo.Availability,
+		})
+	}
 
-		// Insert or update the video into the database
-		insert, id, err := datasvc.NewVideo(video)
-		if err != nil {
-			errorStream <- err
-			errors++
-			continue
-		}
+	return videos
+}
 
-		// If the video was inserted, add the ID to the list so we can notify the automation webhook
-		if insert {
-			insertedIDs = append(insertedIDs, id)
-		}
+// Process has nothing to do because the videos are already inserted (or updated)
+func (s *stage) Process(_ context.Context, _ *data.Job, _ *data.Video) error {
+	return nil
+}
+
+// Persist records the video statistics
+func (s *stage) Persist(ctx context.Context, _ *data.Job, video *data.Video, _ error) error {
+	// Capture the video statistics on every refresh (even if they did not change)
+	// so that the performance of the video can be tracked over time.
+	// Gone videos do not have statistics.
+	if video.Availability.IsGone() {
+		return nil
 	}
 
-	// Call automation webhook to convey that we have new videos
-	if len(insertedIDs) > 0 {
-		err = postToAutomationWebhook(insertedIDs, cfgsvc.GetAutomationWebhookURL())
-		if err != nil {
-			errorStream <- err
-		}
+	return s.svcs.Data.NewVideoStatsSnapshot(ctx, *video)
+}
+
+// Finish calls the automation webhook to convey that we have new videos
+func (s *stage) Finish(_ context.Context, _ *data.Job) error {
+	if len(s.insertedIDs) == 0 {
+		return nil
 	}
 
-	lgr.Logger.Debug("jobattributes.Processor",
-		slog.String("event", "done"),
-	)
+	return postToAutomationWebhook(s.insertedIDs, s.svcs.Config.GetAutomationWebhookURL())
 }
 
 func postToAutomationWebhook(insertIDs []int64, url string) error {
@@ -2,133 +2,110 @@ package jobaudio
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log/slog"
 	"time"
 
-	"github.com/khaledhikmat/yt-extractor/service/audio"
-	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
-	"github.com/khaledhikmat/yt-extractor/service/config"
+	"github.com/khaledhikmat/yt-extractor/job"
+	"github.com/khaledhikmat/yt-extractor/service"
 	"github.com/khaledhikmat/yt-extractor/service/data"
 	"github.com/khaledhikmat/yt-extractor/service/lgr"
-	"github.com/khaledhikmat/yt-extractor/service/storage"
-	"github.com/khaledhikmat/yt-extractor/service/transcription"
-	"github.com/khaledhikmat/yt-extractor/service/youtube"
 )
 
+// stage converts the extracted videos (stored in S3) to audio (stored in S3)
+type stage struct {
+	svcs job.Services
+}
+
 func Processor(ctx context.Context,
-	channelID string,
+	_ string,
 	jobID int64,
 	pageSize int,
 	errorStream chan error,
-	_ config.IService,
-	datasvc data.IService,
-	_ youtube.IService,
-	_ audio.IService,
-	_ storage.IService,
-	cloudconvertsvc cloudconvert.IService,
-	_ transcription.IService) {
-	// Update job state to running
-	job, err := datasvc.RetrieveJobByID(jobID)
-	if err != nil {
-		errorStream <- err
-		return
-	}
-	job.State = data.JobStateRunning
-	err = datasvc.UpdateJob(&job)
-	if err != nil {
-		errorStream <- err
-		return
-	}
+	svcs job.Services) {
+	job.Run(ctx, jobID, pageSize, errorStream, svcs.Data, New(svcs))
+}
 
-	errors := 0
-	videos := []data.Video{}
-	finalState := data.JobStateCompleted
-
-	defer func() {
-		// Update job state to completed
-		now := time.Now()
-		job.State = finalState
-		job.Videos = int64(len(videos))
-		job.Errors = int64(errors)
-		job.CompletedAt = &now
-		err = datasvc.UpdateJob(&job)
-		if err != nil {
-			errorStream <- err
-			return
-		}
-	}()
+// New creates the audio stage
+func New(svcs job.Services) job.Stage {
+	return &stage{svcs: svcs}
+}
 
-	// Retrieve unaudioed videos from Youtube
-	if job.Type == data.JobTypeAudioError {
-		videos, err = datasvc.RetrieveAudioErroredVideos(channelID, pageSize)
-	} else {
-		videos, err = datasvc.RetrieveUnaudioedVideos(channelID, pageSize)
-	}
-	if err != nil {
-		errorStream <- err
-		errors++
+func (s *stage) Select(ctx context.Context, j *data.Job, pageSize int) ([]data.Video, error) {
+	if j.Type == data.JobTypeAudioError {
+		return s.svcs.Data.RetrieveAudioErroredVideos(ctx, j.ChannelID, pageSize)
 	}
 
-	lgr.Logger.Debug("jobaudio.Processor",
-		slog.String("event", "receivedVideos"),
-		slog.Int("videos", len(videos)),
-	)
-	fmt.Printf("jobaudio.Processor - %d videos\n", len(videos))
-
-	// Update each videos with transcribed data
-	// WARNING: Any error causes the audio URL to be set to invalid
-	// This means that audio will be re-attempted
-	for _, video := range videos {
-		// If the context is cancelled, exit the loop
-		// But execute the defer block first
-		select {
-		case <-ctx.Done():
-			finalState = data.JobStateCancelled
-			return
-		default:
-		}
+	return s.svcs.Data.RetrieveUnaudioedVideos(ctx, j.ChannelID, pageSize)
+}
 
-		var audioURL string
+// Accepts the extracted videos that are yet to be audioed (or transcribed from their captions)
+func (s *stage) Accepts(_ *data.Job, video *data.Video) bool {
+	return video.Availability == service.AvailabilityPublic &&
+		video.ExtractionStatus == data.StageStatusSucceeded &&
+		video.AudioStatus == data.StageStatusPending &&
+		video.TranscriptionStatus == data.StageStatusPending &&
+		video.IsPublishedSince(s.svcs.Config.GetVideoTranscriptionCutoffDate())
+}
 
-		lgr.Logger.Debug("jobaudio.Processor",
-			slog.String("event", "aboutToConvert"),
-			slog.String("videoId", video.VideoID),
-		)
-		fmt.Printf("jobaudio.Processor - video %s\n", video.VideoID)
+// WARNING: Any error causes the audio status to be set to failed
+// This means that audio will be re-attempted
+func (s *stage) Process(ctx context.Context, _ *data.Job, video *data.Video) error {
+	lgr.Logger.Debug("jobaudio.Process",
+		slog.String("event", "aboutToConvert"),
+		slog.String("videoId", video.VideoID),
+	)
 
-		// Use CloudConvert service to convert MP4 (stored in S3) to MP3 (stored in S3)
-		audioURL, err = cloudconvertsvc.ConvertVideoToAudio(video.ChannelID, video.VideoID)
-		if err != nil {
-			errorStream <- err
-			errors++
-			// Fall through to update the database with the error
-		}
+	err := s.svcs.Data.UpdateVideoStageStatus(ctx, video, data.StageAudio, data.StageStatusInProgress)
+	if err != nil {
+		return err
+	}
+
+	// Use CloudConvert service to convert MP4 (stored in S3) to MP3 (stored in S3)
+	audioURL, accepted, err := s.svcs.CloudConvert.ConvertVideoToAudio(video.ChannelID, video.VideoID)
+	if err != nil {
+		return err
+	}
 
-		lgr.Logger.Debug("jobaudio.Processor",
-			slog.String("event", "updatingDb"),
-			slog.String("videoId", video.VideoID),
-			slog.String("audioUrl", audioURL),
-		)
-		fmt.Printf("jobaudio.Processor - updating db video %s url %s\n", video.VideoID, audioURL)
+	video.AudioURL = nil
+	if audioURL != "" {
+		video.AudioURL = &audioURL
+	}
 
-		// Update the video with audio URL
-		updateDb(datasvc, errorStream, &video, &job, &audioURL)
+	video.AudioStatus = data.StageStatusSucceeded
+	if accepted {
+		// The webhook completes the conversion
+		video.AudioStatus = data.StageStatusWaitingExternal
 	}
 
-	lgr.Logger.Debug("jobaudio.Processor",
-		slog.String("event", "done"),
-	)
-	fmt.Printf("jobaudio.Processor - done\n")
+	return nil
 }
 
-func updateDb(datasvc data.IService, errorStream chan error, video *data.Video, job *data.Job, url *string) {
-	// Update the video with audio URL
+func (s *stage) Persist(ctx context.Context, j *data.Job, video *data.Video, audioErr error) error {
+	// Restore the status of the videos that were not audioed (i.e. cancelled) without recording an attempt
+	if errors.Is(audioErr, job.ErrCancelled) {
+		if video.AudioStatus != data.StageStatusInProgress {
+			return nil
+		}
+		return s.svcs.Data.UpdateVideoStageStatus(ctx, video, data.StageAudio, video.RestoredStatus())
+	}
+
+	// Update the video with audio URL (if any) and status
 	now := time.Now()
-	video.AudioURL = url
 	video.AudioedAt = &now
-	err := datasvc.UpdateVideo(video, job.Type)
-	if err != nil {
-		errorStream <- err
+	video.AudioError = nil
+	if audioErr != nil {
+		message := audioErr.Error()
+		video.AudioURL = nil
+		video.AudioStatus = data.StageStatusFailed
+		video.AudioError = &message
 	}
+
+	lgr.Logger.Debug("jobaudio.Persist",
+		slog.String("event", "updatingDb"),
+		slog.String("videoId", video.VideoID),
+		slog.String("status", string(video.AudioStatus)),
+	)
+
+	return s.svcs.Data.UpdateVideo(ctx, video, j.Type)
 }
@@ -2,34 +2,65 @@ package jobautomation
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"log/slog"
-	"time"
 
 	"github.com/khaledhikmat/yt-extractor/job"
-	"github.com/khaledhikmat/yt-extractor/service/audio"
-	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
-	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/data"
-	"github.com/khaledhikmat/yt-extractor/service/lgr"
-	"github.com/khaledhikmat/yt-extractor/service/storage"
-	"github.com/khaledhikmat/yt-extractor/service/transcription"
-	"github.com/khaledhikmat/yt-extractor/service/youtube"
 
 	jobaudio "github.com/khaledhikmat/yt-extractor/job/audio"
+	jobembedding "github.com/khaledhikmat/yt-extractor/job/embedding"
 	jobextraction "github.com/khaledhikmat/yt-extractor/job/extraction"
 	jobtranscription "github.com/khaledhikmat/yt-extractor/job/transcription"
 )
 
-// This job processor map is for internal use and contains only the job processors that might be enqueued
-// The server api has one similar to this
-var jobProcs = map[data.JobType]job.Processor{
-	data.JobTypeExtraction:         jobextraction.Processor,
-	data.JobTypeExtractionError:    jobextraction.Processor,
-	data.JobTypeAudio:              jobaudio.Processor,
-	data.JobTypeAudioError:         jobaudio.Processor,
-	data.JobTypeTranscription:      jobtranscription.Processor,
-	data.JobTypeTranscriptionError: jobtranscription.Processor,
+// defaultPipeline is the pipeline of the channels without a pipeline definition
+const defaultPipeline = "default"
+
+// StageDefinition declares a pipeline stage
+type StageDefinition struct {
+	Stage   string   `json:"stage"`
+	After   []string `json:"after"`
+	Workers int      `json:"workers"`
+}
+
+// stageTemplate describes how to build a pipeline stage
+type stageTemplate struct {
+	types []data.JobType
+	new   func(svcs job.Services, jobID int64) job.Stage
+}
+
+// These are the stages that a pipeline might be made of
+// The server api has a similar job processor map for the individual jobs
+var stageTemplates = map[string]stageTemplate{
+	"extraction": {
+		types: []data.JobType{data.JobTypeExtraction, data.JobTypeExtractionError},
+		new:   jobextraction.New,
+	},
+	"captions": {
+		types: []data.JobType{data.JobTypeCaptions},
+		new: func(svcs job.Services, _ int64) job.Stage {
+			return jobtranscription.New(svcs)
+		},
+	},
+	"audio": {
+		types: []data.JobType{data.JobTypeAudio, data.JobTypeAudioError},
+		new: func(svcs job.Services, _ int64) job.Stage {
+			return jobaudio.New(svcs)
+		},
+	},
+	"transcription": {
+		types: []data.JobType{data.JobTypeTranscription, data.JobTypeTranscriptionError},
+		new: func(svcs job.Services, _ int64) job.Stage {
+			return jobtranscription.New(svcs)
+		},
+	},
+	"embedding": {
+		types: []data.JobType{data.JobTypeEmbedding},
+		new: func(svcs job.Services, _ int64) job.Stage {
+			return jobembedding.New(svcs)
+		},
+	},
 }
 
 func Processor(ctx context.Context,
@@ -37,108 +68,83 @@ func Processor(ctx context.Context,
 	jobID int64,
 	pageSize int,
 	errorStream chan error,
-	cfgsvc config.IService,
-	datasvc data.IService,
-	ytsvc youtube.IService,
-	audiosvc audio.IService,
-	storagesvc storage.IService,
-	cloudconvertsvc cloudconvert.IService,
-	transcriptionsvc transcription.IService) {
-	// Update job state to running
-	job, err := datasvc.RetrieveJobByID(jobID)
+	svcs job.Services) {
+	strategy := svcs.Config.GetTranscriptionStrategy()
+	definitions, err := pipelineDefinitions(svcs.Config.GetAutomationPipelines(), strategy, channelID)
 	if err != nil {
 		errorStream <- err
-		return
 	}
-	job.State = data.JobStateRunning
-	err = datasvc.UpdateJob(&job)
+
+	nodes, err := buildPipeline(definitions, svcs, jobID)
 	if err != nil {
+		// An invalid pipeline falls back to the default pipeline so the automation still runs
 		errorStream <- err
-		return
+		nodes, _ = buildPipeline(defaultDefinitions(strategy), svcs, jobID)
 	}
 
-	errors := 0
-	videos := []data.Video{}
-	finalState := data.JobStateCompleted
-
-	defer func() {
-		// Update job state to completed
-		now := time.Now()
-		job.State = finalState
-		job.Videos = int64(len(videos))
-		job.Errors = int64(errors)
-		job.CompletedAt = &now
-		err = datasvc.UpdateJob(&job)
+	job.RunPipeline(ctx, jobID, pageSize, errorStream, svcs.Data, nodes)
+}
+
+// pipelineDefinitions returns the channel pipeline definition (or the default one)
+func pipelineDefinitions(pipelines, strategy, channelID string) ([]StageDefinition, error) {
+	if pipelines != "" {
+		definitions := map[string][]StageDefinition{}
+		err := json.Unmarshal([]byte(pipelines), &definitions)
 		if err != nil {
-			errorStream <- err
-			return
+			return defaultDefinitions(strategy), fmt.Errorf("automation pipelines are invalid: %w", err)
 		}
-	}()
-
-	// Construct a series of jobs to process
-	// The re-attempts (or error processors) are included before the actual processors
-	// This is to ensure that the re-attempts do not re-do the ones that just failed
-	jobs := []data.Job{
-		{
-			ChannelID: channelID,
-			Type:      data.JobTypeExtractionError,
-			State:     data.JobStateQueued,
-		},
-		{
-			ChannelID: channelID,
-			Type:      data.JobTypeExtraction,
-			State:     data.JobStateQueued,
-		},
-		{
-			ChannelID: channelID,
-			Type:      data.JobTypeAudioError,
-			State:     data.JobStateQueued,
-		},
-		{
-			ChannelID: channelID,
-			Type:      data.JobTypeAudio,
-			State:     data.JobStateQueued,
-		},
-		{
-			ChannelID: channelID,
-			Type:      data.JobTypeTranscriptionError,
-			State:     data.JobStateQueued,
-		},
-		{
-			ChannelID: channelID,
-			Type:      data.JobTypeTranscription,
-			State:     data.JobStateQueued,
-		},
-	}
 
-	// Run each job processor synchronously
-	for _, job := range jobs {
-		select {
-		case <-ctx.Done():
-			finalState = data.JobStateCancelled
-			return
-		default:
+		if channelDefinitions, ok := definitions[channelID]; ok {
+			return channelDefinitions, nil
 		}
 
-		proc, ok := jobProcs[job.Type]
+		if defaultDefinitions, ok := definitions[defaultPipeline]; ok {
+			return defaultDefinitions, nil
+		}
+	}
+
+	return defaultDefinitions(strategy), nil
+}
+
+// defaultDefinitions chains captions, extraction, audio and transcription.
+// Captions are only attempted (before the extraction) if the strategy calls for it.
+func defaultDefinitions(strategy string) []StageDefinition {
+	definitions := []StageDefinition{}
+	if strategy == "captions" {
+		definitions = append(definitions, StageDefinition{Stage: "captions"})
+		definitions = append(definitions, StageDefinition{Stage: "extraction", After: []string{"captions"}})
+	} else {
+		definitions = append(definitions, StageDefinition{Stage: "extraction"})
+	}
+
+	definitions = append(definitions, StageDefinition{Stage: "audio", After: []string{"extraction"}})
+	return append(definitions, StageDefinition{Stage: "transcription", After: []string{"audio"}})
+}
+
+// buildPipeline builds the pipeline nodes from their definitions
+func buildPipeline(definitions []StageDefinition, svcs job.Services, jobID int64) ([]job.Node, error) {
+	nodes := []job.Node{}
+	for _, definition := range definitions {
+		template, ok := stageTemplates[definition.Stage]
 		if !ok {
-			errorStream <- fmt.Errorf("job type %s does not have a processor", job.Type)
-			errors++
-			return
+			return nil, fmt.Errorf("pipeline stage %s does not exist", definition.Stage)
 		}
 
-		job.StartedAt = time.Now()
-		jobID, err := datasvc.NewJob(job)
-		if err != nil {
-			errorStream <- fmt.Errorf("new job produced %s", err.Error())
-			errors++
-			return
+		// Stages that are concurrent on their own keep their concurrency unless it is declared
+		stage := template.new(svcs, jobID)
+		workers := definition.Workers
+		if concurrent, ok := stage.(job.Concurrent); ok && workers == 0 {
+			workers = concurrent.Workers()
 		}
 
-		proc(ctx, job.ChannelID, jobID, pageSize, errorStream, cfgsvc, datasvc, ytsvc, audiosvc, storagesvc, cloudconvertsvc, transcriptionsvc)
+		nodes = append(nodes, job.Node{
+			Name:    definition.Stage,
+			Stage:   stage,
+			Types:   template.types,
+			After:   definition.After,
+			Workers: workers,
+		})
 	}
 
-	lgr.Logger.Debug("jobtranscription.Processor",
-		slog.String("event", "done"),
-	)
+	return job.SortPipeline(nodes)
 }
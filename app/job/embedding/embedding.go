@@ -0,0 +1,104 @@
+package jobembedding
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/khaledhikmat/yt-extractor/job"
+	"github.com/khaledhikmat/yt-extractor/service/data"
+	"github.com/khaledhikmat/yt-extractor/service/lgr"
+)
+
+// textsBatchSize is the number of transcript segments embedded in one embedding request
+const textsBatchSize = 100
+
+// stage embeds the transcript segments (i.e. the chunks of the indexed transcripts) of the videos
+// so they can be searched semantically
+type stage struct {
+	svcs job.Services
+}
+
+func Processor(ctx context.Context,
+	_ string,
+	jobID int64,
+	pageSize int,
+	errorStream chan error,
+	svcs job.Services) {
+	job.Run(ctx, jobID, pageSize, errorStream, svcs.Data, New(svcs))
+}
+
+func New(svcs job.Services) job.Stage {
+	return &stage{svcs: svcs}
+}
+
+func (s *stage) Select(ctx context.Context, j *data.Job, pageSize int) ([]data.Video, error) {
+	return s.svcs.Data.RetrieveUnembeddedVideos(ctx, j.ChannelID, s.svcs.Embedding.Model(), pageSize)
+}
+
+// Accepts the transcribed videos. Their transcripts are indexed as they are transcribed.
+func (s *stage) Accepts(_ *data.Job, video *data.Video) bool {
+	return video.TranscriptionStatus == data.StageStatusSucceeded
+}
+
+func (s *stage) Process(ctx context.Context, _ *data.Job, video *data.Video) error {
+	segments, err := s.svcs.Data.RetrieveTranscriptSegments(ctx, video.ChannelID, video.VideoID)
+	if err != nil {
+		return err
+	}
+
+	if len(segments) == 0 {
+		return job.ErrSkipped
+	}
+
+	model := s.svcs.Embedding.Model()
+	for batch := range slices.Chunk(segments, textsBatchSize) {
+		texts := []string{}
+		for _, segment := range batch {
+			texts = append(texts, segment.Text)
+		}
+
+		vectors, err := s.svcs.Embedding.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embedding video %s produced %s", video.VideoID, err.Error())
+		}
+
+		embeddings := []data.TranscriptEmbedding{}
+		for i, segment := range batch {
+			embeddings = append(embeddings, data.TranscriptEmbedding{
+				SegmentID: segment.ID,
+				Model:     model,
+				Vector:    vectors[i],
+			})
+		}
+
+		err = s.svcs.Data.NewTranscriptEmbeddings(ctx, embeddings)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Persist records the embedding outcome. The failed videos are reattempted with backoff
+// until they are dead-lettered so they do not block the other videos.
+func (s *stage) Persist(ctx context.Context, _ *data.Job, video *data.Video, embeddingErr error) error {
+	video.EmbeddingStatus = data.StageStatusSucceeded
+	video.EmbeddingError = nil
+	if embeddingErr != nil {
+		message := embeddingErr.Error()
+		video.EmbeddingStatus = data.StageStatusFailed
+		video.EmbeddingError = &message
+	}
+
+	lgr.Logger.Debug("jobembedding.Persist",
+		slog.String("event", "updatingDb"),
+		slog.String("videoId", video.VideoID),
+		slog.String("status", string(video.EmbeddingStatus)),
+	)
+
+	// The pipeline may run the stage within another job type
+	return s.svcs.Data.UpdateVideo(ctx, video, data.JobTypeEmbedding)
+}
@@ -2,151 +2,153 @@ package jobextraction
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log/slog"
+	"slices"
 	"time"
 
+	"github.com/khaledhikmat/yt-extractor/job"
 	"github.com/khaledhikmat/yt-extractor/service"
-	"github.com/khaledhikmat/yt-extractor/service/audio"
-	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
-	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/data"
-	"github.com/khaledhikmat/yt-extractor/service/lgr"
-	"github.com/khaledhikmat/yt-extractor/service/storage"
-	"github.com/khaledhikmat/yt-extractor/service/transcription"
 	"github.com/khaledhikmat/yt-extractor/service/youtube"
 )
 
+// stage extracts the videos from Youtube and stores them to an external storage
+type stage struct {
+	svcs  job.Services
+	jobID int64
+}
+
 func Processor(ctx context.Context,
-	channelID string,
+	_ string,
 	jobID int64,
 	pageSize int,
 	errorStream chan error,
-	_ config.IService,
-	datasvc data.IService,
-	ytsvc youtube.IService,
-	_ audio.IService,
-	storagesvc storage.IService,
-	_ cloudconvert.IService,
-	_ transcription.IService) {
-
-	// Update job state to running
-	job, err := datasvc.RetrieveJobByID(jobID)
-	if err != nil {
-		errorStream <- err
-		return
+	svcs job.Services) {
+	job.Run(ctx, jobID, pageSize, errorStream, svcs.Data, New(svcs, jobID))
+}
+
+// New creates the extraction stage. The job ID keys the extraction progress.
+func New(svcs job.Services, jobID int64) job.Stage {
+	return &stage{
+		svcs:  svcs,
+		jobID: jobID,
 	}
-	job.State = data.JobStateRunning
-	err = datasvc.UpdateJob(&job)
+}
+
+func (s *stage) Select(ctx context.Context, j *data.Job, pageSize int) ([]data.Video, error) {
+	if j.Type == data.JobTypeExtractionError {
+		return s.svcs.Data.RetrieveExtractErroredVideos(ctx, j.ChannelID, pageSize)
+	}
+
+	return s.svcs.Data.RetrieveUnextractedVideos(ctx, j.ChannelID, pageSize)
+}
+
+// Accepts the videos that are yet to be extracted unless they are already transcribed from their captions
+func (s *stage) Accepts(_ *data.Job, video *data.Video) bool {
+	return video.Availability == service.AvailabilityPublic &&
+		video.ExtractionStatus == data.StageStatusPending &&
+		video.TranscriptionStatus != data.StageStatusSucceeded
+}
+
+// Workers extracts several videos at once
+func (s *stage) Workers() int {
+	return s.svcs.Config.GetExtractionWorkers()
+}
+
+// WARNING: Any error causes the extraction status to be set to failed
+// This means that extraction will re-attempted
+func (s *stage) Process(ctx context.Context, _ *data.Job, video *data.Video) error {
+	err := s.svcs.Data.UpdateVideoStageStatus(ctx, video, data.StageExtraction, data.StageStatusInProgress)
 	if err != nil {
-		errorStream <- err
-		return
+		return err
 	}
 
-	errors := 0
-	videos := []data.Video{}
-	finalState := data.JobStateCompleted
-
-	defer func() {
-		// Update job state to completed
-		now := time.Now()
-		job.State = finalState
-		job.Videos = int64(len(videos))
-		job.Errors = int64(errors)
-		job.CompletedAt = &now
-		err = datasvc.UpdateJob(&job)
+	mode, extension := s.mode(video.ChannelID)
+	result := s.svcs.Youtube.ExtractVideo(ctx, s.jobID, video.VideoURL, mode)
+
+	// Record that the video is no longer available so it is excluded from further processing
+	if !result.Availability.IsAvailable() {
+		video.Availability = result.Availability
+		err = s.svcs.Data.UpdateVideoAvailability(ctx, video)
 		if err != nil {
-			errorStream <- err
-			return
+			return err
 		}
-	}()
-
-	// Retrieve unextracted videos from Youtube
-	if job.Type == data.JobTypeExtractionError {
-		videos, err = datasvc.RetrieveExtractErroredVideos(channelID, pageSize)
-	} else {
-		videos, err = datasvc.RetrieveUnextractedVideos(channelID, pageSize)
 	}
-	if err != nil {
-		errorStream <- err
-		errors++
+
+	// Record the formats that were selected for download
+	if result.FormatIDs != "" {
+		video.FormatIDs = &result.FormatIDs
 	}
 
-	// Accumulate all video URLs
-	videoURLs := []string{}
-	for _, video := range videos {
-		videoURLs = append(videoURLs, video.VideoURL)
+	// Record why the extraction failed so it is re-attempted according to its retry policy
+	video.ExtractionErrorClass = result.ErrorClass
+
+	if result.Err != nil {
+		return fmt.Errorf("error extracting video %s: %w", video.VideoURL, result.Err)
 	}
 
-	// Extract videos
-	results, err := ytsvc.ExtractVideos(ctx, errorStream, videoURLs)
+	// Store the local reference video to an external storage
+	extractionURL, err := s.svcs.Storage.NewFile(ctx, video.ChannelID, result.LocalReference, fmt.Sprintf("%s.%s", video.VideoID, extension))
 	if err != nil {
-		if err.Error() == "context canceled" {
-			finalState = data.JobStateCancelled
-			return
-		}
-		errorStream <- err
-		errors++
+		// Storage uploads fail on transient errors so they are backed off
+		video.ExtractionErrorClass = service.ErrorClassNetwork
+		return err
 	}
 
-	// Update videos with extracted data
-	// WARNING: Any error causes the extraction URL to be set to invalid
-	// This means that extraction will re-attempted
-	for _, video := range videos {
-		// If the context is cancelled, exit the loop
-		// But execute the defer block first
-		select {
-		case <-ctx.Done():
-			finalState = data.JobStateCancelled
-			return
-		default:
-		}
-
-		var extractionURL string
+	video.ExtractionURL = &extractionURL
+	return nil
+}
 
-		localReference, ok := results[video.VideoURL]
-		if !ok {
-			errorStream <- fmt.Errorf("video %s not found in extraction results", video.VideoURL)
-			errors++
-			extractionURL = service.InvalidURL
-			updateDb(datasvc, errorStream, &video, &job, &extractionURL)
-			continue
+func (s *stage) Persist(ctx context.Context, j *data.Job, video *data.Video, extractionErr error) error {
+	// Restore the status of the videos that were not extracted (i.e. cancelled) without recording an attempt
+	if errors.Is(extractionErr, job.ErrCancelled) {
+		if video.ExtractionStatus != data.StageStatusInProgress {
+			return nil
 		}
+		return s.svcs.Data.UpdateVideoStageStatus(ctx, video, data.StageExtraction, video.RestoredStatus())
+	}
 
-		if localReference != service.InvalidURL {
-			// Store the local reference video to an external storage
-			extractionURL, err = storagesvc.NewFile(ctx, video.ChannelID, localReference, fmt.Sprintf("%s.mp4", video.VideoID))
-			if err != nil {
-				errorStream <- err
-				errors++
-				extractionURL = service.InvalidURL
-				updateDb(datasvc, errorStream, &video, &job, &extractionURL)
-				continue
-			}
-		} else {
-			// If the video is not extracted, use the unextracted URL
-			extractionURL = localReference
+	// Update the video with extraction URL if successful or with the extraction error
+	now := time.Now()
+	video.ExtractedAt = &now
+	video.ExtractionStatus = data.StageStatusSucceeded
+	video.ExtractionError = nil
+	if extractionErr != nil {
+		message := extractionErr.Error()
+		video.ExtractionURL = nil
+		video.ExtractionStatus = data.StageStatusFailed
+		video.ExtractionError = &message
+
+		// Errors that retrying cannot fix are not re-attempted
+		if video.ExtractionErrorClass.RetryPolicy() == service.RetryPolicyNever {
+			video.ExtractionStatus = data.StageStatusDeadLetter
 		}
+	}
 
-		// WARNING: We need to store the unextracted URL to prevent cyclic extraction on the same video
-		// It might be tempting to not store anything, but we need to prevent cyclic extraction
+	err := s.svcs.Data.UpdateVideo(ctx, video, j.Type)
+	if err != nil || extractionErr != nil {
+		return err
+	}
 
-		// Update the video with the extraction URL if successful
-		updateDb(datasvc, errorStream, &video, &job, &extractionURL)
+	// The extracted audio is also the audio artifact so the audio step is complete
+	if mode, _ := s.mode(video.ChannelID); mode == youtube.ExtractionModeAudio {
+		video.AudioURL = video.ExtractionURL
+		video.AudioedAt = &now
+		video.AudioStatus = data.StageStatusSucceeded
+		video.AudioError = nil
+		return s.svcs.Data.UpdateVideo(ctx, video, data.JobTypeAudio)
 	}
 
-	lgr.Logger.Debug("jobextraction.Processor",
-		slog.String("event", "done"),
-	)
+	return nil
 }
 
-func updateDb(datasvc data.IService, errorStream chan error, video *data.Video, job *data.Job, url *string) {
-	// Update the video with transcription URL
-	now := time.Now()
-	video.ExtractionURL = url
-	video.ExtractedAt = &now
-	err := datasvc.UpdateVideo(video, job.Type)
-	if err != nil {
-		errorStream <- err
+// mode returns the extraction mode and the file extension of the channel.
+// Audio-only channels download the audio stream only and skip the audio conversion.
+func (s *stage) mode(channelID string) (youtube.ExtractionMode, string) {
+	if slices.Contains(s.svcs.Config.GetAudioOnlyChannels(), channelID) {
+		return youtube.ExtractionModeAudio, "mp3"
 	}
+
+	return youtube.ExtractionModeVideo, "mp4"
 }
@@ -0,0 +1,142 @@
+package jobindexing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/khaledhikmat/yt-extractor/job"
+	"github.com/khaledhikmat/yt-extractor/service/data"
+	"github.com/khaledhikmat/yt-extractor/service/youtube"
+)
+
+const (
+	// segmentWindow is the longest time range of the captions that make up a transcript segment
+	segmentWindow = 30 * time.Second
+	// segmentLength is the longest (in bytes) transcript segment made from plain text
+	segmentLength = 1000
+)
+
+// stage is a maintenance stage that makes the transcripts of the already transcribed videos searchable.
+// The transcription stage indexes the videos that it transcribes.
+type stage struct {
+	svcs job.Services
+}
+
+func Processor(ctx context.Context,
+	_ string,
+	jobID int64,
+	pageSize int,
+	errorStream chan error,
+	svcs job.Services) {
+	job.Run(ctx, jobID, pageSize, errorStream, svcs.Data, New(svcs))
+}
+
+func New(svcs job.Services) job.Stage {
+	return &stage{svcs: svcs}
+}
+
+func (s *stage) Select(ctx context.Context, j *data.Job, pageSize int) ([]data.Video, error) {
+	return s.svcs.Data.RetrieveUnindexedVideos(ctx, j.ChannelID, pageSize)
+}
+
+// Process indexes the timestamped subtitles of the video if it has them or else its plain transcript
+func (s *stage) Process(ctx context.Context, _ *data.Job, video *data.Video) error {
+	if video.SubtitlesURL != nil {
+		content, err := s.svcs.Storage.RetrieveFile(ctx, video.ChannelID, fmt.Sprintf("%s.srt", video.VideoID))
+		if err != nil {
+			return fmt.Errorf("retrieving subtitles of video %s produced %s", video.VideoID, err.Error())
+		}
+
+		captions, err := youtube.ParseCaptions(bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+
+		return Index(ctx, s.svcs, video, "", captions)
+	}
+
+	content, err := s.svcs.Storage.RetrieveFile(ctx, video.ChannelID, fmt.Sprintf("%s.txt", video.VideoID))
+	if err != nil {
+		return fmt.Errorf("retrieving transcript of video %s produced %s", video.VideoID, err.Error())
+	}
+
+	return Index(ctx, s.svcs, video, string(content), nil)
+}
+
+// Persist has nothing to record: the indexed segments are the outcome
+func (s *stage) Persist(_ context.Context, _ *data.Job, _ *data.Video, _ error) error {
+	return nil
+}
+
+// Index makes the video transcript searchable. The captions are preferred
+// because their segments tell when the text was said.
+// Exported to allow the transcription stage to index the videos that it transcribes
+func Index(ctx context.Context, svcs job.Services, video *data.Video, text string, captions []youtube.Caption) error {
+	segments := segmentText(text)
+	if len(captions) > 0 {
+		segments = segmentCaptions(captions)
+	}
+
+	return svcs.Data.NewTranscriptSegments(ctx, *video, segments)
+}
+
+// segmentCaptions groups the consecutive captions into segments of up to the segment window
+func segmentCaptions(captions []youtube.Caption) []data.TranscriptSegment {
+	segments := []data.TranscriptSegment{}
+
+	var current *data.TranscriptSegment
+	var start time.Duration
+	for _, caption := range captions {
+		if current != nil && caption.End-start > segmentWindow {
+			segments = append(segments, *current)
+			current = nil
+		}
+
+		if current == nil {
+			start = caption.Start
+			startMs := caption.Start.Milliseconds()
+			current = &data.TranscriptSegment{
+				StartMs: &startMs,
+			}
+		} else {
+			current.Text += " "
+		}
+
+		endMs := caption.End.Milliseconds()
+		current.EndMs = &endMs
+		current.Text += caption.Text
+	}
+
+	if current != nil {
+		segments = append(segments, *current)
+	}
+
+	return segments
+}
+
+// segmentText splits the plain text into segments of up to the segment length at word boundaries
+func segmentText(text string) []data.TranscriptSegment {
+	segments := []data.TranscriptSegment{}
+
+	var sb strings.Builder
+	for _, word := range strings.Fields(text) {
+		if sb.Len() > 0 && sb.Len()+1+len(word) > segmentLength {
+			segments = append(segments, data.TranscriptSegment{Text: sb.String()})
+			sb.Reset()
+		}
+
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(word)
+	}
+
+	if sb.Len() > 0 {
+		segments = append(segments, data.TranscriptSegment{Text: sb.String()})
+	}
+
+	return segments
+}
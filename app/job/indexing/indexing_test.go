@@ -0,0 +1,79 @@
+package jobindexing
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khaledhikmat/yt-extractor/service/youtube"
+)
+
+func TestSegmentCaptions(t *testing.T) {
+	captions := []youtube.Caption{
+		{Start: 0, End: 10 * time.Second, Text: "one"},
+		{Start: 10 * time.Second, End: 25 * time.Second, Text: "two"},
+		// Ends past the window of the first caption so it starts the next segment
+		{Start: 25 * time.Second, End: 35 * time.Second, Text: "three"},
+		{Start: 40 * time.Second, End: 50 * time.Second, Text: "four"},
+	}
+
+	segments := segmentCaptions(captions)
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 segments, got %+v", segments)
+	}
+
+	tests := []struct {
+		text    string
+		startMs int64
+		endMs   int64
+	}{
+		{"one two", 0, 25000},
+		{"three four", 25000, 50000},
+	}
+
+	for i, test := range tests {
+		segment := segments[i]
+		if segment.Text != test.text || segment.StartMs == nil || *segment.StartMs != test.startMs || segment.EndMs == nil || *segment.EndMs != test.endMs {
+			t.Errorf("segment %d: expected %q from %d to %d ms, got %+v", i, test.text, test.startMs, test.endMs, segment)
+		}
+	}
+
+	if len(segmentCaptions(nil)) != 0 {
+		t.Errorf("expected no segments without captions")
+	}
+}
+
+func TestSegmentText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []int
+	}{
+		{name: "empty", text: "  ", expected: []int{}},
+		{name: "short", text: "one  two\nthree", expected: []int{len("one two three")}},
+		// 200 words of 4 bytes take 999 bytes with the spaces so the 201st word starts a segment
+		{name: "long", text: strings.Repeat("word ", 201), expected: []int{999, 4}},
+	}
+
+	for _, test := range tests {
+		segments := segmentText(test.text)
+		lengths := []int{}
+		for _, segment := range segments {
+			lengths = append(lengths, len(segment.Text))
+			if segment.StartMs != nil || segment.EndMs != nil {
+				t.Errorf("%s: expected a segment without a time range, got %+v", test.name, segment)
+			}
+		}
+
+		if len(lengths) != len(test.expected) {
+			t.Errorf("%s: expected segments of %v bytes, got %v", test.name, test.expected, lengths)
+			continue
+		}
+		for i := range lengths {
+			if lengths[i] != test.expected[i] || lengths[i] > segmentLength {
+				t.Errorf("%s: expected segments of %v bytes, got %v", test.name, test.expected, lengths)
+				break
+			}
+		}
+	}
+}
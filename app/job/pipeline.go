@@ -0,0 +1,270 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/khaledhikmat/yt-extractor/service/data"
+	"github.com/khaledhikmat/yt-extractor/service/lgr"
+)
+
+// pipeline runs the nodes of a job such that every video flows through
+// the stages independently of the other videos
+type pipeline struct {
+	datasvc     data.IService
+	errorStream chan error
+	nodes       []*pipelineNode
+	next        map[string][]*pipelineNode
+	wg          sync.WaitGroup
+}
+
+type pipelineNode struct {
+	Node
+	runner *runner
+	// jobs are the runner job of every node job type so the videos are processed
+	// with the job type they are selected with
+	jobs  map[data.JobType]*data.Job
+	slots chan struct{}
+	mutex sync.Mutex
+	seen  map[string]bool
+}
+
+// SortPipeline validates the pipeline nodes and returns them in topological order
+func SortPipeline(nodes []Node) ([]Node, error) {
+	byName := map[string]Node{}
+	for _, node := range nodes {
+		if _, ok := byName[node.Name]; ok {
+			return nil, fmt.Errorf("pipeline stage %s is duplicated", node.Name)
+		}
+
+		if len(node.Types) == 0 {
+			return nil, fmt.Errorf("pipeline stage %s does not have job types", node.Name)
+		}
+
+		byName[node.Name] = node
+	}
+
+	sorted := []Node{}
+	visited := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var visit func(node Node) error
+	visit = func(node Node) error {
+		if visited[node.Name] {
+			return nil
+		}
+
+		if visiting[node.Name] {
+			return fmt.Errorf("pipeline stage %s depends on itself", node.Name)
+		}
+
+		visiting[node.Name] = true
+		for _, after := range node.After {
+			previous, ok := byName[after]
+			if !ok {
+				return fmt.Errorf("pipeline stage %s comes after unknown stage %s", node.Name, after)
+			}
+
+			err := visit(previous)
+			if err != nil {
+				return err
+			}
+		}
+		visiting[node.Name] = false
+		visited[node.Name] = true
+
+		sorted = append(sorted, node)
+		return nil
+	}
+
+	for _, node := range nodes {
+		err := visit(node)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// RunPipeline runs the pipeline nodes for a job. Every stage selects its candidates but a video
+// is only selected by the first stage (in pipeline order) that selects it. As soon as a stage
+// is done with a video, it is handed over to the following stages that accept it.
+// The job reports the per-stage counts.
+func RunPipeline(ctx context.Context,
+	jobID int64,
+	pageSize int,
+	errorStream chan error,
+	datasvc data.IService,
+	nodes []Node) {
+	job, err := begin(ctx, datasvc, jobID)
+	if err != nil {
+		errorStream <- err
+		return
+	}
+
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("job.%s", job.Type))
+	defer span.End()
+
+	p := &pipeline{
+		datasvc:     datasvc,
+		errorStream: errorStream,
+		next:        map[string][]*pipelineNode{},
+	}
+
+	var selectErr error
+	defer func() {
+		job.Stages = data.StageCounts{}
+		videos := map[string]bool{}
+		for _, node := range p.nodes {
+			job.Stages[node.Name] = data.StageCount{
+				Videos: node.runner.videos.Load(),
+				Errors: node.runner.errors.Load(),
+			}
+			job.Errors += node.runner.errors.Load()
+			for videoID := range node.seen {
+				videos[videoID] = true
+			}
+		}
+		job.Videos = int64(len(videos))
+
+		if err != nil {
+			job.Errors++
+		}
+
+		end(ctx, datasvc, errorStream, &job, errors.Join(err, selectErr))
+	}()
+
+	nodes, err = SortPipeline(nodes)
+	if err != nil {
+		errorStream <- err
+		return
+	}
+
+	for _, node := range nodes {
+		n := &pipelineNode{
+			Node:  node,
+			slots: make(chan struct{}, max(node.Workers, 1)),
+			jobs:  map[data.JobType]*data.Job{},
+			seen:  map[string]bool{},
+		}
+		for _, jobType := range node.Types {
+			n.jobs[jobType] = &data.Job{
+				ID:        job.ID,
+				ChannelID: job.ChannelID,
+				Type:      jobType,
+				State:     data.JobStateRunning,
+				StartedAt: job.StartedAt,
+			}
+		}
+		n.runner = newRunner(datasvc, errorStream, node.Stage, n.jobs[node.Types[0]])
+
+		for _, after := range node.After {
+			p.next[after] = append(p.next[after], n)
+		}
+		p.nodes = append(p.nodes, n)
+	}
+
+	// Videos may be anywhere in the pipeline so every stage selects its own candidates
+	selected := map[string]bool{}
+	for _, n := range p.nodes {
+		for _, jobType := range n.Types {
+			videos, err := n.Stage.Select(ctx, n.jobs[jobType], pageSize)
+			if err != nil {
+				// The other stages still run but the job fails
+				n.runner.fail(ctx, err)
+				selectErr = errors.Join(selectErr, err)
+			}
+
+			lgr.Logger.Debug("job.RunPipeline",
+				slog.String("event", "receivedVideos"),
+				slog.String("stage", n.Name),
+				slog.String("type", string(jobType)),
+				slog.Int("videos", len(videos)),
+			)
+
+			for _, video := range videos {
+				if selected[video.VideoID] {
+					continue
+				}
+				selected[video.VideoID] = true
+				p.enqueue(ctx, n, n.jobs[jobType], video)
+			}
+		}
+	}
+
+	p.wg.Wait()
+
+	for _, n := range p.nodes {
+		n.runner.finish(ctx)
+	}
+}
+
+// enqueue processes the video with the job in the node as soon as the node has a free slot
+func (p *pipeline) enqueue(ctx context.Context, n *pipelineNode, job *data.Job, video data.Video) {
+	n.mutex.Lock()
+	if n.seen[video.VideoID] {
+		n.mutex.Unlock()
+		return
+	}
+	n.seen[video.VideoID] = true
+	n.mutex.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		select {
+		case <-ctx.Done():
+			return
+		case n.slots <- struct{}{}:
+		}
+
+		n.runner.runVideo(ctx, job, &video)
+		<-n.slots
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.handOver(ctx, n, video)
+	}()
+}
+
+// handOver offers the video to the following stages of the node
+func (p *pipeline) handOver(ctx context.Context, n *pipelineNode, video data.Video) {
+	if len(p.next[n.Name]) == 0 {
+		return
+	}
+
+	// Reload the video because the database decides the stage outcome (i.e. dead letter)
+	latest, err := p.datasvc.RetrieveVideoByID(ctx, video.ID)
+	if err != nil {
+		p.errorStream <- err
+		return
+	}
+
+	p.offer(ctx, n, &latest)
+}
+
+// offer enqueues the video in the following stages of the node that accept it. The video skips
+// the stages that do not accept it (i.e. audio for captioned videos) so it reaches their followers.
+func (p *pipeline) offer(ctx context.Context, n *pipelineNode, video *data.Video) {
+	for _, next := range p.next[n.Name] {
+		follower, ok := next.Stage.(Follower)
+		if !ok {
+			continue
+		}
+
+		// Handed over videos are fresh to the following stages
+		if !follower.Accepts(next.runner.job, video) {
+			p.offer(ctx, next, video)
+			continue
+		}
+
+		p.enqueue(ctx, next, next.runner.job, *video)
+	}
+}
@@ -0,0 +1,165 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/khaledhikmat/yt-extractor/service/data"
+)
+
+// stepStage moves the videos from one status (or the failed status for its error job type) to the next
+type stepStage struct {
+	datasvc   data.IService
+	from      data.StageStatus
+	to        data.StageStatus
+	errorType data.JobType
+	mutex     sync.Mutex
+	processed []string
+	types     map[string]data.JobType
+}
+
+func (s *stepStage) Select(ctx context.Context, j *data.Job, _ int) ([]data.Video, error) {
+	from := s.from
+	if s.errorType != "" && j.Type == s.errorType {
+		from = data.StageStatusFailed
+	}
+
+	videos := []data.Video{}
+	for id := int64(1); ; id++ {
+		video, _ := s.datasvc.RetrieveVideoByID(ctx, id)
+		if video.ID == 0 {
+			return videos, nil
+		}
+
+		if video.ExtractionStatus == from {
+			videos = append(videos, video)
+		}
+	}
+}
+
+func (s *stepStage) Process(_ context.Context, j *data.Job, video *data.Video) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.processed = append(s.processed, video.VideoID)
+	if s.types == nil {
+		s.types = map[string]data.JobType{}
+	}
+	s.types[video.VideoID] = j.Type
+	video.ExtractionStatus = s.to
+	return nil
+}
+
+func (s *stepStage) Persist(ctx context.Context, j *data.Job, video *data.Video, _ error) error {
+	return s.datasvc.UpdateVideo(ctx, video, j.Type)
+}
+
+func (s *stepStage) Accepts(_ *data.Job, video *data.Video) bool {
+	return video.ExtractionStatus == s.from
+}
+
+func TestSortPipeline(t *testing.T) {
+	nodes, err := SortPipeline([]Node{
+		{Name: "c", Types: []data.JobType{data.JobTypeTranscription}, After: []string{"b"}},
+		{Name: "b", Types: []data.JobType{data.JobTypeAudio}, After: []string{"a"}},
+		{Name: "a", Types: []data.JobType{data.JobTypeExtraction}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if nodes[0].Name != "a" || nodes[1].Name != "b" || nodes[2].Name != "c" {
+		t.Errorf("unexpected order %v", nodes)
+	}
+
+	_, err = SortPipeline([]Node{
+		{Name: "a", Types: []data.JobType{data.JobTypeExtraction}, After: []string{"b"}},
+		{Name: "b", Types: []data.JobType{data.JobTypeAudio}, After: []string{"a"}},
+	})
+	if err == nil {
+		t.Errorf("expected a cycle error")
+	}
+
+	_, err = SortPipeline([]Node{
+		{Name: "a", Types: []data.JobType{data.JobTypeExtraction}, After: []string{"x"}},
+	})
+	if err == nil {
+		t.Errorf("expected an unknown stage error")
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	datasvc := &fakeData{
+		job:       data.Job{ID: 1, ChannelID: "channel", Type: data.JobTypeAutomation, State: data.JobStateQueued},
+		jobVideos: map[int64]data.JobVideo{},
+		videos: map[int64]data.Video{
+			1: {ID: 1, ChannelID: "channel", VideoID: "fresh", ExtractionStatus: data.StageStatusPending},
+			2: {ID: 2, ChannelID: "channel", VideoID: "halfway", ExtractionStatus: data.StageStatusSucceeded},
+		},
+	}
+
+	first := &stepStage{datasvc: datasvc, from: data.StageStatusPending, to: data.StageStatusSucceeded}
+	second := &stepStage{datasvc: datasvc, from: data.StageStatusSucceeded, to: data.StageStatusDeadLetter}
+
+	RunPipeline(context.Background(), 1, 10, make(chan error, 10), datasvc, []Node{
+		{Name: "second", Stage: second, Types: []data.JobType{data.JobTypeAudio}, After: []string{"first"}, Workers: 2},
+		{Name: "first", Stage: first, Types: []data.JobType{data.JobTypeExtraction}},
+	})
+
+	if datasvc.job.State != data.JobStateCompleted {
+		t.Errorf("expected a completed job, got %s", datasvc.job.State)
+	}
+
+	// The fresh video is handed over to the second stage as soon as the first stage is done with it
+	if len(first.processed) != 1 || len(second.processed) != 2 {
+		t.Errorf("unexpected processed videos %v and %v", first.processed, second.processed)
+	}
+
+	for _, video := range datasvc.videos {
+		if video.ExtractionStatus != data.StageStatusDeadLetter {
+			t.Errorf("expected video %s to go through the pipeline, got %s", video.VideoID, video.ExtractionStatus)
+		}
+	}
+
+	if datasvc.job.Videos != 2 || datasvc.job.Stages["first"].Videos != 1 || datasvc.job.Stages["second"].Videos != 2 {
+		t.Errorf("unexpected counts %d %+v", datasvc.job.Videos, datasvc.job.Stages)
+	}
+}
+
+func TestRunPipelineHandOver(t *testing.T) {
+	datasvc := &fakeData{
+		job:       data.Job{ID: 1, ChannelID: "channel", Type: data.JobTypeAutomation, State: data.JobStateQueued},
+		jobVideos: map[int64]data.JobVideo{},
+		videos: map[int64]data.Video{
+			1: {ID: 1, ChannelID: "channel", VideoID: "fresh", ExtractionStatus: data.StageStatusPending},
+			2: {ID: 2, ChannelID: "channel", VideoID: "failed", ExtractionStatus: data.StageStatusFailed},
+		},
+	}
+
+	first := &stepStage{datasvc: datasvc, from: data.StageStatusPending, to: data.StageStatusSucceeded, errorType: data.JobTypeExtractionError}
+	// The second stage does not accept the videos of the first stage so they skip it
+	second := &stepStage{datasvc: datasvc, from: data.StageStatusInProgress, to: data.StageStatusPending}
+	third := &stepStage{datasvc: datasvc, from: data.StageStatusSucceeded, to: data.StageStatusDeadLetter}
+
+	RunPipeline(context.Background(), 1, 10, make(chan error, 10), datasvc, []Node{
+		{Name: "first", Stage: first, Types: []data.JobType{data.JobTypeExtraction, data.JobTypeExtractionError}},
+		{Name: "second", Stage: second, Types: []data.JobType{data.JobTypeAudio}, After: []string{"first"}},
+		{Name: "third", Stage: third, Types: []data.JobType{data.JobTypeTranscription}, After: []string{"second"}},
+	})
+
+	// The videos are processed with the job type they are selected with
+	if first.types["fresh"] != data.JobTypeExtraction || first.types["failed"] != data.JobTypeExtractionError {
+		t.Errorf("unexpected job types %v", first.types)
+	}
+
+	if len(second.processed) != 0 || len(third.processed) != 2 {
+		t.Errorf("unexpected processed videos %v and %v", second.processed, third.processed)
+	}
+
+	for _, video := range datasvc.videos {
+		if video.ExtractionStatus != data.StageStatusDeadLetter {
+			t.Errorf("expected video %s to go through the pipeline, got %s", video.VideoID, video.ExtractionStatus)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mdobak/go-xerrors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/khaledhikmat/yt-extractor/service/data"
+	"github.com/khaledhikmat/yt-extractor/service/lgr"
+)
+
+var (
+	tracer = otel.Tracer(fmt.Sprintf("yt.extractor.%s.job", os.Getenv("APP_NAME")))
+	meter  = otel.Meter(fmt.Sprintf("yt.extractor.%s.job", os.Getenv("APP_NAME")))
+
+	videosCounter metric.Int64Counter
+	errorsCounter metric.Int64Counter
+)
+
+func init() {
+	var err error
+	videosCounter, err = meter.Int64Counter(
+		fmt.Sprintf("yt.extractor.%s.job.videos.counter", os.Getenv("APP_NAME")),
+		metric.WithDescription("The number of videos processed by jobs"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lgr.Logger.Error(
+			"creating counter",
+			slog.Any("error", xerrors.New(err.Error())),
+		)
+	}
+
+	errorsCounter, err = meter.Int64Counter(
+		fmt.Sprintf("yt.extractor.%s.job.errors.counter", os.Getenv("APP_NAME")),
+		metric.WithDescription("The number of job video errors"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lgr.Logger.Error(
+			"creating counter",
+			slog.Any("error", xerrors.New(err.Error())),
+		)
+	}
+}
+
+// runner keeps the counters of a running job
+type runner struct {
+	datasvc     data.IService
+	errorStream chan error
+	stage       Stage
+	job         *data.Job
+	videos      atomic.Int64
+	errors      atomic.Int64
+}
+
+// Run runs a stage for a job: it sets the job running, processes the selected videos
+// and completes (or cancels) the job
+func Run(ctx context.Context,
+	jobID int64,
+	pageSize int,
+	errorStream chan error,
+	datasvc data.IService,
+	stage Stage) {
+	job, err := begin(ctx, datasvc, jobID)
+	if err != nil {
+		errorStream <- err
+		return
+	}
+
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("job.%s", job.Type))
+	defer span.End()
+
+	r := newRunner(datasvc, errorStream, stage, &job)
+
+	var selectErr error
+	defer func() {
+		job.Videos = r.videos.Load()
+		job.Errors = r.errors.Load()
+		end(ctx, datasvc, errorStream, &job, selectErr)
+	}()
+
+	videos, selectErr := stage.Select(ctx, &job, pageSize)
+	if selectErr != nil {
+		r.fail(ctx, selectErr)
+		return
+	}
+
+	lgr.Logger.Debug("job.Run",
+		slog.String("event", "receivedVideos"),
+		slog.String("type", string(job.Type)),
+		slog.Int("videos", len(videos)),
+	)
+
+	workers := 1
+	if concurrent, ok := stage.(Concurrent); ok {
+		workers = max(concurrent.Workers(), 1)
+	}
+
+	// Feed the videos to the workers until cancelled
+	feed := make(chan *data.Video)
+	go func() {
+		defer close(feed)
+		for i := range videos {
+			select {
+			case <-ctx.Done():
+				return
+			case feed <- &videos[i]:
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for video := range feed {
+				r.runVideo(ctx, r.job, video)
+			}
+		}()
+	}
+	wg.Wait()
+
+	r.finish(ctx)
+}
+
+// begin sets the job running
+func begin(ctx context.Context, datasvc data.IService, jobID int64) (data.Job, error) {
+	job, err := datasvc.RetrieveJobByID(ctx, jobID)
+	if err != nil {
+		return job, err
+	}
+
+	job.State = data.JobStateRunning
+	err = datasvc.UpdateJob(ctx, &job)
+	if err != nil {
+		return job, err
+	}
+
+	// The videos left in progress by a crashed (or killed) job are released once their lease expires
+	released, err := datasvc.ReleaseStaleVideos(ctx)
+	if err != nil {
+		lgr.Logger.Error("job.begin",
+			slog.String("event", "releasingStaleVideos"),
+			slog.Any("error", xerrors.New(err.Error())),
+		)
+	} else if released > 0 {
+		lgr.Logger.Info("job.begin",
+			slog.String("event", "releasedStaleVideos"),
+			slog.Int64("videos", released),
+		)
+	}
+
+	return job, nil
+}
+
+// end completes the job, fails it if the job could not run (i.e. its videos could not be selected)
+// or cancels it if the context is cancelled
+func end(ctx context.Context, datasvc data.IService, errorStream chan error, job *data.Job, jobErr error) {
+	now := time.Now()
+	job.State = data.JobStateCompleted
+	if ctx.Err() != nil {
+		job.State = data.JobStateCancelled
+	} else if jobErr != nil {
+		job.State = data.JobStateFailed
+	}
+	job.CompletedAt = &now
+	// The job is updated even if the context is cancelled so it is not left running
+	err := datasvc.UpdateJob(context.WithoutCancel(ctx), job)
+	if err != nil {
+		errorStream <- err
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int64("job.id", job.ID),
+		attribute.String("job.type", string(job.Type)),
+		attribute.String("job.channel", job.ChannelID),
+		attribute.String("job.state", string(job.State)),
+		attribute.Int64("job.videos", job.Videos),
+		attribute.Int64("job.errors", job.Errors),
+	)
+
+	lgr.Logger.Debug("job.end",
+		slog.String("event", "done"),
+		slog.String("type", string(job.Type)),
+		slog.String("state", string(job.State)),
+		slog.Int64("videos", job.Videos),
+		slog.Int64("errors", job.Errors),
+	)
+}
+
+func newRunner(datasvc data.IService, errorStream chan error, stage Stage, job *data.Job) *runner {
+	return &runner{
+		datasvc:     datasvc,
+		errorStream: errorStream,
+		stage:       stage,
+		job:         job,
+	}
+}
+
+// finish lets the stage act once all the videos are processed unless the job is cancelled
+func (r *runner) finish(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	if finisher, ok := r.stage.(Finisher); ok {
+		err := finisher.Finish(ctx, r.job)
+		if err != nil {
+			r.errorStream <- err
+		}
+	}
+}
+
+// runVideo processes and persists a single video with the job (i.e. the job type the video is
+// selected with) and records it in the job ledger
+func (r *runner) runVideo(ctx context.Context, job *data.Job, video *data.Video) {
+	ctx, span := tracer.Start(ctx, fmt.Sprintf("job.%s.video", job.Type))
+	span.SetAttributes(attribute.String("video.id", video.VideoID))
+	defer span.End()
+
+	r.videos.Add(1)
+	videosCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("job.type", string(job.Type))))
+
+	jobVideo := data.JobVideo{
+		JobID:     job.ID,
+		ChannelID: video.ChannelID,
+		VideoID:   video.VideoID,
+		StartedAt: time.Now(),
+	}
+	id, err := r.datasvc.NewJobVideo(ctx, jobVideo)
+	if err != nil {
+		r.errorStream <- err
+	}
+	jobVideo.ID = id
+
+	processErr := r.stage.Process(ctx, job, video)
+	if errors.Is(processErr, ErrSkipped) {
+		r.complete(ctx, &jobVideo, nil)
+		return
+	}
+
+	// Videos interrupted by a cancellation are persisted (so they are not left in progress)
+	// but they are not counted as errors
+	if processErr != nil && ctx.Err() != nil {
+		processErr = fmt.Errorf("%w: %w", ErrCancelled, processErr)
+	}
+	err = errors.Join(processErr, r.stage.Persist(context.WithoutCancel(ctx), job, video, processErr))
+	if err != nil && ctx.Err() == nil {
+		r.fail(ctx, fmt.Errorf("%s video %s produced %w", job.Type, video.VideoID, err))
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	r.complete(ctx, &jobVideo, err)
+}
+
+// complete closes the ledger entry of a video
+func (r *runner) complete(ctx context.Context, jobVideo *data.JobVideo, videoErr error) {
+	if jobVideo.ID <= 0 {
+		return
+	}
+
+	now := time.Now()
+	jobVideo.CompletedAt = &now
+	if videoErr != nil {
+		message := videoErr.Error()
+		jobVideo.Error = &message
+	}
+
+	err := r.datasvc.UpdateJobVideo(context.WithoutCancel(ctx), jobVideo)
+	if err != nil {
+		r.errorStream <- err
+	}
+}
+
+func (r *runner) fail(ctx context.Context, err error) {
+	r.errors.Add(1)
+	errorsCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("job.type", string(r.job.Type))))
+	r.errorStream <- err
+}
@@ -0,0 +1,245 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/khaledhikmat/yt-extractor/service/data"
+)
+
+// fakeData keeps the job and its ledger in memory
+type fakeData struct {
+	data.IService
+	mutex     sync.Mutex
+	job       data.Job
+	jobVideos map[int64]data.JobVideo
+	videos    map[int64]data.Video
+}
+
+func (f *fakeData) RetrieveVideoByID(_ context.Context, id int64) (data.Video, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.videos[id], nil
+}
+
+func (f *fakeData) UpdateVideo(_ context.Context, video *data.Video, _ data.JobType) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.videos[video.ID] = *video
+	return nil
+}
+
+func (f *fakeData) RetrieveJobByID(_ context.Context, _ int64) (data.Job, error) {
+	return f.job, nil
+}
+
+func (f *fakeData) UpdateJob(_ context.Context, job *data.Job) error {
+	f.job = *job
+	return nil
+}
+
+func (f *fakeData) ReleaseStaleVideos(_ context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeData) NewJobVideo(_ context.Context, jobVideo data.JobVideo) (int64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	jobVideo.ID = int64(len(f.jobVideos) + 1)
+	f.jobVideos[jobVideo.ID] = jobVideo
+	return jobVideo.ID, nil
+}
+
+func (f *fakeData) UpdateJobVideo(_ context.Context, jobVideo *data.JobVideo) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.jobVideos[jobVideo.ID] = *jobVideo
+	return nil
+}
+
+// fakeStage fails the "bad" video, skips the "skip" video and persists the rest
+type fakeStage struct {
+	mutex     sync.Mutex
+	persisted []string
+	finished  bool
+	selectErr error
+	// cancel is called by the "cancel" video to interrupt the job
+	cancel      context.CancelFunc
+	persistErrs map[string]error
+}
+
+func (s *fakeStage) Select(_ context.Context, job *data.Job, _ int) ([]data.Video, error) {
+	if s.selectErr != nil {
+		return nil, s.selectErr
+	}
+
+	return []data.Video{
+		{ChannelID: job.ChannelID, VideoID: "good"},
+		{ChannelID: job.ChannelID, VideoID: "bad"},
+		{ChannelID: job.ChannelID, VideoID: "skip"},
+	}, nil
+}
+
+func (s *fakeStage) Process(_ context.Context, _ *data.Job, video *data.Video) error {
+	switch video.VideoID {
+	case "cancel":
+		s.cancel()
+		return context.Canceled
+	case "bad":
+		return errors.New("bad video")
+	case "skip":
+		return ErrSkipped
+	}
+	return nil
+}
+
+func (s *fakeStage) Persist(_ context.Context, _ *data.Job, video *data.Video, processErr error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.persisted = append(s.persisted, video.VideoID)
+	if s.persistErrs == nil {
+		s.persistErrs = map[string]error{}
+	}
+	s.persistErrs[video.VideoID] = processErr
+	return nil
+}
+
+func (s *fakeStage) Workers() int {
+	return 2
+}
+
+func (s *fakeStage) Finish(_ context.Context, _ *data.Job) error {
+	s.finished = true
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	datasvc := &fakeData{
+		job:       data.Job{ID: 1, ChannelID: "channel", Type: data.JobTypeAudio, State: data.JobStateQueued},
+		jobVideos: map[int64]data.JobVideo{},
+	}
+	stage := &fakeStage{}
+
+	errorStream := make(chan error, 10)
+	Run(context.Background(), 1, 10, errorStream, datasvc, stage)
+	close(errorStream)
+
+	if datasvc.job.State != data.JobStateCompleted || datasvc.job.CompletedAt == nil {
+		t.Errorf("expected a completed job, got %+v", datasvc.job)
+	}
+
+	if datasvc.job.Videos != 3 || datasvc.job.Errors != 1 {
+		t.Errorf("expected 3 videos and 1 error, got %d videos and %d errors", datasvc.job.Videos, datasvc.job.Errors)
+	}
+
+	if len(stage.persisted) != 2 {
+		t.Errorf("expected the good and bad videos to be persisted, got %v", stage.persisted)
+	}
+
+	if !stage.finished {
+		t.Errorf("expected the stage to be finished")
+	}
+
+	errs := 0
+	for range errorStream {
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("expected 1 streamed error, got %d", errs)
+	}
+
+	if len(datasvc.jobVideos) != 3 {
+		t.Fatalf("expected 3 ledger entries, got %d", len(datasvc.jobVideos))
+	}
+
+	for _, jobVideo := range datasvc.jobVideos {
+		if jobVideo.CompletedAt == nil {
+			t.Errorf("expected ledger entry %s to be completed", jobVideo.VideoID)
+		}
+
+		if (jobVideo.Error != nil) != (jobVideo.VideoID == "bad") {
+			t.Errorf("unexpected ledger entry error for %s: %v", jobVideo.VideoID, jobVideo.Error)
+		}
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	datasvc := &fakeData{
+		job:       data.Job{ID: 1, ChannelID: "channel", Type: data.JobTypeAudio, State: data.JobStateQueued},
+		jobVideos: map[int64]data.JobVideo{},
+	}
+	stage := &fakeStage{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	errorStream := make(chan error, 10)
+	Run(ctx, 1, 10, errorStream, datasvc, stage)
+
+	if datasvc.job.State != data.JobStateCancelled {
+		t.Errorf("expected a cancelled job, got %s", datasvc.job.State)
+	}
+
+	if stage.finished {
+		t.Errorf("expected a cancelled stage not to be finished")
+	}
+}
+
+func TestRunSelectFailed(t *testing.T) {
+	datasvc := &fakeData{
+		job:       data.Job{ID: 1, ChannelID: "channel", Type: data.JobTypeAudio, State: data.JobStateQueued},
+		jobVideos: map[int64]data.JobVideo{},
+	}
+	stage := &fakeStage{selectErr: errors.New("database is down")}
+
+	errorStream := make(chan error, 10)
+	Run(context.Background(), 1, 10, errorStream, datasvc, stage)
+	close(errorStream)
+
+	if datasvc.job.State != data.JobStateFailed || datasvc.job.CompletedAt == nil || datasvc.job.Errors != 1 {
+		t.Errorf("expected a failed job with 1 error, got %+v", datasvc.job)
+	}
+
+	if stage.finished {
+		t.Errorf("expected a failed stage not to be finished")
+	}
+}
+
+func TestRunCancelledVideo(t *testing.T) {
+	datasvc := &fakeData{
+		job:       data.Job{ID: 1, ChannelID: "cancel", Type: data.JobTypeAudio, State: data.JobStateQueued},
+		jobVideos: map[int64]data.JobVideo{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stage := &fakeStage{cancel: cancel}
+
+	errorStream := make(chan error, 10)
+	Run(ctx, 1, 10, errorStream, datasvc, &cancelStage{stage})
+
+	// The interrupted video is persisted with the cancellation so the stage can restore it
+	if !errors.Is(stage.persistErrs["cancel"], ErrCancelled) {
+		t.Errorf("expected a cancelled video, got %v", stage.persistErrs["cancel"])
+	}
+
+	if datasvc.job.State != data.JobStateCancelled || datasvc.job.Errors != 0 {
+		t.Errorf("expected a cancelled job without errors, got %+v", datasvc.job)
+	}
+}
+
+// cancelStage selects the "cancel" video only
+type cancelStage struct {
+	*fakeStage
+}
+
+func (s *cancelStage) Select(_ context.Context, job *data.Job, _ int) ([]data.Video, error) {
+	return []data.Video{{ChannelID: job.ChannelID, VideoID: "cancel"}}, nil
+}
@@ -0,0 +1,70 @@
+package jobshorts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/khaledhikmat/yt-extractor/job"
+	"github.com/khaledhikmat/yt-extractor/service/data"
+)
+
+// stage is a maintenance stage that re-classifies all the channel videos as Shorts or not
+type stage struct {
+	svcs job.Services
+}
+
+func Processor(ctx context.Context,
+	_ string,
+	jobID int64,
+	pageSize int,
+	errorStream chan error,
+	svcs job.Services) {
+	job.Run(ctx, jobID, pageSize, errorStream, svcs.Data, &stage{svcs: svcs})
+}
+
+// Select walks through all the channel videos one page at a time
+func (s *stage) Select(ctx context.Context, j *data.Job, pageSize int) ([]data.Video, error) {
+	videos := []data.Video{}
+	for page := 1; ctx.Err() == nil; page++ {
+		pageVideos, err := s.svcs.Data.RetrieveVideos(ctx, j.ChannelID, page, pageSize, "published_at", "desc")
+		if err != nil {
+			return videos, err
+		}
+
+		if len(pageVideos) == 0 {
+			break
+		}
+
+		for _, video := range pageVideos {
+			if video.Availability.IsGone() {
+				continue
+			}
+			videos = append(videos, video)
+		}
+	}
+
+	return videos, nil
+}
+
+func (s *stage) Process(ctx context.Context, _ *data.Job, video *data.Video) error {
+	short, err := s.svcs.Youtube.IsShort(ctx, video.VideoID, video.Duration)
+	if err != nil {
+		return fmt.Errorf("classifying video %s as short produced %s", video.VideoID, err.Error())
+	}
+
+	// The unclassified videos are persisted so that the refresh does not classify them again
+	if short == video.Short && video.ShortClassifiedAt != nil {
+		return job.ErrSkipped
+	}
+
+	video.Short = short
+	return nil
+}
+
+func (s *stage) Persist(ctx context.Context, j *data.Job, video *data.Video, processErr error) error {
+	if processErr != nil {
+		return nil
+	}
+
+	return s.svcs.Data.UpdateVideo(ctx, video, j.Type)
+}
@@ -2,138 +2,179 @@ package jobtranscription
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/khaledhikmat/yt-extractor/job"
+	jobindexing "github.com/khaledhikmat/yt-extractor/job/indexing"
 	"github.com/khaledhikmat/yt-extractor/service"
-	"github.com/khaledhikmat/yt-extractor/service/audio"
-	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
-	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/data"
 	"github.com/khaledhikmat/yt-extractor/service/lgr"
-	"github.com/khaledhikmat/yt-extractor/service/storage"
-	"github.com/khaledhikmat/yt-extractor/service/transcription"
 	"github.com/khaledhikmat/yt-extractor/service/youtube"
 )
 
+// stage transcribes the videos from their audio or from their Youtube captions
+type stage struct {
+	svcs job.Services
+}
+
 func Processor(ctx context.Context,
-	channelID string,
+	_ string,
 	jobID int64,
 	pageSize int,
 	errorStream chan error,
-	cfgsvc config.IService,
-	datasvc data.IService,
-	_ youtube.IService,
-	audiosvc audio.IService,
-	storagesvc storage.IService,
-	_ cloudconvert.IService,
-	transcriptionsvc transcription.IService) {
-	// Update job state to running
-	job, err := datasvc.RetrieveJobByID(jobID)
-	if err != nil {
-		errorStream <- err
-		return
+	svcs job.Services) {
+	job.Run(ctx, jobID, pageSize, errorStream, svcs.Data, New(svcs))
+}
+
+// New creates the transcription stage. The job type decides whether the videos are
+// transcribed from their audio or from their captions.
+func New(svcs job.Services) job.Stage {
+	return &stage{svcs: svcs}
+}
+
+// Transcribe transcribes a single video from its audio outside of a job.
+// Exported to allow the server API (i.e. server/api.go) to also call it
+// upon webhook invocations
+func Transcribe(ctx context.Context, video *data.Video, svcs job.Services) error {
+	s := &stage{svcs: svcs}
+	j := &data.Job{
+		ChannelID: video.ChannelID,
+		Type:      data.JobTypeTranscription,
 	}
-	job.State = data.JobStateRunning
-	err = datasvc.UpdateJob(&job)
-	if err != nil {
-		errorStream <- err
-		return
+
+	err := s.Process(ctx, j, video)
+	return errors.Join(err, s.Persist(ctx, j, video, err))
+}
+
+func (s *stage) Select(ctx context.Context, j *data.Job, pageSize int) ([]data.Video, error) {
+	switch j.Type {
+	case data.JobTypeTranscriptionError:
+		return s.svcs.Data.RetrieveTranscribeErroredVideos(ctx, j.ChannelID, pageSize)
+	case data.JobTypeCaptions:
+		return s.svcs.Data.RetrieveCaptionableVideos(ctx, j.ChannelID, pageSize)
+	default:
+		return s.svcs.Data.RetrieveUntranscribedVideos(ctx, j.ChannelID, pageSize)
 	}
+}
 
-	errors := 0
-	videos := []data.Video{}
-	finalState := data.JobStateCompleted
+// Accepts the videos that are yet to be transcribed. Captions are attempted once (before the
+// video is extracted) and audio transcriptions require audioed videos.
+func (s *stage) Accepts(j *data.Job, video *data.Video) bool {
+	if video.Availability != service.AvailabilityPublic ||
+		video.TranscriptionStatus != data.StageStatusPending ||
+		!video.IsPublishedSince(s.svcs.Config.GetVideoTranscriptionCutoffDate()) {
+		return false
+	}
 
-	defer func() {
-		// Update job state to completed
-		now := time.Now()
-		job.State = finalState
-		job.Videos = int64(len(videos))
-		job.Errors = int64(errors)
-		job.CompletedAt = &now
-		err = datasvc.UpdateJob(&job)
-		if err != nil {
-			errorStream <- err
-			return
-		}
-	}()
+	if j.Type == data.JobTypeCaptions {
+		return video.AudioStatus == data.StageStatusPending &&
+			video.CaptionsAttemptedAt == nil &&
+			(video.Caption || s.svcs.Config.IsAutoCaptionsAllowed())
+	}
+
+	return video.ExtractionStatus == data.StageStatusSucceeded &&
+		video.AudioStatus == data.StageStatusSucceeded
+}
 
-	// Retrieve untranscribed videos
-	// Retrieve unaudioed videos from Youtube
-	if job.Type == data.JobTypeTranscriptionError {
-		videos, err = datasvc.RetrieveTranscribeErroredVideos(channelID, pageSize)
-	} else {
-		videos, err = datasvc.RetrieveUntranscribedVideos(channelID, pageSize)
+// WARNING: Any error causes the transcription status to be set to failed
+// This means that transcription will be re-attempted
+func (s *stage) Process(ctx context.Context, j *data.Job, video *data.Video) error {
+	// Captions are a free transcription source. If they are not acceptable, the attempt
+	// is recorded so the video can be extracted, converted to audio and then transcribed.
+	if j.Type == data.JobTypeCaptions {
+		err := s.processCaptions(ctx, video)
+		if err == youtube.ErrNoCaptions {
+			err = s.recordCaptions(ctx, video, err)
+			if err != nil {
+				return err
+			}
+			return job.ErrSkipped
+		}
+		return err
 	}
+
+	err := s.svcs.Data.UpdateVideoStageStatus(ctx, video, data.StageTranscription, data.StageStatusInProgress)
 	if err != nil {
-		errorStream <- err
-		errors++
+		return err
 	}
 
-	lgr.Logger.Debug("jobtranscription.Processor",
-		slog.String("event", "receivedVideos"),
-		slog.Int("videos", len(videos)),
-	)
+	return s.processAudio(ctx, video)
+}
 
-	// Update each videos with transcribed data
-	// WARNING: Any error causes the transcription URL to be set to invalid
-	// This means that transcription will be re-attempted
-	for _, video := range videos {
-		// If the context is cancelled, exit the loop
-		// But execute the defer block first
-		select {
-		case <-ctx.Done():
-			finalState = data.JobStateCancelled
-			return
-		default:
+func (s *stage) Persist(ctx context.Context, j *data.Job, video *data.Video, transcriptionErr error) error {
+	// Restore the status of the videos that were not transcribed (i.e. cancelled) without recording
+	// an attempt. Cancelled captions are not recorded either.
+	if errors.Is(transcriptionErr, job.ErrCancelled) {
+		if video.TranscriptionStatus != data.StageStatusInProgress {
+			return nil
 		}
+		return s.svcs.Data.UpdateVideoStageStatus(ctx, video, data.StageTranscription, video.RestoredStatus())
+	}
 
-		// Process a single video for audio transcription
-		err := Process(ctx, &video, errorStream, job.Type, cfgsvc, datasvc, audiosvc, storagesvc, transcriptionsvc)
-		if err != nil {
-			errors++
-			continue
+	// Failed captions are recorded but leave the transcription alone so the video can be transcribed from its audio
+	jobType := j.Type
+	if jobType == data.JobTypeCaptions {
+		err := s.recordCaptions(ctx, video, transcriptionErr)
+		if err != nil || transcriptionErr != nil {
+			return err
 		}
+		jobType = data.JobTypeTranscription
 	}
 
-	lgr.Logger.Debug("jobtranscription.Processor",
-		slog.String("event", "done"),
+	// Update the video with transcription URL if successful or with the transcription error
+	now := time.Now()
+	video.TranscribedAt = &now
+	video.TranscriptionStatus = data.StageStatusSucceeded
+	video.TranscriptionError = nil
+	if transcriptionErr != nil {
+		message := transcriptionErr.Error()
+		video.TranscriptionURL = nil
+		video.TranscriptionStatus = data.StageStatusFailed
+		video.TranscriptionError = &message
+	}
+
+	lgr.Logger.Debug("jobtranscription.Persist",
+		slog.String("event", "updatingDb"),
+		slog.String("videoId", video.VideoID),
+		slog.String("status", string(video.TranscriptionStatus)),
 	)
+
+	return s.svcs.Data.UpdateVideo(ctx, video, jobType)
 }
 
-// Exported to allow the server API (i.e. server/api.go) to also call it
-// upon webhook invocations
-func Process(ctx context.Context,
-	video *data.Video,
-	errorStream chan error,
-	jobType data.JobType,
-	cfgsvc config.IService,
-	datasvc data.IService,
-	audiosvc audio.IService,
-	storagesvc storage.IService,
-	transcriptionsvc transcription.IService) error {
-	var transcriptionURL string
-
-	lgr.Logger.Debug("jobtranscription.Process",
+// recordCaptions records that the captions of the video were attempted along with why they failed (if they did)
+func (s *stage) recordCaptions(ctx context.Context, video *data.Video, captionsErr error) error {
+	now := time.Now()
+	video.CaptionsAttemptedAt = &now
+	video.CaptionsError = nil
+	if captionsErr != nil {
+		message := captionsErr.Error()
+		video.CaptionsError = &message
+	}
+
+	return s.svcs.Data.UpdateVideo(ctx, video, data.JobTypeCaptions)
+}
+
+// processAudio transcribes a single video from its audio
+func (s *stage) processAudio(ctx context.Context, video *data.Video) error {
+	lgr.Logger.Debug("jobtranscription.processAudio",
 		slog.String("event", "aboutToSplit"),
 		slog.String("videoId", video.VideoID),
 	)
 
 	// Use the audio service to segment the audio into 10-min audio files
 	// using the video audio URL so it can be easily transcribed
-	localAudioFiles, err := audiosvc.SplitAudio(*video.AudioURL)
+	localAudioFiles, err := s.svcs.Audio.SplitAudio(*video.AudioURL)
 	if err != nil {
-		errorStream <- err
-		transcriptionURL = service.InvalidURL
-		updateDb(datasvc, errorStream, video, jobType, &transcriptionURL)
 		return err
 	}
 
-	lgr.Logger.Debug("jobtranscription.Process",
+	lgr.Logger.Debug("jobtranscription.processAudio",
 		slog.String("event", "aboutToTranscribe"),
 		slog.String("videoId", video.VideoID),
 		slog.Int("audioFiles", len(localAudioFiles)),
@@ -153,15 +194,12 @@ func Process(ctx context.Context,
 	for _, file := range localAudioFiles {
 		// Use the transcription service to get a transcribed text
 		// and delete local audio file
-		txt, err := transcriptionsvc.TranscribeAudio(file)
+		txt, err := s.svcs.Transcription.TranscribeAudio(file)
 		if err != nil {
-			errorStream <- err
-			transcriptionURL = service.InvalidURL
-			updateDb(datasvc, errorStream, video, jobType, &transcriptionURL)
 			return err
 		}
 
-		lgr.Logger.Debug("jobtranscription.Process",
+		lgr.Logger.Debug("jobtranscription.processAudio",
 			slog.String("event", "completedIndividualTranscription"),
 			slog.String("videoId", video.VideoID),
 			slog.String("audioFile", file),
@@ -170,17 +208,14 @@ func Process(ctx context.Context,
 		transcribedText += txt
 	}
 
-	lgr.Logger.Debug("jobtranscription.Process",
+	lgr.Logger.Debug("jobtranscription.processAudio",
 		slog.String("event", "savingToLocalFile"),
 		slog.String("videoId", video.VideoID),
 	)
 
 	// Save transcribed text in a file
-	localTextFile, err := saveToFile(transcribedText, cfgsvc.GetLocalTranscriptionFolder(), fmt.Sprintf("%s.txt", video.VideoID))
+	localTextFile, err := saveToFile(transcribedText, s.svcs.Config.GetLocalTranscriptionFolder(), fmt.Sprintf("%s.txt", video.VideoID))
 	if err != nil {
-		errorStream <- err
-		transcriptionURL = service.InvalidURL
-		updateDb(datasvc, errorStream, video, jobType, &transcriptionURL)
 		return err
 	}
 
@@ -190,32 +225,85 @@ func Process(ctx context.Context,
 		_ = os.Remove(localTextFile)
 	}()
 
-	lgr.Logger.Debug("jobtranscription.Process",
+	lgr.Logger.Debug("jobtranscription.processAudio",
 		slog.String("event", "uploadingToS3"),
 		slog.String("videoId", video.VideoID),
 	)
 
 	// Upload to S3 and delete local text file
-	transcriptionURL, err = storagesvc.NewFile(ctx, video.ChannelID, localTextFile, fmt.Sprintf("%s.txt", video.VideoID))
+	transcriptionURL, err := s.svcs.Storage.NewFile(ctx, video.ChannelID, localTextFile, fmt.Sprintf("%s.txt", video.VideoID))
 	if err != nil {
-		errorStream <- err
-		transcriptionURL = service.InvalidURL
-		updateDb(datasvc, errorStream, video, jobType, &transcriptionURL)
 		return err
 	}
 
-	lgr.Logger.Debug("jobtranscription.Process",
-		slog.String("event", "updatingDb"),
+	source := data.TranscriptionSourceAudio
+	video.TranscriptionSource = &source
+	video.TranscriptionURL = &transcriptionURL
+	s.index(ctx, video, transcribedText, nil)
+	return nil
+}
+
+// processCaptions transcribes a single video from its Youtube captions.
+// It returns youtube.ErrNoCaptions if there is no acceptable caption track.
+func (s *stage) processCaptions(ctx context.Context, video *data.Video) error {
+	lgr.Logger.Debug("jobtranscription.processCaptions",
+		slog.String("event", "aboutToExtractCaptions"),
 		slog.String("videoId", video.VideoID),
-		slog.String("audioUrl", *video.AudioURL),
-		slog.String("transcriptionUrl", transcriptionURL),
 	)
 
-	// Update the video with transcription URL
-	updateDb(datasvc, errorStream, video, jobType, &transcriptionURL)
+	captions, err := s.svcs.Youtube.ExtractCaptions(ctx, video.VideoID, video.VideoURL)
+	if err != nil {
+		return err
+	}
+
+	// Save the captions as plain text (i.e. the transcription) and as timestamped subtitles
+	localTextFile, err := saveToFile(youtube.FormatCaptionsText(captions), s.svcs.Config.GetLocalTranscriptionFolder(), fmt.Sprintf("%s.txt", video.VideoID))
+	if err != nil {
+		return err
+	}
+
+	localSubtitlesFile, err := saveToFile(youtube.FormatCaptionsSRT(captions), s.svcs.Config.GetLocalTranscriptionFolder(), fmt.Sprintf("%s.srt", video.VideoID))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// Delete local files
+		// Ignore errors because they may have been deleted already
+		_ = os.Remove(localTextFile)
+		_ = os.Remove(localSubtitlesFile)
+	}()
+
+	transcriptionURL, err := s.svcs.Storage.NewFile(ctx, video.ChannelID, localTextFile, fmt.Sprintf("%s.txt", video.VideoID))
+	if err != nil {
+		return err
+	}
+
+	subtitlesURL, err := s.svcs.Storage.NewFile(ctx, video.ChannelID, localSubtitlesFile, fmt.Sprintf("%s.srt", video.VideoID))
+	if err != nil {
+		return err
+	}
+
+	source := data.TranscriptionSourceCaptions
+	video.TranscriptionSource = &source
+	video.TranscriptionURL = &transcriptionURL
+	video.SubtitlesURL = &subtitlesURL
+	s.index(ctx, video, "", captions)
 	return nil
 }
 
+// index makes the transcript searchable. Failing to index does not fail the transcription:
+// the indexing job picks up the transcribed videos that are not indexed.
+func (s *stage) index(ctx context.Context, video *data.Video, text string, captions []youtube.Caption) {
+	err := jobindexing.Index(ctx, s.svcs, video, text, captions)
+	if err != nil {
+		lgr.Logger.Warn("jobtranscription.index",
+			slog.String("videoId", video.VideoID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
 func saveToFile(text, folder, fileName string) (string, error) {
 	// Ensure the directory exists
 	err := os.MkdirAll(folder, os.ModePerm)
@@ -234,14 +322,3 @@ func saveToFile(text, folder, fileName string) (string, error) {
 
 	return filePath, nil
 }
-
-func updateDb(datasvc data.IService, errorStream chan error, video *data.Video, jobType data.JobType, url *string) {
-	// Update the video with transcription URL
-	now := time.Now()
-	video.TranscriptionURL = url
-	video.TranscribedAt = &now
-	err := datasvc.UpdateVideo(video, jobType)
-	if err != nil {
-		errorStream <- err
-	}
-}
@@ -2,26 +2,84 @@ package job
 
 import (
 	"context"
+	"errors"
 
 	"github.com/khaledhikmat/yt-extractor/service/audio"
 	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
 	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/data"
+	"github.com/khaledhikmat/yt-extractor/service/embedding"
 	"github.com/khaledhikmat/yt-extractor/service/storage"
 	"github.com/khaledhikmat/yt-extractor/service/transcription"
 	"github.com/khaledhikmat/yt-extractor/service/youtube"
 )
 
+// ErrSkipped is returned by a stage that leaves a video alone (i.e. it is neither a success nor an error)
+var ErrSkipped = errors.New("video skipped")
+
+// ErrCancelled wraps the process error of a video interrupted by the job cancellation. The video is
+// persisted without cancellation so stages check it (i.e. errors.Is) to restore the video instead.
+var ErrCancelled = errors.New("video cancelled")
+
+// Services are the services available to job processors
+type Services struct {
+	Config        config.IService
+	Data          data.IService
+	Youtube       youtube.IService
+	Audio         audio.IService
+	Storage       storage.IService
+	CloudConvert  cloudconvert.IService
+	Transcription transcription.IService
+	Embedding     embedding.IService
+}
+
 // Signature of job processors
 type Processor func(ctx context.Context,
 	channelID string,
 	jobId int64,
 	pageSize int,
 	errorStream chan error,
-	cfgsvc config.IService,
-	datasvc data.IService,
-	ytsvc youtube.IService,
-	audioSvc audio.IService,
-	storagesvc storage.IService,
-	cloudconvertsvc cloudconvert.IService,
-	transcriptionsvc transcription.IService)
+	svcs Services)
+
+// Stage is a step of the video pipeline. The runner (i.e. Run) takes care of the job
+// lifecycle, cancellation, counters, tracing and the per-video ledger.
+type Stage interface {
+	// Select returns the candidate videos of the job
+	Select(ctx context.Context, job *data.Job, pageSize int) ([]data.Video, error)
+	// Process processes a single video. It returns ErrSkipped to leave the video alone.
+	Process(ctx context.Context, job *data.Job, video *data.Video) error
+	// Persist records the outcome of processing a single video
+	Persist(ctx context.Context, job *data.Job, video *data.Video, processErr error) error
+}
+
+// Concurrent is implemented by stages that process several videos at once
+type Concurrent interface {
+	Workers() int
+}
+
+// Finisher is implemented by stages that act once all the videos are processed.
+// It is not called if the job is cancelled.
+type Finisher interface {
+	Finish(ctx context.Context, job *data.Job) error
+}
+
+// Follower is implemented by stages that take over a video as soon as
+// a previous pipeline stage is done with it
+type Follower interface {
+	Accepts(job *data.Job, video *data.Video) bool
+}
+
+// Node is a stage of a pipeline
+type Node struct {
+	// Name identifies the stage in the pipeline and in the job per-stage counts
+	Name  string
+	Stage Stage
+	// Types are the job types the stage selects its candidates with (i.e. fresh and re-attempted videos).
+	// The videos are processed with the job type they are selected with. The handed over videos
+	// are processed with the first one.
+	Types []data.JobType
+	// After are the stages that hand their videos over to this stage
+	After []string
+	// Workers is the number of videos the stage processes concurrently
+	Workers int
+}
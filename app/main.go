@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -20,12 +21,15 @@ import (
 	"go.opentelemetry.io/otel/sdk/trace"
 	nooptrace "go.opentelemetry.io/otel/trace/noop"
 
+	jobs "github.com/khaledhikmat/yt-extractor/job"
 	"github.com/khaledhikmat/yt-extractor/server"
 	"github.com/khaledhikmat/yt-extractor/service/audio"
 	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
 	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/data"
+	"github.com/khaledhikmat/yt-extractor/service/embedding"
 	"github.com/khaledhikmat/yt-extractor/service/lgr"
+	"github.com/khaledhikmat/yt-extractor/service/llm"
 	"github.com/khaledhikmat/yt-extractor/service/storage"
 	"github.com/khaledhikmat/yt-extractor/service/transcription"
 	"github.com/khaledhikmat/yt-extractor/service/youtube"
@@ -76,12 +80,74 @@ func main() {
 
 	// Create Services
 	configSvc := config.New()
+	err := configSvc.Validate()
+	if err != nil {
+		lgr.Logger.Error(
+			"validating the configuration",
+			slog.Any("error", xerrors.New(err.Error())),
+		)
+		os.Exit(1)
+	}
+
 	dataSvc := data.New(configSvc)
 	youtubeSvc := youtube.New(configSvc)
 	audioSvc := audio.New(configSvc)
 	storageSvc := storage.New(configSvc)
 	cloudConvertSvc := cloudconvert.New(configSvc)
 	transcriptionSvc := transcription.New(configSvc)
+	embeddingSvc := embedding.New(configSvc)
+	llmSvc := llm.New(configSvc)
+	jobSvcs := jobs.Services{
+		Config:        configSvc,
+		Data:          dataSvc,
+		Youtube:       youtubeSvc,
+		Audio:         audioSvc,
+		Storage:       storageSvc,
+		CloudConvert:  cloudConvertSvc,
+		Transcription: transcriptionSvc,
+		Embedding:     embeddingSvc,
+	}
+
+	// Run the schema migrations subcommand (i.e. migrate up|down|status|baseline <version>) and exit
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := migrate(canxCtx, dataSvc, os.Args[2:])
+		if err != nil {
+			lgr.Logger.Error(
+				"migrating the database",
+				slog.Any("error", xerrors.New(err.Error())),
+			)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Fail fast if the database schema drifted from (or is behind) the binary
+	if configSvc.IsAutoMigrate() {
+		migrations, err := dataSvc.MigrateUp(canxCtx)
+		for _, migration := range migrations {
+			lgr.Logger.Info(
+				"applied migration",
+				slog.Int64("version", migration.Version),
+				slog.String("name", migration.Name),
+			)
+		}
+		if err != nil {
+			lgr.Logger.Error(
+				"migrating the database",
+				slog.Any("error", xerrors.New(err.Error())),
+			)
+			os.Exit(1)
+		}
+	} else {
+		err := dataSvc.CheckMigrations(canxCtx)
+		if err != nil {
+			lgr.Logger.Error(
+				"checking the database migrations",
+				slog.Any("error", xerrors.New(err.Error())),
+			)
+			os.Exit(1)
+		}
+	}
 
 	// Setup OpenTelemetry
 	shutdown, err := setupOpenTelemetry(rootCtx, configSvc)
@@ -105,13 +171,30 @@ func main() {
 	// Print the extractor version
 	_ = youtubeSvc.PrintExtractorVersion()
 
+	// Restore the most recent cookies so they survive container rebuilds
+	cookies, err := dataSvc.RetrieveCookies(canxCtx)
+	if err != nil {
+		lgr.Logger.Error(
+			"retrieving cookies",
+			slog.Any("error", xerrors.New(err.Error())),
+		)
+	} else if cookies != nil {
+		err = youtubeSvc.SetCookies(cookies)
+		if err != nil {
+			lgr.Logger.Error(
+				"setting cookies",
+				slog.Any("error", xerrors.New(err.Error())),
+			)
+		}
+	}
+
 	// Create an error stream
 	errorStream := make(chan error)
 	defer close(errorStream)
 
 	// Run the http server
 	go func() {
-		err = server.Run(canxCtx, errorStream, configSvc, dataSvc, youtubeSvc, audioSvc, storageSvc, cloudConvertSvc, transcriptionSvc)
+		err = server.Run(canxCtx, errorStream, configSvc, dataSvc, youtubeSvc, audioSvc, storageSvc, cloudConvertSvc, transcriptionSvc, embeddingSvc, llmSvc)
 		if err != nil {
 			errorStream <- err
 		}
@@ -142,7 +225,19 @@ func main() {
 					ChannelID: configSvc.GetExtractionChannelID(),
 					Type:      data.JobTypeExtraction,
 				}
-				_, err = server.ProcessJob(canxCtx, job, 10, false, errorStream, configSvc, dataSvc, youtubeSvc, audioSvc, storageSvc, cloudConvertSvc, transcriptionSvc)
+				_, err := server.ProcessJob(canxCtx, job, 10, false, errorStream, jobSvcs)
+				if errors.Is(err, data.ErrJobConflict) {
+					// Another instance is extracting the channel so wait for it before retrying
+					lgr.Logger.Info(
+						"contineous extraction skipped",
+						slog.String("reason", err.Error()),
+					)
+					select {
+					case <-canxCtx.Done():
+					case <-time.After(time.Minute):
+					}
+					continue
+				}
 				if err != nil {
 					errorStream <- err
 				}
@@ -170,13 +265,21 @@ func main() {
 				ChannelID: configSvc.GetExtractionChannelID(),
 				Type:      data.JobTypeExtraction,
 			}
-			_, err = server.ProcessJob(canxCtx, job, 10, true, errorStream, configSvc, dataSvc, youtubeSvc, audioSvc, storageSvc, cloudConvertSvc, transcriptionSvc)
+			_, err = server.ProcessJob(canxCtx, job, 10, true, errorStream, jobSvcs)
+			if errors.Is(err, data.ErrJobConflict) {
+				// The previous period's extraction is still running
+				lgr.Logger.Info(
+					"periodic extraction skipped",
+					slog.String("reason", err.Error()),
+				)
+				break
+			}
 			if err != nil {
 				errorStream <- err
 			}
 		case e := <-errorStream:
 			// Add error table to the database
-			err := dataSvc.NewError("main", e.Error())
+			err := dataSvc.NewError(canxCtx, "main", e.Error())
 			if err != nil {
 				lgr.Logger.Error(
 					"error saving error to database",
@@ -229,6 +332,50 @@ resume:
 	}
 }
 
+// migrate runs the schema migrations subcommand
+func migrate(ctx context.Context, dataSvc data.IService, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down|status|baseline <version>")
+	}
+
+	switch args[0] {
+	case "up":
+		migrations, err := dataSvc.MigrateUp(ctx)
+		for _, migration := range migrations {
+			fmt.Printf("applied %04d %s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "down":
+		migration, err := dataSvc.MigrateDown(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %04d %s\n", migration.Version, migration.Name)
+		return nil
+	case "status":
+		migrations, err := dataSvc.RetrieveMigrations(ctx)
+		for _, migration := range migrations {
+			appliedAt := "pending"
+			if migration.AppliedAt != nil {
+				appliedAt = migration.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%04d %-40s %s\n", migration.Version, migration.Name, appliedAt)
+		}
+		return err
+	case "baseline":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: migrate baseline <version>")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("baseline version %s is invalid", args[1])
+		}
+		return dataSvc.BaselineMigrations(ctx, version)
+	default:
+		return fmt.Errorf("usage: migrate up|down|status|baseline <version>")
+	}
+}
+
 // Reference:
 // https://cloud.google.com/stackdriver/docs/instrumentation/setup/go
 // setupOpenTelemetry sets up the OpenTelemetry SDK and exporters for metrics and
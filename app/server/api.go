@@ -2,34 +2,55 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/khaledhikmat/yt-extractor/service"
 	"github.com/khaledhikmat/yt-extractor/service/audio"
 	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
 	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/data"
+	"github.com/khaledhikmat/yt-extractor/service/embedding"
+	"github.com/khaledhikmat/yt-extractor/service/llm"
 	"github.com/khaledhikmat/yt-extractor/service/storage"
 	"github.com/khaledhikmat/yt-extractor/service/transcription"
 	"github.com/khaledhikmat/yt-extractor/service/youtube"
 
-	"github.com/khaledhikmat/yt-extractor/job"
+	jobs "github.com/khaledhikmat/yt-extractor/job"
 	jobattributes "github.com/khaledhikmat/yt-extractor/job/attributes"
 	jobaudio "github.com/khaledhikmat/yt-extractor/job/audio"
 	jobautomation "github.com/khaledhikmat/yt-extractor/job/automation"
+	jobembedding "github.com/khaledhikmat/yt-extractor/job/embedding"
 	jobextraction "github.com/khaledhikmat/yt-extractor/job/extraction"
+	jobindexing "github.com/khaledhikmat/yt-extractor/job/indexing"
+	jobshorts "github.com/khaledhikmat/yt-extractor/job/shorts"
 	jobtranscription "github.com/khaledhikmat/yt-extractor/job/transcription"
 )
 
 const (
 	version = "1.0.0"
+
+	// progressStreamInterval is how often the job progress is pushed to the stream
+	progressStreamInterval = 2 * time.Second
+
+	// pingTimeout bounds the database ping of the health check
+	pingTimeout = 2 * time.Second
+
+	// maxVideoPageSize bounds the page size of the filtered videos
+	maxVideoPageSize = 500
+
+	// maxSemanticPassages bounds the passages (i.e. k) of the semantic search so a request cannot return the vector cache
+	maxSemanticPassages = 100
+
+	// maxAskPassages bounds the passages (i.e. k) handed to the LLM so the prompt stays small
+	maxAskPassages = 20
 )
 
-var jobProcs = map[data.JobType]job.Processor{
+var jobProcs = map[data.JobType]jobs.Processor{
 	data.JobTypeAttributes:         jobattributes.Processor,
 	data.JobTypeExtraction:         jobextraction.Processor,
 	data.JobTypeExtractionError:    jobextraction.Processor,
@@ -37,7 +58,11 @@ var jobProcs = map[data.JobType]job.Processor{
 	data.JobTypeAudioError:         jobaudio.Processor,
 	data.JobTypeTranscription:      jobtranscription.Processor,
 	data.JobTypeTranscriptionError: jobtranscription.Processor,
+	data.JobTypeCaptions:           jobtranscription.Processor,
 	data.JobTypeAutomation:         jobautomation.Processor,
+	data.JobTypeShorts:             jobshorts.Processor,
+	data.JobTypeIndexing:           jobindexing.Processor,
+	data.JobTypeEmbedding:          jobembedding.Processor,
 }
 
 func apiRoutes(ctx context.Context,
@@ -49,9 +74,33 @@ func apiRoutes(ctx context.Context,
 	audiosvc audio.IService,
 	storagesvc storage.IService,
 	cloudconvertsvc cloudconvert.IService,
-	transcriptionsvc transcription.IService) {
+	transcriptionsvc transcription.IService,
+	embeddingsvc embedding.IService,
+	llmsvc llm.IService) {
+	svcs := jobs.Services{
+		Config:        cfgsvc,
+		Data:          datasvc,
+		Youtube:       ytsvc,
+		Audio:         audiosvc,
+		Storage:       storagesvc,
+		CloudConvert:  cloudconvertsvc,
+		Transcription: transcriptionsvc,
+		Embedding:     embeddingsvc,
+	}
 
 	r.GET("/ping", func(c *gin.Context) {
+		// The health check fails if the database is not reachable
+		pingCtx, cancel := context.WithTimeout(c.Request.Context(), pingTimeout)
+		defer cancel()
+
+		err := datasvc.Ping(pingCtx)
+		if err != nil {
+			c.JSON(503, gin.H{
+				"message": fmt.Sprintf("database ping produced %s", err.Error()),
+			})
+			return
+		}
+
 		c.JSON(200, gin.H{
 			"message": fmt.Sprintf("version: %s - env: %s", version, cfgsvc.GetRuntimeEnvironment()),
 		})
@@ -66,7 +115,7 @@ func apiRoutes(ctx context.Context,
 			return
 		}
 
-		err := datasvc.ResetFactory()
+		err := datasvc.ResetFactory(c.Request.Context())
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("reset factory produced %s", err.Error()),
@@ -79,6 +128,53 @@ func apiRoutes(ctx context.Context,
 		})
 	})
 
+	// Upload the yt-dlp cookies (Netscape format) after validating them with a test fetch
+	r.POST("/admins/cookies", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		content, err := c.GetRawData()
+		if err != nil || len(content) == 0 {
+			c.JSON(400, gin.H{
+				"message": "cookies are required",
+			})
+			return
+		}
+
+		err = ytsvc.ValidateCookies(c.Request.Context(), content)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("validate cookies produced %s", err.Error()),
+			})
+			return
+		}
+
+		err = datasvc.NewCookies(c.Request.Context(), content)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("store cookies produced %s", err.Error()),
+			})
+			return
+		}
+
+		err = ytsvc.SetCookies(content)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("set cookies produced %s", err.Error()),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": nil,
+		})
+	})
+
 	r.GET("/videos", func(c *gin.Context) {
 		isPermitted := isPermitted(c, datasvc)
 		if !isPermitted {
@@ -117,7 +213,7 @@ func apiRoutes(ctx context.Context,
 		}
 
 		//jobType := c.Query("t")
-		videos, err := datasvc.RetrieveVideos(channelID, page, pageSize, order, dir)
+		videos, err := datasvc.RetrieveVideos(c.Request.Context(), channelID, page, pageSize, order, dir)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve videos produced %s", err.Error()),
@@ -130,6 +226,36 @@ func apiRoutes(ctx context.Context,
 		})
 	})
 
+	r.GET("/videos/query", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		filter, err := parseVideoFilter(c)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		page, err := datasvc.QueryVideos(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("query videos produced %s", err.Error()),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": page,
+		})
+	})
+
 	r.GET("/videos/unextracted", func(c *gin.Context) {
 		isPermitted := isPermitted(c, datasvc)
 		if !isPermitted {
@@ -152,7 +278,7 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		videos, err := datasvc.RetrieveUnextractedVideos(channelID, pageSize)
+		videos, err := datasvc.RetrieveUnextractedVideos(c.Request.Context(), channelID, pageSize)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve unextracted videos produced %s", err.Error()),
@@ -187,7 +313,7 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		videos, err := datasvc.RetrieveExtractErroredVideos(channelID, pageSize)
+		videos, err := datasvc.RetrieveExtractErroredVideos(c.Request.Context(), channelID, pageSize)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve extract errored videos produced %s", err.Error()),
@@ -200,6 +326,102 @@ func apiRoutes(ctx context.Context,
 		})
 	})
 
+	r.GET("/videos/deadlettered", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		channelID := c.Query("c")
+		if channelID == "" {
+			c.JSON(400, gin.H{
+				"message": "channel ID is required",
+			})
+			return
+		}
+
+		pageSize, e := strconv.Atoi(c.Query("s"))
+		if e != nil {
+			pageSize = 50
+		}
+
+		videos, err := datasvc.RetrieveDeadLetteredVideos(c.Request.Context(), channelID, pageSize)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("retrieve dead lettered videos produced %s", err.Error()),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": videos,
+		})
+	})
+
+	// Requeue a dead-lettered video stage (i.e. extraction, audio, transcription or embedding) so it is attempted again
+	r.POST("/videos/requeue", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		id, err := strconv.Atoi(c.Query("i"))
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("invalid id: %s", err.Error()),
+			})
+			return
+		}
+
+		video, err := datasvc.RetrieveVideoByID(c.Request.Context(), int64(id))
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("Retrieving video id %d caused error: %s", id, err.Error()),
+			})
+			return
+		}
+
+		stage := data.Stage(c.Query("t"))
+		statuses := map[data.Stage]data.StageStatus{
+			data.StageExtraction:    video.ExtractionStatus,
+			data.StageAudio:         video.AudioStatus,
+			data.StageTranscription: video.TranscriptionStatus,
+			data.StageEmbedding:     video.EmbeddingStatus,
+		}
+		status, ok := statuses[stage]
+		if !ok {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("invalid stage: %s", stage),
+			})
+			return
+		}
+
+		if status != data.StageStatusDeadLetter {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("video %d %s is %s and not dead lettered", id, stage, status),
+			})
+			return
+		}
+
+		err = datasvc.RequeueVideo(c.Request.Context(), &video, stage)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("Requeuing video %d caused error: %s", id, err.Error()),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": nil,
+		})
+	})
+
 	r.GET("/videos/unexternalized", func(c *gin.Context) {
 		isPermitted := isPermitted(c, datasvc)
 		if !isPermitted {
@@ -222,7 +444,7 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		videos, err := datasvc.RetrieveUnexternalizedVideos(channelID, pageSize)
+		videos, err := datasvc.RetrieveUnexternalizedVideos(c.Request.Context(), channelID, pageSize)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve unexternalized videos produced %s", err.Error()),
@@ -257,7 +479,7 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		videos, err := datasvc.RetrieveUnaudioedVideos(channelID, pageSize)
+		videos, err := datasvc.RetrieveUnaudioedVideos(c.Request.Context(), channelID, pageSize)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve unaudioed videos produced %s", err.Error()),
@@ -292,7 +514,7 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		videos, err := datasvc.RetrieveAudioErroredVideos(channelID, pageSize)
+		videos, err := datasvc.RetrieveAudioErroredVideos(c.Request.Context(), channelID, pageSize)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve audio errored videos produced %s", err.Error()),
@@ -327,7 +549,7 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		videos, err := datasvc.RetrieveUntranscribedVideos(channelID, pageSize)
+		videos, err := datasvc.RetrieveUntranscribedVideos(c.Request.Context(), channelID, pageSize)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve untranscribed videos produced %s", err.Error()),
@@ -362,7 +584,7 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		videos, err := datasvc.RetrieveTranscribeErroredVideos(channelID, pageSize)
+		videos, err := datasvc.RetrieveTranscribeErroredVideos(c.Request.Context(), channelID, pageSize)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve transcribe errored videos produced %s", err.Error()),
@@ -397,7 +619,7 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		videos, err := datasvc.RetrieveUpdatedVideos(channelID, pageSize)
+		videos, err := datasvc.RetrieveUpdatedVideos(c.Request.Context(), channelID, pageSize)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve updated videos produced %s", err.Error()),
@@ -410,6 +632,226 @@ func apiRoutes(ctx context.Context,
 		})
 	})
 
+	r.GET("/videos/stats", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		id, err := strconv.Atoi(c.Query("i"))
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("invalid id: %s", err.Error()),
+			})
+			return
+		}
+
+		days, e := strconv.Atoi(c.Query("d"))
+		if e != nil {
+			days = 30
+		}
+
+		stats, err := datasvc.RetrieveVideoStats(c.Request.Context(), int64(id), days)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("retrieve video stats produced %s", err.Error()),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": stats,
+		})
+	})
+
+	r.GET("/search", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		text := c.Query("q")
+		if text == "" {
+			c.JSON(400, gin.H{
+				"message": "search text is required",
+			})
+			return
+		}
+
+		pageSize, e := strconv.Atoi(c.Query("s"))
+		if e != nil {
+			pageSize = 20
+		}
+
+		// The channel is optional so the search can span all the channels
+		results, err := datasvc.SearchTranscripts(c.Request.Context(), c.Query("c"), text, pageSize)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("search transcripts produced %s", err.Error()),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": results,
+		})
+	})
+
+	r.GET("/search/semantic", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		text := c.Query("q")
+		if text == "" {
+			c.JSON(400, gin.H{
+				"message": "search text is required",
+			})
+			return
+		}
+
+		k, e := strconv.Atoi(c.Query("k"))
+		if e != nil || k <= 0 {
+			k = 10
+		}
+
+		if k > maxSemanticPassages {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("k must be at most %d", maxSemanticPassages),
+			})
+			return
+		}
+
+		// The embedding provider is an upstream service so its failures are bad gateways
+		vectors, err := embeddingsvc.Embed(c.Request.Context(), []string{text})
+		if err != nil {
+			c.JSON(502, gin.H{
+				"message": fmt.Sprintf("embed search text produced %s", err.Error()),
+			})
+			return
+		}
+
+		// The channel is optional so the search can span all the channels
+		passages, err := datasvc.SemanticSearch(c.Request.Context(), c.Query("c"), embeddingsvc.Model(), vectors[0], k)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("semantic search produced %s", err.Error()),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": passages,
+		})
+	})
+
+	r.POST("/channels/:id/ask", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		var question data.Question
+		if err := c.ShouldBindJSON(&question); err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("bind question produced %s", err.Error()),
+			})
+			return
+		}
+
+		if question.Question == "" {
+			c.JSON(400, gin.H{
+				"message": "question is required",
+			})
+			return
+		}
+
+		if question.K <= 0 {
+			question.K = 8
+		}
+
+		if question.K > maxAskPassages {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("k must be at most %d", maxAskPassages),
+			})
+			return
+		}
+
+		// The embedding and LLM providers are upstream services so their failures are bad gateways
+		vectors, err := embeddingsvc.Embed(c.Request.Context(), []string{question.Question})
+		if err != nil {
+			c.JSON(502, gin.H{
+				"message": fmt.Sprintf("embed question produced %s", err.Error()),
+			})
+			return
+		}
+
+		passages, err := datasvc.SemanticSearch(c.Request.Context(), c.Param("id"), embeddingsvc.Model(), vectors[0], question.K)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("semantic search produced %s", err.Error()),
+			})
+			return
+		}
+
+		if len(passages) == 0 {
+			c.JSON(404, gin.H{
+				"message": "channel has no embedded transcripts",
+			})
+			return
+		}
+
+		sources := []llm.Source{}
+		for _, passage := range passages {
+			source := llm.Source{
+				Title: passage.Title,
+				Text:  passage.Text,
+			}
+			if passage.Start != nil {
+				source.Label = (time.Duration(*passage.Start) * time.Second).String()
+			}
+			sources = append(sources, source)
+		}
+
+		reply, err := llmsvc.Chat(c.Request.Context(), llm.AnswerPrompt(question.Question, sources))
+		if err != nil {
+			c.JSON(502, gin.H{
+				"message": fmt.Sprintf("answer question produced %s", err.Error()),
+			})
+			return
+		}
+
+		// The citations keep the numbers that the answer refers to
+		answer := data.Answer{
+			Question:  question.Question,
+			Answer:    reply,
+			Citations: []data.Citation{},
+		}
+		for _, i := range llm.CitedSources(reply, len(passages)) {
+			answer.Citations = append(answer.Citations, data.Citation{
+				Number:  i + 1,
+				Passage: passages[i],
+			})
+		}
+
+		c.JSON(200, gin.H{
+			"data": answer,
+		})
+	})
+
 	r.GET("/jobs", func(c *gin.Context) {
 		isPermitted := isPermitted(c, datasvc)
 		if !isPermitted {
@@ -435,7 +877,7 @@ func apiRoutes(ctx context.Context,
 			return
 		}
 
-		job, err := datasvc.RetrieveJobByID(int64(id))
+		job, err := datasvc.RetrieveJobByID(c.Request.Context(), int64(id))
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("retrieve job produced %s", err.Error()),
@@ -448,6 +890,85 @@ func apiRoutes(ctx context.Context,
 		})
 	})
 
+	r.GET("/jobs/:id", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		id, e := strconv.Atoi(c.Param("id"))
+		if e != nil {
+			c.JSON(400, gin.H{
+				"message": "job ID could not be parsed",
+			})
+			return
+		}
+
+		job, err := datasvc.RetrieveJobByID(c.Request.Context(), int64(id))
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": fmt.Sprintf("retrieve job produced %s", err.Error()),
+			})
+			return
+		}
+
+		c.JSON(200, gin.H{
+			"data": gin.H{
+				"job":      job,
+				"progress": ytsvc.RetrieveExtractionProgress(job.ID),
+			},
+		})
+	})
+
+	// Stream the job progress as Server-Sent Events until the job is no longer active
+	r.GET("/jobs/:id/progress", func(c *gin.Context) {
+		isPermitted := isPermitted(c, datasvc)
+		if !isPermitted {
+			c.JSON(403, gin.H{
+				"message": "Invalid or missing API key",
+			})
+			return
+		}
+
+		id, e := strconv.Atoi(c.Param("id"))
+		if e != nil {
+			c.JSON(400, gin.H{
+				"message": "job ID could not be parsed",
+			})
+			return
+		}
+
+		ticker := time.NewTicker(progressStreamInterval)
+		defer ticker.Stop()
+
+		c.Stream(func(_ io.Writer) bool {
+			job, err := datasvc.RetrieveJobByID(c.Request.Context(), int64(id))
+			if err != nil {
+				c.SSEvent("error", fmt.Sprintf("retrieve job produced %s", err.Error()))
+				return false
+			}
+
+			c.SSEvent("progress", gin.H{
+				"job":      job,
+				"progress": ytsvc.RetrieveExtractionProgress(job.ID),
+			})
+
+			if job.State != data.JobStateQueued && job.State != data.JobStateRunning {
+				return false
+			}
+
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-ticker.C:
+				return true
+			}
+		})
+	})
+
 	r.POST("/jobs", func(c *gin.Context) {
 		isPermitted := isPermitted(c, datasvc)
 		if !isPermitted {
@@ -484,7 +1005,13 @@ func apiRoutes(ctx context.Context,
 			pageSize = 50
 		}
 
-		id, err := ProcessJob(ctx, job, pageSize, true, errorStream, cfgsvc, datasvc, ytsvc, audiosvc, storagesvc, cloudconvertsvc, transcriptionsvc)
+		id, err := ProcessJob(ctx, job, pageSize, true, errorStream, svcs)
+		if errors.Is(err, data.ErrJobConflict) {
+			c.JSON(409, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("process job produced %s", err.Error()),
@@ -515,7 +1042,7 @@ func apiRoutes(ctx context.Context,
 		}
 
 		// The only thing we allow clients to update is to set the externalization
-		video, err := datasvc.RetrieveVideoByID(int64(id))
+		video, err := datasvc.RetrieveVideoByID(c.Request.Context(), int64(id))
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("Retrieving video id %d caused error: %s", id, err.Error()),
@@ -523,7 +1050,7 @@ func apiRoutes(ctx context.Context,
 			return
 		}
 
-		err = datasvc.UpdateVideo(&video, data.JobTypeExternalization)
+		err = datasvc.UpdateVideo(c.Request.Context(), &video, data.JobTypeExternalization)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("Updating video %d caused error: %s", id, err.Error()),
@@ -616,7 +1143,7 @@ func apiRoutes(ctx context.Context,
 		}
 
 		// Force an initial state
-		err := datasvc.NewError(thisError.Source, thisError.Body)
+		err := datasvc.NewError(c.Request.Context(), thisError.Source, thisError.Body)
 		if err != nil {
 			c.JSON(400, gin.H{
 				"message": fmt.Sprintf("new error produced %s", err.Error()),
@@ -693,24 +1220,25 @@ func apiRoutes(ctx context.Context,
 
 		channelID := tagParts[0]
 		videoID := tagParts[1]
-		url := service.InvalidURL
 		if status == "job.finished" {
 			// URL must be generated from the bucket name and the folder
-			url = constructStorageURL(cfgsvc, channelID, videoID)
+			url := constructStorageURL(cfgsvc, channelID, videoID)
 			// Guard against multiple webhook posts by making sure that we
-			// return an error if the audio URL is not expected
-			video, err := updateDb(datasvc, errorStream, channelID, videoID, &url)
+			// return an error if the audio is not waiting for the webhook
+			video, err := updateDb(c.Request.Context(), datasvc, errorStream, channelID, videoID, url, nil)
 			if err == nil {
 				// Start an asynchronous transcription processor to process the just-completed audio
 				go func() {
-					// Ignore the error because it has already been streamed to the error stream
-					_ = jobtranscription.Process(ctx, &video, errorStream, data.JobTypeTranscription, cfgsvc, datasvc, audiosvc, storagesvc, transcriptionsvc)
+					err := jobtranscription.Transcribe(ctx, &video, svcs)
+					if err != nil {
+						errorStream <- err
+					}
 				}()
 			}
 		} else if status == "job.failed" {
-			url = service.InvalidURL
-			errorStream <- fmt.Errorf("cloudconvert webhook - job failed for channel %s and video %s", channelID, videoID)
-			_, _ = updateDb(datasvc, errorStream, channelID, videoID, &url)
+			err := fmt.Errorf("cloudconvert webhook - job failed for channel %s and video %s", channelID, videoID)
+			errorStream <- err
+			_, _ = updateDb(c.Request.Context(), datasvc, errorStream, channelID, videoID, "", err)
 		}
 
 		// If the job status is not finished and is not failed, do not do anything.
@@ -721,29 +1249,33 @@ func apiRoutes(ctx context.Context,
 	})
 }
 
-func updateDb(datasvc data.IService, errorStream chan error, channelID, videoID string, URL *string) (data.Video, error) {
-	fmt.Printf("Cloudconvert webhook - updateDb - channel ID: %s, video ID: %s - URL: %s\n", channelID, videoID, *URL)
-	video, err := datasvc.RetrieveVideoByIDs(channelID, videoID)
+func updateDb(ctx context.Context, datasvc data.IService, errorStream chan error, channelID, videoID string, URL string, conversionErr error) (data.Video, error) {
+	fmt.Printf("Cloudconvert webhook - updateDb - channel ID: %s, video ID: %s - URL: %s\n", channelID, videoID, URL)
+	video, err := datasvc.RetrieveVideoByIDs(ctx, channelID, videoID)
 	if err != nil {
 		errorStream <- err
 		return video, err
 	}
 
-	if video.AudioURL == nil {
-		errorStream <- fmt.Errorf("audio URL should not be nil")
-		return video, fmt.Errorf("audio URL should not be nil")
+	if video.AudioStatus != data.StageStatusWaitingExternal {
+		errorStream <- fmt.Errorf("audio status %s is not in waiting state", video.AudioStatus)
+		return video, fmt.Errorf("audio status %s is not in waiting state", video.AudioStatus)
 	}
 
-	if *video.AudioURL != service.AcceptedURL {
-		errorStream <- fmt.Errorf("audio URL %s is not in waiting state", *video.AudioURL)
-		return video, fmt.Errorf("audio URL %s is not in waiting state", *video.AudioURL)
-	}
-
-	// Update the video with audio URL
+	// Update the video with audio URL if successful or with the conversion error
 	now := time.Now()
-	video.AudioURL = URL
+	video.AudioURL = &URL
 	video.AudioedAt = &now
-	err = datasvc.UpdateVideo(&video, data.JobTypeAudio)
+	video.AudioStatus = data.StageStatusSucceeded
+	video.AudioError = nil
+	if conversionErr != nil {
+		message := conversionErr.Error()
+		video.AudioURL = nil
+		video.AudioStatus = data.StageStatusFailed
+		video.AudioError = &message
+	}
+
+	err = datasvc.UpdateVideo(ctx, &video, data.JobTypeAudio)
 	if err != nil {
 		errorStream <- err
 		return video, err
@@ -758,13 +1290,9 @@ func ProcessJob(ctx context.Context,
 	pageSize int,
 	async bool,
 	errorStream chan error,
-	cfgsvc config.IService,
-	datasvc data.IService,
-	ytsvc youtube.IService,
-	audiosvc audio.IService,
-	storagesvc storage.IService,
-	cloudconvertsvc cloudconvert.IService,
-	transcriptionsvc transcription.IService) (int64, error) {
+	svcs jobs.Services) (int64, error) {
+	datasvc := svcs.Data
+
 	fmt.Printf("Processing job %s for channel %s\n", job.Type, job.ChannelID)
 	// Validate there is a processor for the job type
 	proc, ok := jobProcs[job.Type]
@@ -772,29 +1300,21 @@ func ProcessJob(ctx context.Context,
 		return -1, fmt.Errorf("job type %s does not have a processor", job.Type)
 	}
 
-	// Check to make sure there is no existing job for the same type and channel
-	isPending, err := datasvc.IsPendingJobsByTypeNChannel(job.ChannelID, job.Type)
-	if err != nil {
-		return -1, fmt.Errorf("is pending jobs by type and channel produced %s", err.Error())
-	}
-	if isPending {
-		return -1, fmt.Errorf("job type %s for channel %s is already pending", job.Type, job.ChannelID)
-	}
-
-	// Force an initial state
+	// Force an initial state. The database refuses a second queued or running job
+	// for the same type and channel so racing requests cannot both start one.
 	job.State = data.JobStateQueued
 	job.StartedAt = time.Now()
-	id, err := datasvc.NewJob(job)
+	id, err := datasvc.NewJob(ctx, job)
 	if err != nil {
-		return -1, fmt.Errorf("new job produced %s", err.Error())
+		return -1, fmt.Errorf("new job produced %w", err)
 	}
 
 	if async {
 		// Start the job processor asynchronously
-		go proc(ctx, job.ChannelID, id, pageSize, errorStream, cfgsvc, datasvc, ytsvc, audiosvc, storagesvc, cloudconvertsvc, transcriptionsvc)
+		go proc(ctx, job.ChannelID, id, pageSize, errorStream, svcs)
 	} else {
 		// Start the job processor synchronously
-		proc(ctx, job.ChannelID, id, pageSize, errorStream, cfgsvc, datasvc, ytsvc, audiosvc, storagesvc, cloudconvertsvc, transcriptionsvc)
+		proc(ctx, job.ChannelID, id, pageSize, errorStream, svcs)
 	}
 
 	return id, nil
@@ -806,7 +1326,7 @@ func isPermitted(c *gin.Context, datasvc data.IService) bool {
 		return false
 	}
 
-	isvalid, err := datasvc.IsAPIKeyValid(apiKey)
+	isvalid, err := datasvc.IsAPIKeyValid(c.Request.Context(), apiKey)
 	if err != nil {
 		return false
 	}
@@ -822,3 +1342,101 @@ func constructStorageURL(cfgsvc config.IService, channelID, videoID string) stri
 	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", cfgsvc.GetStorageBucket(), cfgsvc.GetStorageRegion(), fmt.Sprintf("%s/%s.mp3", channelID, videoID))
 
 }
+
+// parseVideoFilter reads the video filter from the query parameters.
+// The dates are either dates (i.e. 2025-01-01) or RFC3339 times and the durations are in seconds.
+func parseVideoFilter(c *gin.Context) (data.VideoFilter, error) {
+	filter := data.VideoFilter{
+		ChannelID:           c.Query("c"),
+		Title:               c.Query("title"),
+		ExtractionStatus:    data.StageStatus(c.Query("extractionStatus")),
+		AudioStatus:         data.StageStatus(c.Query("audioStatus")),
+		TranscriptionStatus: data.StageStatus(c.Query("transcriptionStatus")),
+		OrderBy:             c.Query("o"),
+		OrderDir:            c.Query("d"),
+		Cursor:              c.Query("cursor"),
+		PageSize:            50,
+	}
+
+	if filter.ChannelID == "" {
+		return filter, fmt.Errorf("channel ID is required")
+	}
+
+	var err error
+	if c.Query("s") != "" {
+		filter.PageSize, err = strconv.Atoi(c.Query("s"))
+		if err != nil {
+			return filter, fmt.Errorf("page size could not be parsed")
+		}
+	}
+	filter.PageSize = min(filter.PageSize, maxVideoPageSize)
+
+	if value := c.Query("publishedFrom"); value != "" {
+		date, _, err := parseFilterDate(value)
+		if err != nil {
+			return filter, fmt.Errorf("publishedFrom could not be parsed")
+		}
+		filter.PublishedFrom = &date
+	}
+
+	if value := c.Query("publishedTo"); value != "" {
+		date, dateOnly, err := parseFilterDate(value)
+		if err != nil {
+			return filter, fmt.Errorf("publishedTo could not be parsed")
+		}
+
+		// A date includes the whole day so the range ends before the next day
+		if dateOnly {
+			before := date.AddDate(0, 0, 1)
+			filter.PublishedBefore = &before
+		} else {
+			filter.PublishedTo = &date
+		}
+	}
+
+	for name, dest := range map[string]**int64{
+		"minDuration": &filter.MinDuration,
+		"maxDuration": &filter.MaxDuration,
+		"minViews":    &filter.MinViews,
+	} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("%s could not be parsed", name)
+		}
+		*dest = &n
+	}
+
+	for name, dest := range map[string]**bool{
+		"short":         &filter.Short,
+		"hasTranscript": &filter.HasTranscript,
+	} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("%s could not be parsed", name)
+		}
+		*dest = &b
+	}
+
+	return filter, nil
+}
+
+// parseFilterDate parses an RFC3339 time or a date (i.e. 2025-01-01) and tells which one it is
+func parseFilterDate(value string) (time.Time, bool, error) {
+	date, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return date, false, nil
+	}
+
+	date, err = time.Parse(time.DateOnly, value)
+	return date, true, err
+}
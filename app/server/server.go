@@ -12,7 +12,9 @@ import (
 	"github.com/khaledhikmat/yt-extractor/service/cloudconvert"
 	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/data"
+	"github.com/khaledhikmat/yt-extractor/service/embedding"
 	"github.com/khaledhikmat/yt-extractor/service/lgr"
+	"github.com/khaledhikmat/yt-extractor/service/llm"
 	"github.com/khaledhikmat/yt-extractor/service/storage"
 	"github.com/khaledhikmat/yt-extractor/service/transcription"
 	"github.com/khaledhikmat/yt-extractor/service/youtube"
@@ -52,7 +54,9 @@ func Run(canxCtx context.Context,
 	audiosvc audio.IService,
 	storagesvc storage.IService,
 	cloudconvertsvc cloudconvert.IService,
-	transcriptionsvc transcription.IService) error {
+	transcriptionsvc transcription.IService,
+	embeddingsvc embedding.IService,
+	llmsvc llm.IService) error {
 	// Setup the Gin router
 	r := gin.Default()
 	cfg := cors.DefaultConfig()
@@ -68,7 +72,7 @@ func Run(canxCtx context.Context,
 	// TODO: Add routes
 
 	// Setup API routes
-	apiRoutes(canxCtx, r, errorStream, cfgsvc, datasvc, ytsvc, audiosvc, storagesvc, cloudconvertsvc, transcriptionsvc)
+	apiRoutes(canxCtx, r, errorStream, cfgsvc, datasvc, ytsvc, audiosvc, storagesvc, cloudconvertsvc, transcriptionsvc, embeddingsvc, llmsvc)
 
 	fn := getRunWithCanxFn(r, ":"+cfgsvc.GetAPIPort())
 	return fn(canxCtx, errorStream)
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/khaledhikmat/yt-extractor/service"
 	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/lgr"
 )
@@ -23,7 +22,10 @@ func New(cfgsvc config.IService) IService {
 	}
 }
 
-func (svc *cloudConvertService) ConvertVideoToAudio(channelID, videoID string) (string, error) {
+// ConvertVideoToAudio returns the audio URL once the conversion finishes.
+// If the conversion completion is reported by the webhook, it returns as soon as the
+// conversion is accepted without a URL.
+func (svc *cloudConvertService) ConvertVideoToAudio(channelID, videoID string) (string, bool, error) {
 	lgr.Logger.Debug("ConvertFromURL",
 		slog.String("channelId", channelID),
 		slog.String("videoId", videoID),
@@ -74,31 +76,32 @@ func (svc *cloudConvertService) ConvertVideoToAudio(channelID, videoID string) (
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return service.InvalidURL, err
+		return "", false, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return service.InvalidURL, fmt.Errorf("failed to create job, status: %s", resp.Status)
+		return "", false, fmt.Errorf("failed to create job, status: %s", resp.Status)
 	}
 
 	var jobResp map[string]interface{}
 	err = json.NewDecoder(resp.Body).Decode(&jobResp)
 	if err != nil {
-		return service.InvalidURL, fmt.Errorf("failed to decode job response: %v", err)
+		return "", false, fmt.Errorf("failed to decode job response: %v", err)
 	}
 
 	jobID := jobResp["data"].(map[string]interface{})["id"].(string)
 	if jobID == "" {
-		return service.InvalidURL, fmt.Errorf("job id is empty")
+		return "", false, fmt.Errorf("job id is empty")
 	}
 
 	if svc.ConfigSvc.IsCloudConvertWebhook() {
-		return service.AcceptedURL, nil
+		return "", true, nil
 	}
 
 	// Poll for job status
-	return svc.checkJobStatus(jobID, channelID, videoID)
+	audioURL, err := svc.checkJobStatus(jobID, channelID, videoID)
+	return audioURL, false, err
 }
 
 func (svc *cloudConvertService) checkJobStatus(jobID, channelID, videoID string) (string, error) {
@@ -120,18 +123,18 @@ func (svc *cloudConvertService) checkJobStatus(jobID, channelID, videoID string)
 		req.Header.Set("Authorization", "Bearer "+svc.ConfigSvc.GetCloudConvertKey())
 		resp, err := client.Do(req)
 		if err != nil {
-			return service.InvalidURL, err
+			return "", err
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			return service.InvalidURL, fmt.Errorf("failed to show job, status: %s", resp.Status)
+			return "", fmt.Errorf("failed to show job, status: %s", resp.Status)
 		}
 
 		var jobStatusResponse map[string]interface{}
 		err = json.NewDecoder(resp.Body).Decode(&jobStatusResponse)
 		if err != nil {
-			return service.InvalidURL, fmt.Errorf("failed to decode job status response: %v", err)
+			return "", fmt.Errorf("failed to decode job status response: %v", err)
 		}
 
 		status := jobStatusResponse["data"].(map[string]interface{})["status"].(string)
@@ -140,7 +143,7 @@ func (svc *cloudConvertService) checkJobStatus(jobID, channelID, videoID string)
 			url := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", svc.ConfigSvc.GetStorageBucket(), svc.ConfigSvc.GetStorageRegion(), fmt.Sprintf("%s/%s.mp3", channelID, videoID))
 			return url, nil
 		} else if status == "failed" {
-			return service.InvalidURL, fmt.Errorf("cloudconvert job failed, status: %s", status)
+			return "", fmt.Errorf("cloudconvert job failed, status: %s", status)
 		}
 
 		// Wait for 5 seconds before retrying
@@ -152,7 +155,7 @@ func (svc *cloudConvertService) checkJobStatus(jobID, channelID, videoID string)
 			lgr.Logger.Debug("checkJobStatus",
 				slog.String("timeout", fmt.Sprintf("%d attempts", svc.ConfigSvc.GetCloudConvertAttempts())),
 			)
-			return service.InvalidURL, fmt.Errorf("cloudconvert job timed out after %d attempts", svc.ConfigSvc.GetCloudConvertAttempts())
+			return "", fmt.Errorf("cloudconvert job timed out after %d attempts", svc.ConfigSvc.GetCloudConvertAttempts())
 		}
 	}
 }
@@ -16,7 +16,7 @@ import (
 func TestConversion(t *testing.T) {
 	configSvc := config.New()
 	svc := New(configSvc)
-	s3URL, err := svc.ConvertVideoToAudio("UCP-PfkMcOKriSxFMH7pTxfA", "02o2vrpOogc")
+	s3URL, _, err := svc.ConvertVideoToAudio("UCP-PfkMcOKriSxFMH7pTxfA", "02o2vrpOogc")
 	if err != nil {
 		t.Error(err)
 	}
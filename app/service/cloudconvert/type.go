@@ -1,5 +1,5 @@
 package cloudconvert
 
 type IService interface {
-	ConvertVideoToAudio(channelID, videoID string) (string, error)
+	ConvertVideoToAudio(channelID, videoID string) (string, bool, error)
 }
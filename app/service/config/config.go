@@ -1,8 +1,19 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultUpdatePeriod is how far back the updated videos are looked for
+	defaultUpdatePeriod = 48 * time.Hour
+	// defaultTranscriptionCutoffDate is the publish date before which videos are not transcribed
+	defaultTranscriptionCutoffDate = "2025-01-01 00:00:00"
 )
 
 type configService struct {
@@ -32,8 +43,8 @@ func (svc *configService) IsOpenTelemetry() bool {
 	return os.Getenv("OPEN_TELEMETRY") == "true"
 }
 
-func (svc *configService) IsParseCodecEnabled() bool {
-	return os.Getenv("PARSE_CODEC") == "true"
+func (svc *configService) IsAutoMigrate() bool {
+	return os.Getenv("AUTO_MIGRATE") != "false"
 }
 
 func (svc *configService) IsContineousExtraction() bool {
@@ -57,8 +68,77 @@ func (svc *configService) GetExtractionChannelID() string {
 	return os.Getenv("EXTRACTION_CHANNEL_ID")
 }
 
-func (svc *configService) GetLocalCodecsFolder() string {
-	return os.Getenv("LOCAL_CODECS_FOLDER")
+func (svc *configService) GetExtractionWorkers() int {
+	w, err := strconv.Atoi(os.Getenv("EXTRACTION_WORKERS"))
+	if err != nil || w < 1 {
+		return 1
+	}
+
+	return w
+}
+
+func (svc *configService) GetExtractionHostRate() int {
+	w, err := strconv.Atoi(os.Getenv("EXTRACTION_HOST_RATE"))
+	if err != nil {
+		return 0
+	}
+
+	return w
+}
+
+func (svc *configService) GetAudioOnlyChannels() []string {
+	if os.Getenv("AUDIO_ONLY_CHANNELS") == "" {
+		return []string{}
+	}
+
+	return strings.Split(os.Getenv("AUDIO_ONLY_CHANNELS"), ",")
+}
+
+func (svc *configService) GetCookiesFile() string {
+	if os.Getenv("COOKIES_FILE") == "" {
+		return "./cookies.txt"
+	}
+
+	return os.Getenv("COOKIES_FILE")
+}
+
+func (svc *configService) GetCookiesEncryptionKey() string {
+	return os.Getenv("COOKIES_ENCRYPTION_KEY")
+}
+
+func (svc *configService) GetCookiesTestURL() string {
+	// The watch history feed is only served to signed-in accounts
+	if os.Getenv("COOKIES_TEST_URL") == "" {
+		return ":ythistory"
+	}
+
+	return os.Getenv("COOKIES_TEST_URL")
+}
+
+func (svc *configService) GetFormatMaxHeight() int {
+	w, err := strconv.Atoi(os.Getenv("FORMAT_MAX_HEIGHT"))
+	if err != nil {
+		return 1080
+	}
+
+	return w
+}
+
+func (svc *configService) GetFormatPreferredCodec() string {
+	if os.Getenv("FORMAT_PREFERRED_CODEC") == "" {
+		return "avc1"
+	}
+
+	return os.Getenv("FORMAT_PREFERRED_CODEC")
+}
+
+func (svc *configService) GetFormatMaxFileSize() int64 {
+	w, err := strconv.ParseInt(os.Getenv("FORMAT_MAX_FILE_SIZE"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return w
 }
 
 func (svc *configService) GetLocalVideosFolder() string {
@@ -73,30 +153,171 @@ func (svc *configService) GetLocalTranscriptionFolder() string {
 	return os.Getenv("LOCAL_TRANSCRIPTION_FOLDER")
 }
 
-func (svc *configService) GetUpdatePeriod() string {
-	if os.Getenv("UPDATE_PERIOD") == "" {
-		return "48 HOURS"
+func (svc *configService) GetUpdatePeriod() time.Duration {
+	period, err := parsePeriod(os.Getenv("UPDATE_PERIOD"))
+	if err != nil || period <= 0 {
+		return defaultUpdatePeriod
 	}
 
-	return os.Getenv("UPDATE_PERIOD")
+	return period
 }
 
-func (svc *configService) GetReattemptPeriod() string {
-	if os.Getenv("REATTEMPT_PERIOD") == "" {
-		return "48 HOURS"
+func (svc *configService) GetMaxAttempts() int {
+	w, err := strconv.Atoi(os.Getenv("MAX_ATTEMPTS"))
+	if err != nil || w < 1 {
+		return 5
 	}
 
-	return os.Getenv("REATTEMPT_PERIOD")
+	return w
+}
+
+func (svc *configService) GetRetryBackoffBase() int {
+	w, err := strconv.Atoi(os.Getenv("RETRY_BACKOFF_BASE"))
+	if err != nil || w < 1 {
+		return 15
+	}
+
+	return w
+}
+
+func (svc *configService) GetStageLease() int {
+	w, err := strconv.Atoi(os.Getenv("STAGE_LEASE"))
+	if err != nil || w < 1 {
+		return 120
+	}
+
+	return w
 }
 
-func (svc *configService) GetVideoTranscriptionCutoffDate() string {
-	return os.Getenv("VIDEO_TRANSCRIPTION_CUTOFF_DATE")
+func (svc *configService) GetVideoTranscriptionCutoffDate() time.Time {
+	date, err := parseDate(os.Getenv("VIDEO_TRANSCRIPTION_CUTOFF_DATE"))
+	if err != nil {
+		date, _ = parseDate(defaultTranscriptionCutoffDate)
+	}
+
+	return date
 }
 
 func (svc *configService) GetTranscriptionProvider() string {
 	return os.Getenv("TRANSCRIPTION_PROVIDER")
 }
 
+func (svc *configService) GetEmbeddingProvider() string {
+	if os.Getenv("EMBEDDING_PROVIDER") == "" {
+		return "openai"
+	}
+
+	return os.Getenv("EMBEDDING_PROVIDER")
+}
+
+func (svc *configService) GetEmbeddingBaseURL() string {
+	if os.Getenv("EMBEDDING_BASE_URL") == "" {
+		return "https://api.openai.com/v1"
+	}
+
+	return strings.TrimSuffix(os.Getenv("EMBEDDING_BASE_URL"), "/")
+}
+
+func (svc *configService) GetEmbeddingModel() string {
+	if os.Getenv("EMBEDDING_MODEL") == "" {
+		return "text-embedding-3-small"
+	}
+
+	return os.Getenv("EMBEDDING_MODEL")
+}
+
+func (svc *configService) GetEmbeddingDimensions() int {
+	w, err := strconv.Atoi(os.Getenv("EMBEDDING_DIMENSIONS"))
+	if err != nil || w <= 0 {
+		return 256
+	}
+
+	return w
+}
+
+// GetEmbeddingAPIKey defaults to the OpenAI key
+func (svc *configService) GetEmbeddingAPIKey() string {
+	if os.Getenv("EMBEDDING_API_KEY") == "" {
+		return svc.GetOpenAIKey()
+	}
+
+	return os.Getenv("EMBEDDING_API_KEY")
+}
+
+func (svc *configService) GetLLMProvider() string {
+	if os.Getenv("LLM_PROVIDER") == "" {
+		return "openai"
+	}
+
+	return os.Getenv("LLM_PROVIDER")
+}
+
+func (svc *configService) GetLLMBaseURL() string {
+	if os.Getenv("LLM_BASE_URL") == "" {
+		return "https://api.openai.com/v1"
+	}
+
+	return strings.TrimSuffix(os.Getenv("LLM_BASE_URL"), "/")
+}
+
+func (svc *configService) GetLLMModel() string {
+	if os.Getenv("LLM_MODEL") == "" {
+		return "gpt-4o-mini"
+	}
+
+	return os.Getenv("LLM_MODEL")
+}
+
+// GetLLMAPIKey defaults to the OpenAI key
+func (svc *configService) GetLLMAPIKey() string {
+	if os.Getenv("LLM_API_KEY") == "" {
+		return svc.GetOpenAIKey()
+	}
+
+	return os.Getenv("LLM_API_KEY")
+}
+
+func (svc *configService) GetTranscriptionStrategy() string {
+	if os.Getenv("TRANSCRIPTION_STRATEGY") == "" {
+		return "audio"
+	}
+
+	return os.Getenv("TRANSCRIPTION_STRATEGY")
+}
+
+func (svc *configService) GetCaptionLanguages() []string {
+	if os.Getenv("CAPTION_LANGUAGES") == "" {
+		return []string{"ar", "en"}
+	}
+
+	return strings.Split(os.Getenv("CAPTION_LANGUAGES"), ",")
+}
+
+func (svc *configService) IsAutoCaptionsAllowed() bool {
+	return os.Getenv("AUTO_CAPTIONS") != "false"
+}
+
+func (svc *configService) GetShortsDetection() string {
+	if os.Getenv("SHORTS_DETECTION") == "" {
+		return "probe"
+	}
+
+	return os.Getenv("SHORTS_DETECTION")
+}
+
+func (svc *configService) GetShortsMaxDuration() int64 {
+	w, err := strconv.ParseInt(os.Getenv("SHORTS_MAX_DURATION"), 10, 64)
+	if err != nil {
+		return 180
+	}
+
+	return w
+}
+
+func (svc *configService) IsShortsAspectRatioCheck() bool {
+	return os.Getenv("SHORTS_ASPECT_RATIO_CHECK") == "true"
+}
+
 func (svc *configService) GetStorageProvider() string {
 	return os.Getenv("STORAGE_PROVIDER")
 }
@@ -129,10 +350,49 @@ func (svc *configService) GetAutomationWebhookURL() string {
 	return os.Getenv("AUTOMATION_WEBHOOK_URL")
 }
 
+func (svc *configService) GetAutomationPipelines() string {
+	return os.Getenv("AUTOMATION_PIPELINES")
+}
+
+func (svc *configService) GetDbProvider() string {
+	if os.Getenv("DB_PROVIDER") == "" {
+		return "postgres"
+	}
+
+	return os.Getenv("DB_PROVIDER")
+}
+
 func (svc *configService) GetDbDSN() string {
 	return os.Getenv(os.Getenv("DB_DSN"))
 }
 
+func (svc *configService) GetDbMaxOpenConns() int {
+	w, err := strconv.Atoi(os.Getenv("DB_MAX_OPEN_CONNS"))
+	if err != nil || w < 1 {
+		return 10
+	}
+
+	return w
+}
+
+func (svc *configService) GetDbMaxIdleConns() int {
+	w, err := strconv.Atoi(os.Getenv("DB_MAX_IDLE_CONNS"))
+	if err != nil || w < 0 {
+		return 5
+	}
+
+	return w
+}
+
+func (svc *configService) GetDbConnMaxLifetime() int {
+	w, err := strconv.Atoi(os.Getenv("DB_CONN_MAX_LIFETIME"))
+	if err != nil || w < 0 {
+		return 30
+	}
+
+	return w
+}
+
 func (svc *configService) GetNeonDSN() string {
 	return os.Getenv("NEON_DSN")
 }
@@ -166,5 +426,79 @@ func (svc *configService) GetCloudConvertAttempts() int {
 	return w
 }
 
+// Validate checks the configured values whose getters fall back to their defaults so a typo
+// fails the startup instead of quietly changing which videos are updated, retried or transcribed
+func (svc *configService) Validate() error {
+	errs := []error{}
+
+	if value := os.Getenv("UPDATE_PERIOD"); value != "" {
+		period, err := parsePeriod(value)
+		if err == nil && period <= 0 {
+			err = fmt.Errorf("period %q is not positive", value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("UPDATE_PERIOD: %w", err))
+		}
+	}
+
+	if value := os.Getenv("VIDEO_TRANSCRIPTION_CUTOFF_DATE"); value != "" {
+		_, err := parseDate(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("VIDEO_TRANSCRIPTION_CUTOFF_DATE: %w", err))
+		}
+	}
+
+	if value := os.Getenv("RETRY_BACKOFF_BASE"); value != "" {
+		w, err := strconv.Atoi(value)
+		if err != nil || w < 1 {
+			errs = append(errs, fmt.Errorf("RETRY_BACKOFF_BASE: %q is not a positive number of minutes", value))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func (svc *configService) Finalize() {
 }
+
+// parsePeriod parses a Go duration (i.e. 48h) or a number of minutes, hours or days (i.e. 48 HOURS)
+func parsePeriod(value string) (time.Duration, error) {
+	period, err := time.ParseDuration(value)
+	if err == nil {
+		return period, nil
+	}
+
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("period %q is invalid", value)
+	}
+
+	n, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, fmt.Errorf("period %q is invalid", value)
+	}
+
+	units := map[string]time.Duration{
+		"minute": time.Minute,
+		"hour":   time.Hour,
+		"day":    24 * time.Hour,
+	}
+	unit, ok := units[strings.TrimSuffix(fields[1], "s")]
+	if !ok {
+		return 0, fmt.Errorf("period %q has an invalid unit", value)
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+// parseDate parses a UTC date with or without the time (i.e. 2025-01-01 00:00:00 or 2025-01-01)
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{time.DateTime, time.DateOnly} {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("date %q is invalid", value)
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		failed bool
+	}{
+		{name: "defaults", env: map[string]string{}},
+		{name: "valid", env: map[string]string{"UPDATE_PERIOD": "2 days", "VIDEO_TRANSCRIPTION_CUTOFF_DATE": "2025-01-01", "RETRY_BACKOFF_BASE": "5"}},
+		{name: "invalid period", env: map[string]string{"UPDATE_PERIOD": "2 weeks"}, failed: true},
+		{name: "negative period", env: map[string]string{"UPDATE_PERIOD": "-48h"}, failed: true},
+		{name: "invalid cutoff date", env: map[string]string{"VIDEO_TRANSCRIPTION_CUTOFF_DATE": "01/01/2025"}, failed: true},
+		{name: "zero backoff", env: map[string]string{"RETRY_BACKOFF_BASE": "0"}, failed: true},
+		{name: "negative backoff", env: map[string]string{"RETRY_BACKOFF_BASE": "-5"}, failed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{"UPDATE_PERIOD", "VIDEO_TRANSCRIPTION_CUTOFF_DATE", "RETRY_BACKOFF_BASE"} {
+				t.Setenv(name, test.env[name])
+			}
+
+			err := New().Validate()
+			if (err != nil) != test.failed {
+				t.Errorf("expected failed %t, got %v", test.failed, err)
+			}
+		})
+	}
+}
+
+func TestGetters(t *testing.T) {
+	t.Setenv("UPDATE_PERIOD", "2 days")
+	t.Setenv("VIDEO_TRANSCRIPTION_CUTOFF_DATE", "2025-06-01")
+	t.Setenv("RETRY_BACKOFF_BASE", "0")
+
+	svc := New()
+	if period := svc.GetUpdatePeriod(); period != 48*time.Hour {
+		t.Errorf("expected a 48h update period, got %s", period)
+	}
+
+	if date := svc.GetVideoTranscriptionCutoffDate(); !date.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the 2025-06-01 cutoff date, got %s", date)
+	}
+
+	// An invalid backoff falls back to its default
+	if base := svc.GetRetryBackoffBase(); base != 15 {
+		t.Errorf("expected the default backoff, got %d", base)
+	}
+}
@@ -1,26 +1,56 @@
 package config
 
+import "time"
+
 type IService interface {
 	GetRuntimeEnvironment() string
 	IsProduction() bool
 	GetAPIPort() string
 	IsOpenTelemetry() bool
-	IsParseCodecEnabled() bool
+	IsAutoMigrate() bool
 	IsContineousExtraction() bool
 	IsPeriodicExtraction() bool
 	GetExtractionPeriod() int
 	GetExtractionChannelID() string
+	GetExtractionWorkers() int
+	GetExtractionHostRate() int
+	GetAudioOnlyChannels() []string
+	GetCookiesFile() string
+	GetCookiesEncryptionKey() string
+	GetCookiesTestURL() string
+	GetFormatMaxHeight() int
+	GetFormatPreferredCodec() string
+	GetFormatMaxFileSize() int64
 
-	GetLocalCodecsFolder() string
 	GetLocalVideosFolder() string
 	GetLocalAudioFolder() string
 	GetLocalTranscriptionFolder() string
 
-	GetUpdatePeriod() string
-	GetReattemptPeriod() string
-	GetVideoTranscriptionCutoffDate() string
+	GetUpdatePeriod() time.Duration
+	GetMaxAttempts() int
+	GetRetryBackoffBase() int
+	GetStageLease() int
+	GetVideoTranscriptionCutoffDate() time.Time
 
 	GetTranscriptionProvider() string
+	GetTranscriptionStrategy() string
+	GetCaptionLanguages() []string
+	IsAutoCaptionsAllowed() bool
+
+	GetEmbeddingProvider() string
+	GetEmbeddingBaseURL() string
+	GetEmbeddingModel() string
+	GetEmbeddingDimensions() int
+	GetEmbeddingAPIKey() string
+
+	GetLLMProvider() string
+	GetLLMBaseURL() string
+	GetLLMModel() string
+	GetLLMAPIKey() string
+
+	GetShortsDetection() string
+	GetShortsMaxDuration() int64
+	IsShortsAspectRatioCheck() bool
 
 	GetStorageProvider() string
 	GetStorageBucket() string
@@ -31,8 +61,13 @@ type IService interface {
 	GetAWSRegion() string
 
 	GetAutomationWebhookURL() string
+	GetAutomationPipelines() string
 
+	GetDbProvider() string
 	GetDbDSN() string
+	GetDbMaxOpenConns() int
+	GetDbMaxIdleConns() int
+	GetDbConnMaxLifetime() int
 	GetNeonDSN() string
 	GetRailwayDSN() string
 	GetYoutubeAPIKey() string
@@ -43,5 +78,7 @@ type IService interface {
 
 	GetCloudConvertAttempts() int
 
+	Validate() error
+
 	Finalize()
 }
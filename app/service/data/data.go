@@ -1,130 +1,227 @@
 package data
 
 import (
-	_ "embed"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
 	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq" // Import the PostgreSQL driver
 
 	"github.com/khaledhikmat/yt-extractor/service"
 	"github.com/khaledhikmat/yt-extractor/service/config"
+	"github.com/khaledhikmat/yt-extractor/utils"
 )
 
 var mutex = &sync.Mutex{}
 
-//go:embed sql/reset_factory.sql
-var resetfactorySQL string
+// ErrJobConflict is returned when a job of the same type is already queued or running for the channel
+var ErrJobConflict = errors.New("job conflict")
 
-//go:embed sql/insertvideo.sql
-var insertVideoSQL string
-
-//go:embed sql/updatevideo_ytattributes.sql
-var updateytattributesSQL string
-
-//go:embed sql/updatevideo_ytexternalization.sql
-var updateytexternalizationSQL string
-
-//go:embed sql/updatevideo_ytextraction.sql
-var updateytextractionSQL string
-
-//go:embed sql/updatevideo_ytextraction_error.sql
-var updateytextractionerrorSQL string
-
-//go:embed sql/updatevideo_ytaudio.sql
-var updateytaudioSQL string
-
-//go:embed sql/updatevideo_ytaudio_error.sql
-var updateytaudioerrorSQL string
-
-//go:embed sql/updatevideo_yttranscription.sql
-var updateyttranscriptionSQL string
-
-//go:embed sql/updatevideo_yttranscription_error.sql
-var updateyttranscriptionerrorSQL string
-
-//go:embed sql/insertjob.sql
-var insertjobSQL string
-
-//go:embed sql/updatejob.sql
-var updatejobSQL string
-
-//go:embed sql/insertapikey.sql
-var insertapikeySQL string
+const (
+	// uniqueViolationCode is the Postgres error code of a unique index violation
+	uniqueViolationCode = "23505"
+	// activeJobIndex allows a single queued or running job per channel and type
+	activeJobIndex = "jobs_active_type_idx"
+)
 
-//go:embed sql/inserterror.sql
-var inserterrorSQL string
+// Derived video statistics metrics are computed over this window
+const statsMetricsWindow = 7 * 24 * time.Hour
+
+//go:embed sql/*.sql sql/sqlite/*.sql
+var statementsFS embed.FS
+
+// The statements are loaded when the package is initialized so a missing statement file fails the startup
+var (
+	insertapikeySQL               = mustStatement("insertapikey.sql")
+	insertcookiesSQL              = mustStatement("insertcookies.sql")
+	inserterrorSQL                = mustStatement("inserterror.sql")
+	insertjobSQL                  = mustStatement("insertjob.sql")
+	insertjobvideoSQL             = mustStatement("insertjobvideo.sql")
+	inserttranscriptembeddingSQL  = mustStatement("inserttranscriptembedding.sql")
+	inserttranscriptsegmentSQL    = mustStatement("inserttranscriptsegment.sql")
+	insertVideoSQL                = mustStatement("insertvideo.sql")
+	insertvideostatssnapshotSQL   = mustStatement("insertvideostatssnapshot.sql")
+	resetfactorySQL               = mustStatement("reset_factory.sql")
+	searchtranscriptsSQL          = mustStatement("searchtranscripts.sql")
+	updatejobSQL                  = mustStatement("updatejob.sql")
+	updatejobvideoSQL             = mustStatement("updatejobvideo.sql")
+	updaterequeueSQL              = mustStatement("updatevideo_requeue.sql")
+	updatestagestatusSQL          = mustStatement("updatevideo_stagestatus.sql")
+	updatestalestageSQL           = mustStatement("updatevideo_stalestage.sql")
+	updateytattributesSQL         = mustStatement("updatevideo_ytattributes.sql")
+	updateytaudioSQL              = mustStatement("updatevideo_ytaudio.sql")
+	updateytaudioerrorSQL         = mustStatement("updatevideo_ytaudio_error.sql")
+	updateytavailabilitySQL       = mustStatement("updatevideo_ytavailability.sql")
+	updateytcaptionsSQL           = mustStatement("updatevideo_ytcaptions.sql")
+	updateytembeddingSQL          = mustStatement("updatevideo_ytembedding.sql")
+	updateytexternalizationSQL    = mustStatement("updatevideo_ytexternalization.sql")
+	updateytextractionSQL         = mustStatement("updatevideo_ytextraction.sql")
+	updateytextractionerrorSQL    = mustStatement("updatevideo_ytextraction_error.sql")
+	updateytgoneSQL               = mustStatement("updatevideo_ytgone.sql")
+	updateytshortSQL              = mustStatement("updatevideo_ytshort.sql")
+	updateyttranscriptionSQL      = mustStatement("updatevideo_yttranscription.sql")
+	updateyttranscriptionerrorSQL = mustStatement("updatevideo_yttranscription_error.sql")
+)
 
 type dataService struct {
 	ConfigSvc config.IService
 	Db        *sqlx.DB
+	dialect   dialect
+	vectors   *vectorIndex
 }
 
 func New(cfgsvc config.IService) IService {
 	return &dataService{
 		ConfigSvc: cfgsvc,
+		vectors:   newVectorIndex(),
 	}
 }
 
-func (svc *dataService) ResetFactory() error {
-	err := svc.dbConnection()
+func (svc *dataService) ResetFactory(ctx context.Context) error {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return err
 	}
 
-	_, err = svc.Db.Exec(resetfactorySQL)
+	_, err = svc.Db.ExecContext(ctx, svc.statement(resetfactorySQL))
 	if err != nil {
 		return err
 	}
 
+	svc.vectors.reset()
 	return nil
 }
 
-func (svc *dataService) NewVideo(video Video) (bool, int64, error) {
-	err := svc.dbConnection()
+func (svc *dataService) NewVideo(ctx context.Context, video Video) (bool, int64, error) {
+	upserted, err := svc.NewVideos(ctx, []Video{video})
 	if err != nil {
 		return false, -1, err
 	}
 
-	// Make sure that video does not already exist
-	vid, err := svc.RetrieveVideoByIDs(video.ChannelID, video.VideoID)
+	if len(upserted) == 0 {
+		return false, -1, nil
+	}
+
+	return upserted[0].Inserted, upserted[0].ID, nil
+}
+
+// NewVideos inserts the videos or updates their attributes if they already exist in one statement.
+// There is no point inserting a video that is already gone so only the availability of the gone videos is updated.
+func (svc *dataService) NewVideos(ctx context.Context, videos []Video) ([]UpsertedVideo, error) {
+	upserted := []UpsertedVideo{}
+	err := svc.dbConnection(ctx)
 	if err != nil {
-		return false, -1, fmt.Errorf("Error fetching video by ID: %v", err)
+		return upserted, err
+	}
+
+	// A statement cannot upsert the same video twice
+	available := []Video{}
+	seen := map[string]bool{}
+	for _, video := range videos {
+		key := video.ChannelID + "/" + video.VideoID
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if !video.Availability.IsGone() {
+			available = append(available, video)
+			continue
+		}
+
+		// Youtube does not report the attributes of gone videos
+		var id int64
+		err = svc.Db.GetContext(ctx, &id, svc.statement(updateytgoneSQL), video.Availability, video.ChannelID, video.VideoID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return upserted, err
+		}
+
+		upserted = append(upserted, UpsertedVideo{
+			ID:        id,
+			ChannelID: video.ChannelID,
+			VideoID:   video.VideoID,
+		})
 	}
 
-	// If the video already exists, switch to update the attributes
-	if vid.VideoID != "" {
-		err = svc.UpdateVideo(&video, JobTypeAttributes)
-		return false, vid.ID, err
+	if len(available) == 0 {
+		return upserted, nil
 	}
 
-	// Execute the insert query using NamedExec or NamedQuery
-	rows, err := svc.Db.NamedQuery(insertVideoSQL, video)
+	// The inserted videos are told apart by the existing ones if the upsert cannot report them
+	existing := map[string]bool{}
+	if !svc.dialect.reportsInserts() {
+		existing, err = svc.existingVideos(ctx, available)
+		if err != nil {
+			return upserted, err
+		}
+	}
+
+	rows, err := svc.Db.NamedQueryContext(ctx, svc.statement(insertVideoSQL), available)
 	if err != nil {
-		return false, -1, err
+		return upserted, err
 	}
 	defer rows.Close()
 
-	// Fetch the newly inserted ID if needed
-	if rows.Next() {
-		err = rows.Scan(&video.ID)
+	for rows.Next() {
+		video := UpsertedVideo{}
+		err = rows.StructScan(&video)
 		if err != nil {
-			return false, -1, err
+			return upserted, err
 		}
+		if !svc.dialect.reportsInserts() {
+			video.Inserted = !existing[video.ChannelID+"/"+video.VideoID]
+		}
+		upserted = append(upserted, video)
 	}
 
-	return true, video.ID, nil
+	return upserted, rows.Err()
 }
 
-func (svc *dataService) UpdateVideo(video *Video, jobType JobType) error {
-	err := svc.dbConnection()
+// existingVideos returns the keys (i.e. channel ID/video ID) of the videos that are already stored
+func (svc *dataService) existingVideos(ctx context.Context, videos []Video) (map[string]bool, error) {
+	existing := map[string]bool{}
+	videoIDs := []string{}
+	for _, video := range videos {
+		videoIDs = append(videoIDs, video.VideoID)
+	}
+
+	query, args, err := sqlx.In(`SELECT channel_id, video_id FROM videos WHERE video_id IN (?)`, videoIDs)
+	if err != nil {
+		return existing, err
+	}
+
+	stored := []UpsertedVideo{}
+	err = svc.Db.SelectContext(ctx, &stored, svc.Db.Rebind(query), args...)
+	if err != nil {
+		return existing, err
+	}
+
+	for _, video := range stored {
+		existing[video.ChannelID+"/"+video.VideoID] = true
+	}
+
+	return existing, nil
+}
+
+func (svc *dataService) UpdateVideo(ctx context.Context, video *Video, jobType JobType) error {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Make sure the video does exist
-	vid, err := svc.RetrieveVideoByIDs(video.ChannelID, video.VideoID)
+	vid, err := svc.RetrieveVideoByIDs(ctx, video.ChannelID, video.VideoID)
 	if err != nil {
 		return fmt.Errorf("Error fetching video by ID: %v", err)
 	}
@@ -133,27 +230,47 @@ func (svc *dataService) UpdateVideo(video *Video, jobType JobType) error {
 		return fmt.Errorf("Video ID %s does not exist", vid.VideoID)
 	}
 
+	// Failed stages are re-attempted with exponential backoff until they run out of attempts (i.e. dead letter)
+	maxAttempts := svc.ConfigSvc.GetMaxAttempts()
+	backoffBase := svc.ConfigSvc.GetRetryBackoffBase()
+
 	// We have several video update types:
 	if jobType == JobTypeAttributes {
-		if vid.Views == video.Views && vid.Comments == video.Comments && vid.Likes == video.Likes {
+		// Youtube does not report the attributes of gone videos so only the availability is updated
+		if video.Availability.IsGone() {
+			if vid.Availability == video.Availability {
+				return nil
+			}
+			return svc.UpdateVideoAvailability(ctx, video)
+		}
+
+		if !isAttributesChanged(vid, *video) {
 			// Skip the update if the attributes are the same
 			return nil
 		}
-		_, err = svc.Db.Exec(updateytattributesSQL, video.Views, video.Comments, video.Likes, video.ID)
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytattributesSQL), video.Views, video.Comments, video.Likes,
+			video.Description, video.Tags, video.Thumbnails, video.CategoryID, video.DefaultAudioLanguage,
+			video.Caption, video.LiveBroadcastContent, video.PrivacyStatus, video.Availability, video.Short, video.ID)
+	} else if jobType == JobTypeCaptions {
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytcaptionsSQL), video.CaptionsError, video.ID)
+	} else if jobType == JobTypeEmbedding {
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytembeddingSQL), video.EmbeddingStatus, video.EmbeddingError, maxAttempts, backoffBase, video.ID)
+	} else if jobType == JobTypeShorts {
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytshortSQL), video.Short, video.ID)
 	} else if jobType == JobTypeExternalization {
-		_, err = svc.Db.Exec(updateytexternalizationSQL, video.ID)
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytexternalizationSQL), video.ID)
 	} else if jobType == JobTypeExtraction {
-		_, err = svc.Db.Exec(updateytextractionSQL, video.ExtractionURL, video.ID)
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytextractionSQL), video.ExtractionURL, video.ExtractionStatus, video.ExtractionError, video.FormatIDs, video.ExtractionErrorClass, maxAttempts, backoffBase, video.ID)
 	} else if jobType == JobTypeExtractionError {
-		_, err = svc.Db.Exec(updateytextractionerrorSQL, video.ExtractionURL, video.ID)
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytextractionerrorSQL), video.ExtractionURL, video.ExtractionStatus, video.ExtractionError, video.FormatIDs, video.ExtractionErrorClass, maxAttempts, backoffBase, video.ID)
 	} else if jobType == JobTypeAudio {
-		_, err = svc.Db.Exec(updateytaudioSQL, video.AudioURL, video.ID)
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytaudioSQL), video.AudioURL, video.AudioStatus, video.AudioError, maxAttempts, backoffBase, video.ID)
 	} else if jobType == JobTypeAudioError {
-		_, err = svc.Db.Exec(updateytaudioerrorSQL, video.AudioURL, video.ID)
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateytaudioerrorSQL), video.AudioURL, video.AudioStatus, video.AudioError, maxAttempts, backoffBase, video.ID)
 	} else if jobType == JobTypeTranscription {
-		_, err = svc.Db.Exec(updateyttranscriptionSQL, video.TranscriptionURL, video.ID)
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateyttranscriptionSQL), video.TranscriptionURL, video.TranscriptionStatus, video.TranscriptionError, video.TranscriptionSource, video.SubtitlesURL, maxAttempts, backoffBase, video.ID)
 	} else if jobType == JobTypeTranscriptionError {
-		_, err = svc.Db.Exec(updateyttranscriptionerrorSQL, video.TranscriptionURL, video.ID)
+		_, err = svc.Db.ExecContext(ctx, svc.statement(updateyttranscriptionerrorSQL), video.TranscriptionURL, video.TranscriptionStatus, video.TranscriptionError, maxAttempts, backoffBase, video.ID)
 	} else {
 		return fmt.Errorf("Invalid job type %s", jobType)
 	}
@@ -165,53 +282,215 @@ func (svc *dataService) UpdateVideo(video *Video, jobType JobType) error {
 	return nil
 }
 
-func (svc *dataService) RetrieveVideos(channelID string, page, pageSize int, orderBy, orderDir string) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
+func (svc *dataService) UpdateVideoAvailability(ctx context.Context, video *Video) error {
+	err := svc.dbConnection(ctx)
 	if err != nil {
-		return videos, err
+		return err
 	}
 
-	if page < 1 {
-		return videos, fmt.Errorf("Invalid page number %d", page)
+	_, err = svc.Db.ExecContext(ctx, svc.statement(updateytavailabilitySQL), video.Availability, video.ID)
+	if err != nil {
+		return err
 	}
 
-	if pageSize <= 0 {
-		return videos, fmt.Errorf("Invalid page size %d", pageSize)
+	return nil
+}
+
+// UpdateVideoStageStatus moves the video to the status within the stage without recording an attempt
+func (svc *dataService) UpdateVideoStageStatus(ctx context.Context, video *Video, stage Stage, status StageStatus) error {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The stage is a column prefix so it cannot be a bind parameter
+	if !stage.isValid() {
+		return fmt.Errorf("Invalid stage %s", stage)
+	}
+
+	_, err = svc.Db.ExecContext(ctx, svc.stageStatement(updatestagestatusSQL, stage), status, video.ID)
+	if err != nil {
+		return err
+	}
+
+	switch stage {
+	case StageExtraction:
+		video.PreviousStatus, video.ExtractionStatus = video.ExtractionStatus, status
+	case StageAudio:
+		video.PreviousStatus, video.AudioStatus = video.AudioStatus, status
+	case StageTranscription:
+		video.PreviousStatus, video.TranscriptionStatus = video.TranscriptionStatus, status
+	case StageEmbedding:
+		video.PreviousStatus, video.EmbeddingStatus = video.EmbeddingStatus, status
+	}
+
+	return nil
+}
+
+// ReleaseStaleVideos fails the video stages that are in progress for longer than the stage lease
+// (i.e. their job crashed or was killed) so they are reattempted (or dead-lettered) like the other failures
+func (svc *dataService) ReleaseStaleVideos(ctx context.Context) (int64, error) {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	lease := svc.ConfigSvc.GetStageLease()
+	message := fmt.Sprintf("in progress for more than %d minutes", lease)
+	released := int64(0)
+	for _, stage := range []Stage{StageExtraction, StageAudio, StageTranscription, StageEmbedding} {
+		result, err := svc.Db.ExecContext(ctx, svc.stageStatement(updatestalestageSQL, stage), message, svc.ConfigSvc.GetMaxAttempts(), lease)
+		if err != nil {
+			return released, err
+		}
+
+		n, err := result.RowsAffected()
+		if err != nil {
+			return released, err
+		}
+		released += n
+	}
+
+	return released, nil
+}
+
+// RequeueVideo moves the video stage back to pending with a fresh attempt count
+func (svc *dataService) RequeueVideo(ctx context.Context, video *Video, stage Stage) error {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The stage is a column prefix so it cannot be a bind parameter
+	if !stage.isValid() {
+		return fmt.Errorf("Invalid stage %s", stage)
+	}
+
+	_, err = svc.Db.ExecContext(ctx, svc.stageStatement(updaterequeueSQL, stage), video.ID)
+	if err != nil {
+		return err
 	}
 
-	if orderBy != "published_at" &&
-		orderBy != "views" &&
-		orderBy != "comments" &&
-		orderBy != "likes" {
-		return videos, fmt.Errorf("Invalid order by %s", orderBy)
+	return nil
+}
+
+func (svc *dataService) RetrieveVideos(ctx context.Context, channelID string, page, pageSize int, orderBy, orderDir string) ([]Video, error) {
+	if page < 1 {
+		return []Video{}, fmt.Errorf("Invalid page number %d", page)
 	}
 
-	if orderDir != "asc" && orderDir != "desc" {
-		return videos, fmt.Errorf("Invalid order direction %s", orderDir)
+	if pageSize <= 0 {
+		return []Video{}, fmt.Errorf("Invalid page size %d", pageSize)
 	}
 
 	// Calculate the offset
 	offset := (page - 1) * pageSize
 
-	query := fmt.Sprintf(`
-        SELECT * 
-		FROM videos 
-		WHERE channel_id = $1 
-		ORDER BY %s %s 
-		LIMIT $2 OFFSET $3 
-    `, orderBy, orderDir)
+	q := newVideoQuery(channelID).
+		orderBy(orderBy, orderDir).
+		page(pageSize, offset)
+
+	return svc.selectVideos(ctx, q)
+}
+
+// QueryVideos returns a page of the filtered videos. The pages continue from the cursor
+// of the previous page (i.e. keyset pagination) so they stay stable while videos are added.
+func (svc *dataService) QueryVideos(ctx context.Context, filter VideoFilter) (VideoPage, error) {
+	page := VideoPage{
+		Videos: []Video{},
+	}
 
-	err = svc.Db.Select(&videos, query, channelID, pageSize, offset)
+	if filter.PageSize <= 0 {
+		return page, fmt.Errorf("Invalid page size %d", filter.PageSize)
+	}
+
+	q := newVideoQuery(filter.ChannelID)
+	if filter.Title != "" {
+		// The title is matched as text so the LIKE wildcards are escaped
+		title := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(filter.Title))
+		q.where(`LOWER(title) LIKE ? ESCAPE '\'`, "%"+title+"%")
+	}
+	if filter.PublishedFrom != nil {
+		q.where("published_at >= ?", filter.PublishedFrom.UTC())
+	}
+	if filter.PublishedTo != nil {
+		q.where("published_at <= ?", filter.PublishedTo.UTC())
+	}
+	if filter.PublishedBefore != nil {
+		q.where("published_at < ?", filter.PublishedBefore.UTC())
+	}
+	if filter.MinDuration != nil {
+		q.where("duration >= ?", *filter.MinDuration)
+	}
+	if filter.MaxDuration != nil {
+		q.where("duration <= ?", *filter.MaxDuration)
+	}
+	if filter.Short != nil {
+		q.where("short = ?", *filter.Short)
+	}
+	if filter.ExtractionStatus != "" {
+		q.where("extraction_status = ?", filter.ExtractionStatus)
+	}
+	if filter.AudioStatus != "" {
+		q.where("audio_status = ?", filter.AudioStatus)
+	}
+	if filter.TranscriptionStatus != "" {
+		q.where("transcription_status = ?", filter.TranscriptionStatus)
+	}
+	if filter.MinViews != nil {
+		q.where("views >= ?", *filter.MinViews)
+	}
+	if filter.HasTranscript != nil {
+		// The transcribed date is also set by the failed transcriptions so only the status tells
+		if *filter.HasTranscript {
+			q.where("transcription_status = ?", StageStatusSucceeded)
+		} else {
+			q.where("transcription_status <> ?", StageStatusSucceeded)
+		}
+	}
+
+	orderBy := filter.OrderBy
+	if orderBy == "" {
+		orderBy = "published_at"
+	}
+	orderDir := filter.OrderDir
+	if orderDir == "" {
+		orderDir = "desc"
+	}
+	q.orderBy(orderBy, orderDir)
+
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return page, err
+	}
+
+	query, args, err := q.buildCount()
+	if err != nil {
+		return page, err
+	}
+
+	err = svc.Db.GetContext(ctx, &page.Total, svc.Db.Rebind(query), args...)
 	if err != nil {
-		return videos, err
+		return page, err
 	}
 
-	return videos, nil
+	// One more video tells whether there is a next page
+	videos, err := svc.selectVideos(ctx, q.after(filter.Cursor).page(filter.PageSize+1, 0))
+	if err != nil {
+		return page, err
+	}
+
+	if len(videos) > filter.PageSize {
+		videos = videos[:filter.PageSize]
+		page.NextCursor = encodeVideoCursor(videos[len(videos)-1], orderBy)
+	}
+
+	page.Videos = videos
+	return page, nil
 }
 
-func (svc *dataService) RetrieveVideoByIDs(channelID string, videoID string) (Video, error) {
-	err := svc.dbConnection()
+func (svc *dataService) RetrieveVideoByIDs(ctx context.Context, channelID string, videoID string) (Video, error) {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return Video{}, err
 	}
@@ -224,7 +503,7 @@ func (svc *dataService) RetrieveVideoByIDs(channelID string, videoID string) (Vi
 		LIMIT 1
     `
 
-	err = svc.Db.Select(&videos, query, channelID, videoID)
+	err = svc.Db.SelectContext(ctx, &videos, query, channelID, videoID)
 	if err != nil {
 		return Video{}, err
 	}
@@ -236,8 +515,20 @@ func (svc *dataService) RetrieveVideoByIDs(channelID string, videoID string) (Vi
 	return videos[0], nil
 }
 
-func (svc *dataService) RetrieveVideoByID(id int64) (Video, error) {
-	err := svc.dbConnection()
+// RetrieveVideosByIDs returns the stored videos among the channel video IDs
+func (svc *dataService) RetrieveVideosByIDs(ctx context.Context, channelID string, videoIDs []string) ([]Video, error) {
+	if len(videoIDs) == 0 {
+		return []Video{}, nil
+	}
+
+	q := newVideoQuery(channelID).
+		where("video_id IN (?)", videoIDs)
+
+	return svc.selectVideos(ctx, q)
+}
+
+func (svc *dataService) RetrieveVideoByID(ctx context.Context, id int64) (Video, error) {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return Video{}, err
 	}
@@ -249,7 +540,7 @@ func (svc *dataService) RetrieveVideoByID(id int64) (Video, error) {
 		LIMIT 1
     `
 
-	err = svc.Db.Select(&videos, query, id)
+	err = svc.Db.SelectContext(ctx, &videos, query, id)
 	if err != nil {
 		return Video{}, err
 	}
@@ -261,229 +552,248 @@ func (svc *dataService) RetrieveVideoByID(id int64) (Video, error) {
 	return videos[0], nil
 }
 
-func (svc *dataService) RetrieveUnextractedVideos(channelID string, max int) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
-	if err != nil {
-		return videos, err
-	}
+func (svc *dataService) RetrieveUnextractedVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	// The videos transcribed from their captions do not need to be extracted
+	q := newVideoQuery(channelID).
+		where("availability = ?", service.AvailabilityPublic).
+		where("extraction_status = ?", StageStatusPending).
+		where("transcription_status <> ?", StageStatusSucceeded).
+		page(max, 0)
 
-	query := `
-        SELECT * FROM videos 
-		WHERE channel_id = $1 
-		AND extracted_at is null 
-		ORDER BY published_at DESC 
-		LIMIT $2 
-    `
+	return svc.selectVideos(ctx, q)
+}
 
-	err = svc.Db.Select(&videos, query, channelID, max)
-	if err != nil {
-		return videos, err
+func (svc *dataService) RetrieveExtractErroredVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	// The errored videos have failed extraction status and are re-attempted according to their error class retry policy:
+	// - backoff: the next attempt is due. The wait doubles with every attempt.
+	// - after cookie refresh: the cookies were refreshed since the last attempt.
+	// - never: the errored videos are dead-lettered right away so they are not re-attempted.
+	// Videos errored before the error classes were introduced do not have a class and are backed off.
+	// Videos that run out of attempts are dead-lettered to prevent them from being picked up perpetually (i.e. cyclic extraction).
+	backoffClasses := append([]service.ErrorClass{""}, service.ErrorClassesByRetryPolicy(service.RetryPolicyBackoff)...)
+	retryConditions := []string{`(
+			extraction_error_class IN (?)
+			AND COALESCE(extraction_next_attempt_at, NOW()) <= NOW()
+		)`}
+	retryArgs := []interface{}{backoffClasses}
+
+	// An empty IN () is not valid SQL
+	cookieRefreshClasses := service.ErrorClassesByRetryPolicy(service.RetryPolicyAfterCookieRefresh)
+	if len(cookieRefreshClasses) > 0 {
+		retryConditions = append(retryConditions, `(
+			extraction_error_class IN (?)
+			AND COALESCE(extraction_attempted_at, extracted_at) < (SELECT MAX(created_at) FROM cookies)
+		)`)
+		retryArgs = append(retryArgs, cookieRefreshClasses)
 	}
 
-	return videos, nil
+	q := newVideoQuery(channelID).
+		where("availability = ?", service.AvailabilityPublic).
+		where("extraction_status = ?", StageStatusFailed).
+		where("("+strings.Join(retryConditions, " OR ")+")", retryArgs...).
+		page(max, 0)
+
+	return svc.selectVideos(ctx, q)
 }
 
-func (svc *dataService) RetrieveExtractErroredVideos(channelID string, max int) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
-	if err != nil {
-		return videos, err
-	}
+func (svc *dataService) RetrieveUnexternalizedVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	// The videos transcribed from their captions are neither extracted nor audioed
+	q := newVideoQuery(channelID).
+		where("externalized_at is null").
+		where("transcribed_at is not null").
+		where("((extracted_at is not null AND audioed_at is not null) OR transcription_source = ?)", TranscriptionSourceCaptions).
+		page(max, 0)
 
-	// The errored videos have invalid extraction URL
-	// and it has not been more than configurable hours since the extraction.
-	// The last clause is to prevent the errored videos from being picked up perpetually (i.e. cyclic extraction).
-	query := fmt.Sprintf(`
-        SELECT * FROM videos 
-		WHERE channel_id = $1 
-		AND extracted_at is not null 
-		AND extraction_url = $2
-		AND extracted_at >= NOW() - INTERVAL '%s'
-		ORDER BY published_at DESC 
-		LIMIT $3 
-    `, svc.ConfigSvc.GetReattemptPeriod())
+	return svc.selectVideos(ctx, q)
+}
 
-	err = svc.Db.Select(&videos, query, channelID, service.InvalidURL, max)
-	if err != nil {
-		return videos, err
-	}
+// Used for transcription within the backend
+func (svc *dataService) RetrieveUnaudioedVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	// Prevent unprocessed query to pick up errored extractions
+	q := svc.transcribableVideoQuery(channelID, StageStatusPending).
+		page(max, 0)
 
-	return videos, nil
+	return svc.selectVideos(ctx, q)
 }
 
-func (svc *dataService) RetrieveUnexternalizedVideos(channelID string, max int) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
-	if err != nil {
-		return videos, err
-	}
+func (svc *dataService) RetrieveAudioErroredVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	// The errored videos have failed audio status and their next attempt is due.
+	// Videos that run out of attempts are dead-lettered to prevent them from being picked up perpetually (i.e. cyclic extraction).
+	q := newVideoQuery(channelID).
+		where("availability = ?", service.AvailabilityPublic).
+		where("audio_status = ?", StageStatusFailed).
+		where("COALESCE(audio_next_attempt_at, NOW()) <= NOW()").
+		page(max, 0)
 
-	query := `
-        SELECT * FROM videos 
-		WHERE channel_id = $1 
-		AND externalized_at is null 
-		AND extracted_at is not null 
-		AND audioed_at is not null 
-		AND transcribed_at is not null 
-		ORDER BY published_at DESC 
-		LIMIT $2 
-    `
+	return svc.selectVideos(ctx, q)
+}
 
-	err = svc.Db.Select(&videos, query, channelID, max)
-	if err != nil {
-		return videos, err
-	}
+// Used for transcription within the backend
+func (svc *dataService) RetrieveUntranscribedVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	q := svc.transcribableVideoQuery(channelID, StageStatusSucceeded).
+		page(max, 0)
 
-	return videos, nil
+	return svc.selectVideos(ctx, q)
 }
 
-// Used for transcription within the backend
-func (svc *dataService) RetrieveUnaudioedVideos(channelID string, max int) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
-	if err != nil {
-		return videos, err
+// Used for transcription from Youtube captions: the captions are attempted once before the video is
+// extracted so that the extraction and the audio conversion are only needed if the captions are not acceptable
+func (svc *dataService) RetrieveCaptionableVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	q := newVideoQuery(channelID).
+		where("availability = ?", service.AvailabilityPublic).
+		where("audio_status = ?", StageStatusPending).
+		where("transcription_status = ?", StageStatusPending).
+		where("captions_attempted_at is null").
+		where("published_at >= ?", svc.ConfigSvc.GetVideoTranscriptionCutoffDate())
+
+	// If auto-generated captions are not acceptable, only videos with creator-uploaded captions qualify
+	if !svc.ConfigSvc.IsAutoCaptionsAllowed() {
+		q.where("caption = ?", true)
 	}
 
-	// Prevent unprocessed query to pick up errored extractions
-	query := fmt.Sprintf(`
-        SELECT * FROM videos 
-		WHERE channel_id = $1 
-		AND extracted_at is not null 
-		AND extraction_url != '%s' 
-		AND audioed_at is null 
-		AND published_at >= '%s'
-		ORDER BY published_at DESC 
-		LIMIT $2 
-    `, service.InvalidURL, svc.ConfigSvc.GetVideoTranscriptionCutoffDate())
+	return svc.selectVideos(ctx, q.page(max, 0))
+}
 
-	err = svc.Db.Select(&videos, query, channelID, max)
-	if err != nil {
-		return videos, err
-	}
+func (svc *dataService) RetrieveTranscribeErroredVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	// The errored videos have failed transcription status and their next attempt is due.
+	// Videos that run out of attempts are dead-lettered to prevent them from being picked up perpetually (i.e. cyclic extraction).
+	q := newVideoQuery(channelID).
+		where("availability = ?", service.AvailabilityPublic).
+		where("transcription_status = ?", StageStatusFailed).
+		where("COALESCE(transcription_next_attempt_at, NOW()) <= NOW()").
+		page(max, 0)
 
-	return videos, nil
+	return svc.selectVideos(ctx, q)
 }
 
-func (svc *dataService) RetrieveAudioErroredVideos(channelID string, max int) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
-	if err != nil {
-		return videos, err
-	}
+// RetrieveDeadLetteredVideos returns the videos that ran out of attempts in any stage
+func (svc *dataService) RetrieveDeadLetteredVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	q := newVideoQuery(channelID).
+		where("(extraction_status = ? OR audio_status = ? OR transcription_status = ? OR embedding_status = ?)",
+			StageStatusDeadLetter, StageStatusDeadLetter, StageStatusDeadLetter, StageStatusDeadLetter).
+		page(max, 0)
 
-	// The errored videos have invalid audio URL
-	// and it has not been more than configurable hours since the audio conversion.
-	// The last clause is to prevent the errored videos from being picked up perpetually (i.e. cyclic extraction).
-	query := fmt.Sprintf(`
-        SELECT * FROM videos 
-		WHERE channel_id = $1 
-		AND audioed_at is not null 
-		AND audio_url = $2
-		AND audioed_at >= NOW() - INTERVAL '%s'
-		ORDER BY published_at DESC 
-		LIMIT $3 
-    `, svc.ConfigSvc.GetReattemptPeriod())
+	return svc.selectVideos(ctx, q)
+}
 
-	err = svc.Db.Select(&videos, query, channelID, service.InvalidURL, max)
-	if err != nil {
-		return videos, err
-	}
+func (svc *dataService) RetrieveUpdatedVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	q := newVideoQuery(channelID).
+		where("externalized_at is not null").
+		where("updated_at >= ?", time.Now().UTC().Add(-svc.ConfigSvc.GetUpdatePeriod())).
+		page(max, 0)
 
-	return videos, nil
+	return svc.selectVideos(ctx, q)
 }
 
-// Used for transcription within the backend
-func (svc *dataService) RetrieveUntranscribedVideos(channelID string, max int) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
-	if err != nil {
-		return videos, err
-	}
+// RetrieveUnindexedVideos returns the transcribed videos whose transcripts are not searchable yet.
+// The indexed date (rather than the segments) tells so the empty transcripts are not selected again.
+func (svc *dataService) RetrieveUnindexedVideos(ctx context.Context, channelID string, max int) ([]Video, error) {
+	q := newVideoQuery(channelID).
+		where("transcription_status = ?", StageStatusSucceeded).
+		where("transcription_url is not null").
+		where("indexed_at is null").
+		page(max, 0)
 
-	query := fmt.Sprintf(`
-        SELECT * FROM videos 
-		WHERE channel_id = $1 
-		AND extracted_at is not null 
-		AND extraction_url != '%s' 
-		AND audioed_at is not null 
-		AND audio_url != '%s' 
-		AND audio_url != '%s' 
-		AND transcribed_at is null 
-		AND published_at >= '%s'
-		ORDER BY published_at DESC 
-		LIMIT $2 
-    `, service.InvalidURL, service.InvalidURL, service.AcceptedURL, svc.ConfigSvc.GetVideoTranscriptionCutoffDate())
+	return svc.selectVideos(ctx, q)
+}
 
-	err = svc.Db.Select(&videos, query, channelID, max)
-	if err != nil {
-		return videos, err
-	}
+// RetrieveUnembeddedVideos returns the indexed videos whose transcript segments do not have the vectors of the model yet.
+// The failed videos are reattempted with backoff until they are dead-lettered.
+func (svc *dataService) RetrieveUnembeddedVideos(ctx context.Context, channelID, model string, max int) ([]Video, error) {
+	q := newVideoQuery(channelID).
+		where(`EXISTS (
+			SELECT 1 FROM transcript_segments s 
+			WHERE s.channel_id = videos.channel_id 
+			AND s.video_id = videos.video_id 
+			AND NOT EXISTS (SELECT 1 FROM transcript_embeddings e WHERE e.segment_id = s.id AND e.model = ?)
+		)`, model).
+		where("embedding_status <> ?", StageStatusDeadLetter).
+		where("COALESCE(embedding_next_attempt_at, NOW()) <= NOW()").
+		page(max, 0)
+
+	return svc.selectVideos(ctx, q)
+}
 
-	return videos, nil
+// transcribableVideoQuery selects the extracted videos that are pending transcription
+// and are published on or after the transcription cutoff date
+func (svc *dataService) transcribableVideoQuery(channelID string, audioStatus StageStatus) *videoQuery {
+	q := newVideoQuery(channelID).
+		where("availability = ?", service.AvailabilityPublic).
+		where("extraction_status = ?", StageStatusSucceeded).
+		where("audio_status = ?", audioStatus).
+		where("transcription_status = ?", StageStatusPending).
+		where("published_at >= ?", svc.ConfigSvc.GetVideoTranscriptionCutoffDate())
+
+	return q
 }
 
-func (svc *dataService) RetrieveTranscribeErroredVideos(channelID string, max int) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
+func (svc *dataService) NewVideoStatsSnapshot(ctx context.Context, video Video) error {
+	err := svc.dbConnection(ctx)
 	if err != nil {
-		return videos, err
+		return err
 	}
 
-	// The errored videos have invalid transcription URL
-	// and it has not been more than configurable hours since the transcription.
-	// The last clause is to prevent the errored videos from being picked up perpetually (i.e. cyclic extraction).
-	query := fmt.Sprintf(`
-        SELECT * FROM videos 
-		WHERE channel_id = $1 
-		AND transcribed_at is not null 
-		AND transcription_url = $2
-		AND transcribed_at >= NOW() - INTERVAL '%s'
-		ORDER BY published_at DESC 
-		LIMIT $3 
-    `, svc.ConfigSvc.GetReattemptPeriod())
+	snapshot := VideoStatsSnapshot{
+		ChannelID: video.ChannelID,
+		VideoID:   video.VideoID,
+		Views:     video.Views,
+		Comments:  video.Comments,
+		Likes:     video.Likes,
+	}
 
-	err = svc.Db.Select(&videos, query, channelID, service.InvalidURL, max)
+	rows, err := svc.Db.NamedQueryContext(ctx, svc.statement(insertvideostatssnapshotSQL), snapshot)
 	if err != nil {
-		return videos, err
+		return err
 	}
+	defer rows.Close()
 
-	return videos, nil
+	return nil
 }
 
-func (svc *dataService) RetrieveUpdatedVideos(channelID string, max int) ([]Video, error) {
-	videos := []Video{}
-	err := svc.dbConnection()
+func (svc *dataService) RetrieveVideoStats(ctx context.Context, id int64, days int) (VideoStats, error) {
+	stats := VideoStats{
+		VideoID:   id,
+		Snapshots: []VideoStatsSnapshot{},
+	}
+
+	if days <= 0 {
+		return stats, fmt.Errorf("Invalid number of days %d", days)
+	}
+
+	video, err := svc.RetrieveVideoByID(ctx, id)
 	if err != nil {
-		return videos, err
+		return stats, err
 	}
 
-	query := fmt.Sprintf(`
-        SELECT * FROM videos 
+	query := `
+        SELECT * FROM video_stats_snapshots 
 		WHERE channel_id = $1 
-		AND externalized_at is not null 
-		AND updated_at >= NOW() - INTERVAL '%s'
-		ORDER BY published_at DESC 
-		LIMIT $2 
-    `, svc.ConfigSvc.GetUpdatePeriod())
+		AND video_id = $2 
+		AND captured_at >= $3
+		ORDER BY captured_at ASC 
+    `
 
-	err = svc.Db.Select(&videos, query, channelID, max)
+	since := time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour)
+	err = svc.Db.SelectContext(ctx, &stats.Snapshots, query, video.ChannelID, video.VideoID, since)
 	if err != nil {
-		return videos, err
+		return stats, err
 	}
 
-	return videos, nil
+	stats.ViewsPerDay, stats.GrowthRate = computeViewsMetrics(stats.Snapshots, time.Now(), statsMetricsWindow)
+	return stats, nil
 }
 
-func (svc *dataService) NewJob(job Job) (int64, error) {
-	err := svc.dbConnection()
+func (svc *dataService) NewJob(ctx context.Context, job Job) (int64, error) {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return -1, err
 	}
 
 	// Execute the insert query using NamedExec or NamedQuery
-	rows, err := svc.Db.NamedQuery(insertjobSQL, job)
+	rows, err := svc.Db.NamedQueryContext(ctx, svc.statement(insertjobSQL), job)
+	if svc.dialect.isUniqueViolation(err, activeJobIndex) {
+		return -1, fmt.Errorf("%w: job type %s for channel %s is already pending", ErrJobConflict, job.Type, job.ChannelID)
+	}
 	if err != nil {
 		return -1, err
 	}
@@ -500,13 +810,13 @@ func (svc *dataService) NewJob(job Job) (int64, error) {
 	return job.ID, nil
 }
 
-func (svc *dataService) UpdateJob(job *Job) error {
-	err := svc.dbConnection()
+func (svc *dataService) UpdateJob(ctx context.Context, job *Job) error {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return err
 	}
 
-	_, err = svc.Db.Exec(updatejobSQL, job.State, job.Videos, job.Errors, job.CompletedAt, job.ID)
+	_, err = svc.Db.ExecContext(ctx, svc.statement(updatejobSQL), job.State, job.Videos, job.Errors, job.Stages, job.CompletedAt, job.ID)
 	if err != nil {
 		return err
 	}
@@ -514,62 +824,75 @@ func (svc *dataService) UpdateJob(job *Job) error {
 	return nil
 }
 
-func (svc *dataService) RetrieveJobByID(id int64) (Job, error) {
-	err := svc.dbConnection()
+func (svc *dataService) NewJobVideo(ctx context.Context, jobVideo JobVideo) (int64, error) {
+	err := svc.dbConnection(ctx)
 	if err != nil {
-		return Job{}, err
+		return -1, err
 	}
 
-	var jobs []Job
-	query := `
-        SELECT * FROM jobs 
-		WHERE id = $1 
-		LIMIT 1
-    `
+	rows, err := svc.Db.NamedQueryContext(ctx, svc.statement(insertjobvideoSQL), jobVideo)
+	if err != nil {
+		return -1, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		err = rows.Scan(&jobVideo.ID)
+		if err != nil {
+			return -1, err
+		}
+	}
 
-	err = svc.Db.Select(&jobs, query, id)
+	return jobVideo.ID, nil
+}
+
+func (svc *dataService) UpdateJobVideo(ctx context.Context, jobVideo *JobVideo) error {
+	err := svc.dbConnection(ctx)
 	if err != nil {
-		return Job{}, err
+		return err
 	}
 
-	if len(jobs) == 0 {
-		return Job{}, fmt.Errorf("Job ID %d does not exist", id)
+	_, err = svc.Db.ExecContext(ctx, svc.statement(updatejobvideoSQL), jobVideo.Error, jobVideo.CompletedAt, jobVideo.ID)
+	if err != nil {
+		return err
 	}
 
-	return jobs[0], nil
+	return nil
 }
 
-func (svc *dataService) IsPendingJobsByTypeNChannel(channelID string, jobType JobType) (bool, error) {
-	err := svc.dbConnection()
+func (svc *dataService) RetrieveJobByID(ctx context.Context, id int64) (Job, error) {
+	err := svc.dbConnection(ctx)
 	if err != nil {
-		return false, err
+		return Job{}, err
 	}
 
 	var jobs []Job
 	query := `
         SELECT * FROM jobs 
-		WHERE  channel_id = $1
-		AND type = $2
-		AND state IN ($3, $4) 
+		WHERE id = $1 
 		LIMIT 1
     `
 
-	err = svc.Db.Select(&jobs, query, channelID, jobType, JobStateQueued, JobStateRunning)
+	err = svc.Db.SelectContext(ctx, &jobs, query, id)
 	if err != nil {
-		return false, err
+		return Job{}, err
+	}
+
+	if len(jobs) == 0 {
+		return Job{}, fmt.Errorf("Job ID %d does not exist", id)
 	}
 
-	return len(jobs) > 0, nil
+	return jobs[0], nil
 }
 
-func (svc *dataService) NewAPIKey(key string) error {
-	err := svc.dbConnection()
+func (svc *dataService) NewAPIKey(ctx context.Context, key string) error {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Execute the insert query using NamedExec or NamedQuery
-	_, err = svc.Db.Exec(insertapikeySQL, key)
+	_, err = svc.Db.ExecContext(ctx, svc.statement(insertapikeySQL), key)
 	if err != nil {
 		return err
 	}
@@ -577,8 +900,8 @@ func (svc *dataService) NewAPIKey(key string) error {
 	return nil
 }
 
-func (svc *dataService) IsAPIKeyValid(key string) (bool, error) {
-	err := svc.dbConnection()
+func (svc *dataService) IsAPIKeyValid(ctx context.Context, key string) (bool, error) {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -592,7 +915,7 @@ func (svc *dataService) IsAPIKeyValid(key string) (bool, error) {
 		LIMIT 1
     `
 
-	err = svc.Db.Select(&keys, query, key)
+	err = svc.Db.SelectContext(ctx, &keys, query, key)
 	if err != nil {
 		return false, err
 	}
@@ -604,14 +927,14 @@ func (svc *dataService) IsAPIKeyValid(key string) (bool, error) {
 	return true, nil
 }
 
-func (svc *dataService) NewError(source, body string) error {
-	err := svc.dbConnection()
+func (svc *dataService) NewError(ctx context.Context, source, body string) error {
+	err := svc.dbConnection(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Execute the insert query using NamedExec or NamedQuery
-	_, err = svc.Db.Exec(inserterrorSQL, source, body)
+	_, err = svc.Db.ExecContext(ctx, svc.statement(inserterrorSQL), source, body)
 	if err != nil {
 		return err
 	}
@@ -619,26 +942,179 @@ func (svc *dataService) NewError(source, body string) error {
 	return nil
 }
 
+// NewCookies stores the yt-dlp cookies encrypted
+func (svc *dataService) NewCookies(ctx context.Context, content []byte) error {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := utils.Encrypt(svc.ConfigSvc.GetCookiesEncryptionKey(), content)
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.Db.ExecContext(ctx, svc.statement(insertcookiesSQL), encrypted)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RetrieveCookies returns the most recently stored yt-dlp cookies or nil if there are none
+func (svc *dataService) RetrieveCookies(ctx context.Context) ([]byte, error) {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents [][]byte
+	query := `
+        SELECT content 
+		FROM cookies 
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+    `
+
+	err = svc.Db.SelectContext(ctx, &contents, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) == 0 {
+		return nil, nil
+	}
+
+	return utils.Decrypt(svc.ConfigSvc.GetCookiesEncryptionKey(), contents[0])
+}
+
+// Ping makes sure the database is reachable
+func (svc *dataService) Ping(ctx context.Context) error {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	return svc.Db.PingContext(ctx)
+}
+
 func (svc *dataService) Finalize() {
 	if svc.Db != nil {
 		svc.Db.Close()
 	}
 }
 
-func (svc *dataService) dbConnection() error {
-	var err error
-	if svc.Db != nil {
-		return nil
+// computeViewsMetrics derives the average views per day and the views growth rate
+// (i.e. 0.25 means 25% more views) from the snapshots captured within the window.
+// The snapshots must be sorted by capture time.
+func computeViewsMetrics(snapshots []VideoStatsSnapshot, now time.Time, window time.Duration) (float64, float64) {
+	var first, last *VideoStatsSnapshot
+	for i := range snapshots {
+		if snapshots[i].CapturedAt.Before(now.Add(-window)) {
+			continue
+		}
+		if first == nil {
+			first = &snapshots[i]
+		}
+		last = &snapshots[i]
+	}
+
+	// At least two snapshots are needed to derive a trend
+	if first == nil || first == last {
+		return 0, 0
 	}
 
-	// Allow one thread to access the database at a time
+	views := float64(last.Views - first.Views)
+	days := last.CapturedAt.Sub(first.CapturedAt).Hours() / 24
+	viewsPerDay := 0.0
+	if days > 0 {
+		viewsPerDay = views / days
+	}
+
+	growthRate := 0.0
+	if first.Views > 0 {
+		growthRate = views / float64(first.Views)
+	}
+
+	return viewsPerDay, growthRate
+}
+
+// isAttributesChanged compares the Youtube-sourced attributes of the stored and refreshed videos
+func isAttributesChanged(stored, refreshed Video) bool {
+	return stored.Views != refreshed.Views ||
+		stored.Comments != refreshed.Comments ||
+		stored.Likes != refreshed.Likes ||
+		stored.Description != refreshed.Description ||
+		!slices.Equal(stored.Tags, refreshed.Tags) ||
+		!maps.Equal(stored.Thumbnails, refreshed.Thumbnails) ||
+		stored.CategoryID != refreshed.CategoryID ||
+		stored.DefaultAudioLanguage != refreshed.DefaultAudioLanguage ||
+		stored.Caption != refreshed.Caption ||
+		stored.LiveBroadcastContent != refreshed.LiveBroadcastContent ||
+		stored.PrivacyStatus != refreshed.PrivacyStatus ||
+		stored.Availability != refreshed.Availability ||
+		stored.Short != refreshed.Short
+}
+
+func (svc *dataService) dbConnection(ctx context.Context) error {
+	// Allow one thread to connect to the database at a time
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	svc.Db, err = sqlx.Connect("postgres", svc.ConfigSvc.GetDbDSN())
+	if svc.Db != nil {
+		return nil
+	}
+
+	d, ok := dialects[svc.ConfigSvc.GetDbProvider()]
+	if !ok {
+		return fmt.Errorf("database provider %s not found", svc.ConfigSvc.GetDbProvider())
+	}
+
+	db, err := sqlx.ConnectContext(ctx, d.driver(), d.dsn(svc.ConfigSvc.GetDbDSN()))
 	if err != nil {
 		return err
 	}
 
+	db.SetMaxOpenConns(svc.ConfigSvc.GetDbMaxOpenConns())
+	db.SetMaxIdleConns(svc.ConfigSvc.GetDbMaxIdleConns())
+	db.SetConnMaxLifetime(time.Duration(svc.ConfigSvc.GetDbConnMaxLifetime()) * time.Minute)
+	svc.Db = db
+	svc.dialect = d
+
 	return nil
 }
+
+// sqlStatement is a SQL statement keyed by dialect
+type sqlStatement map[string]string
+
+// mustStatement loads the SQL statement of the file (i.e. insertvideo.sql) for every dialect.
+// A dialect override replaces the shared statement; otherwise the dialect translates it.
+func mustStatement(file string) sqlStatement {
+	content, err := statementsFS.ReadFile(path.Join("sql", file))
+	if err != nil {
+		panic(fmt.Sprintf("statement %s does not exist: %v", file, err))
+	}
+
+	stmt := sqlStatement{}
+	for name, d := range dialects {
+		override, err := statementsFS.ReadFile(path.Join("sql", name, file))
+		if err != nil {
+			override = content
+		}
+		stmt[name] = d.translate(string(override))
+	}
+
+	return stmt
+}
+
+// stageStatement returns the SQL statement of the dialect for the stage columns (i.e. %[1]s_status).
+// The prefix is replaced rather than formatted since the dialect SQL may have % (i.e. strftime).
+func (svc *dataService) stageStatement(stmt sqlStatement, stage Stage) string {
+	return strings.ReplaceAll(svc.statement(stmt), "%[1]s", string(stage))
+}
+
+// statement returns the SQL statement of the dialect
+func (svc *dataService) statement(stmt sqlStatement) string {
+	return stmt[svc.dialect.name()]
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"path"
+	"testing"
+	"time"
+)
+
+// A dialect override of a statement that does not exist would never run
+func TestStatementOverrides(t *testing.T) {
+	for name := range dialects {
+		entries, err := statementsFS.ReadDir(path.Join("sql", name))
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			_, err := statementsFS.ReadFile(path.Join("sql", entry.Name()))
+			if err != nil {
+				t.Errorf("%s override %s does not override a statement", name, entry.Name())
+			}
+		}
+	}
+}
+
+func TestComputeViewsMetrics(t *testing.T) {
+	now := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	snapshots := []VideoStatsSnapshot{
+		// Outside the 7-day window and must be ignored
+		{Views: 10, CapturedAt: now.Add(-10 * 24 * time.Hour)},
+		{Views: 100, CapturedAt: now.Add(-4 * 24 * time.Hour)},
+		{Views: 150, CapturedAt: now.Add(-2 * 24 * time.Hour)},
+		{Views: 300, CapturedAt: now},
+	}
+
+	viewsPerDay, growthRate := computeViewsMetrics(snapshots, now, statsMetricsWindow)
+	if viewsPerDay != 50 {
+		t.Errorf("expected 50 views per day, got %f", viewsPerDay)
+	}
+	if growthRate != 2 {
+		t.Errorf("expected growth rate of 2, got %f", growthRate)
+	}
+
+	viewsPerDay, growthRate = computeViewsMetrics(snapshots[3:], now, statsMetricsWindow)
+	if viewsPerDay != 0 || growthRate != 0 {
+		t.Errorf("expected no metrics for a single snapshot, got %f and %f", viewsPerDay, growthRate)
+	}
+}
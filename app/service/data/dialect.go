@@ -0,0 +1,275 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq" // Import the PostgreSQL driver
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// dialect hides the differences between the database providers.
+// The SQL statements and the migrations are shared and written for Postgres: a dialect translates
+// the syntax that it does not support and only overrides the SQL that cannot be translated
+// (i.e. the full-text search) with files of the same name in `sql/<dialect>` and `sql/migrations/<dialect>`.
+type dialect interface {
+	// name is the provider name and the folder of the dialect SQL overrides
+	name() string
+	// driver is the database/sql driver name
+	driver() string
+	// dsn adjusts the configured data source name for the driver
+	dsn(dsn string) string
+	// tableExists returns the SQL query that tells whether the table exists
+	tableExists(table string) string
+	// lock serializes the migrators (i.e. several app instances) on the connection
+	lock(ctx context.Context, conn *sqlx.Conn) error
+	unlock(ctx context.Context, conn *sqlx.Conn) error
+	// isUniqueViolation tells whether the error is a violation of the unique index
+	isUniqueViolation(err error, index string) bool
+	// reportsInserts tells whether the video upsert reports the inserted videos
+	reportsInserts() bool
+	// searchQuery turns the search text into the full-text query of the dialect
+	searchQuery(text string) string
+	// translate rewrites the shared (i.e. Postgres) SQL into the SQL of the dialect
+	translate(sql string) string
+}
+
+var dialects = map[string]dialect{
+	"postgres": postgresDialect{},
+	"sqlite":   sqliteDialect{rewrites: sqliteRewrites},
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) name() string {
+	return "postgres"
+}
+
+func (postgresDialect) driver() string {
+	return "postgres"
+}
+
+func (postgresDialect) dsn(dsn string) string {
+	return dsn
+}
+
+func (postgresDialect) tableExists(table string) string {
+	return fmt.Sprintf("SELECT to_regclass('public.%s') IS NOT NULL", table)
+}
+
+func (postgresDialect) lock(ctx context.Context, conn *sqlx.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationsLockID)
+	return err
+}
+
+func (postgresDialect) unlock(ctx context.Context, conn *sqlx.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationsLockID)
+	return err
+}
+
+func (postgresDialect) isUniqueViolation(err error, index string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	return pqErr.Code == uniqueViolationCode && pqErr.Constraint == index
+}
+
+func (postgresDialect) reportsInserts() bool {
+	return true
+}
+
+// websearch_to_tsquery takes the text as is (i.e. quoted phrases and -excluded words)
+func (postgresDialect) searchQuery(text string) string {
+	return text
+}
+
+// The shared SQL is Postgres SQL so the migrations keep their checksums
+func (postgresDialect) translate(sql string) string {
+	return sql
+}
+
+// sqliteTimeFormat is how the SQLite driver writes times (i.e. `_time_format=sqlite`) so they sort as text
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func init() {
+	// SQLite does not have NOW() so the shared statements can use it as is
+	sqlite.MustRegisterScalarFunction("now", 0, func(_ *sqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+}
+
+// sqliteDialect translates the shared SQL with its rewrites
+type sqliteDialect struct {
+	rewrites []sqlRewrite
+}
+
+func (sqliteDialect) name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) driver() string {
+	return "sqlite"
+}
+
+// dsn makes the driver write times in a sortable format and wait for the locks of the other connections
+func (sqliteDialect) dsn(dsn string) string {
+	if dsn == "" {
+		dsn = "yt-extractor.db"
+	}
+
+	params := []string{}
+	if !strings.Contains(dsn, "_time_format=") {
+		params = append(params, "_time_format=sqlite")
+	}
+	if !strings.Contains(dsn, "busy_timeout") {
+		params = append(params, "_pragma=busy_timeout(5000)")
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+
+	return dsn + separator + strings.Join(params, "&")
+}
+
+func (sqliteDialect) tableExists(table string) string {
+	return fmt.Sprintf("SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = '%s'", table)
+}
+
+// SQLite databases are local to a single instance so there are no other migrators
+func (sqliteDialect) lock(_ context.Context, _ *sqlx.Conn) error {
+	return nil
+}
+
+func (sqliteDialect) unlock(_ context.Context, _ *sqlx.Conn) error {
+	return nil
+}
+
+// SQLite does not report the violated index so any unique violation of the statement counts
+func (sqliteDialect) isUniqueViolation(err error, _ string) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func (sqliteDialect) reportsInserts() bool {
+	return false
+}
+
+// searchQuery quotes the words and the phrases of the text so the FTS5 syntax (i.e. operators
+// and column filters) cannot break the query. The quoted terms must all match.
+func (sqliteDialect) searchQuery(text string) string {
+	terms := []string{}
+	for i, part := range strings.Split(text, `"`) {
+		// The odd parts are quoted phrases
+		if i%2 == 1 {
+			if strings.TrimSpace(part) != "" {
+				terms = append(terms, `"`+strings.TrimSpace(part)+`"`)
+			}
+			continue
+		}
+
+		for _, word := range strings.Fields(part) {
+			terms = append(terms, `"`+word+`"`)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// sqlRewrite replaces the matches of the pattern with the SQL that the submatches render
+type sqlRewrite struct {
+	pattern *regexp.Regexp
+	render  func(m []string) string
+}
+
+// sqliteNow is NOW() with the modifier (i.e. '+1 year') in the format of the SQLite times
+func sqliteNow(modifier string) string {
+	return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:%%M:%%f+00:00', 'now', %s)", modifier)
+}
+
+// alterColumnSeparator matches the comma before the next column of ALTER TABLE
+var alterColumnSeparator = regexp.MustCompile(`(?i),\s*((?:ADD|DROP)\s+COLUMN)`)
+
+// sqliteRewrites translate the Postgres types, intervals, TRUNCATE and the multi-column ALTER TABLE
+// that SQLite does not support
+var sqliteRewrites = []sqlRewrite{
+	{
+		pattern: regexp.MustCompile(`\bSERIAL PRIMARY KEY\b`),
+		render: func(_ []string) string {
+			return "INTEGER PRIMARY KEY AUTOINCREMENT"
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`\bBYTEA\b`),
+		render: func(_ []string) string {
+			return "BLOB"
+		},
+	},
+	{
+		// The upsert does not report the inserted videos (i.e. reportsInserts)
+		pattern: regexp.MustCompile(`\(xmax = 0\) AS inserted`),
+		render: func(_ []string) string {
+			return "false AS inserted"
+		},
+	},
+	{
+		// TRUNCATE a, b, c;
+		pattern: regexp.MustCompile(`(?i)\bTRUNCATE\s+([\w\s,]+);?`),
+		render: func(m []string) string {
+			deletes := []string{}
+			for _, table := range strings.Split(m[1], ",") {
+				deletes = append(deletes, fmt.Sprintf("DELETE FROM %s;", strings.TrimSpace(table)))
+			}
+			return strings.Join(deletes, "\n")
+		},
+	},
+	{
+		// NOW() + INTERVAL '1 minute' * (expression) with one level of nested parentheses
+		pattern: regexp.MustCompile(`(?i)NOW\(\)\s*([+-])\s*INTERVAL\s+'(\d+)\s+(\w+)'\s*\*\s*\(((?:[^()]|\([^()]*\))*)\)`),
+		render: func(m []string) string {
+			return sqliteNow(fmt.Sprintf("'%s' || (%s * (%s)) || ' %s'", m[1], m[2], m[4], strings.ToLower(m[3])))
+		},
+	},
+	{
+		// NOW() - INTERVAL '48 HOURS'
+		pattern: regexp.MustCompile(`(?i)NOW\(\)\s*([+-])\s*INTERVAL\s+'(\d+)\s+(\w+)'`),
+		render: func(m []string) string {
+			return sqliteNow(fmt.Sprintf("'%s%s %s'", m[1], m[2], strings.ToLower(m[3])))
+		},
+	},
+	{
+		// SQLite adds or drops one column per ALTER TABLE
+		pattern: regexp.MustCompile(`(?is)ALTER TABLE\s+(\w+)\s+((?:ADD|DROP)\s+COLUMN[^;]*);`),
+		render: func(m []string) string {
+			return "ALTER TABLE " + m[1] + "\n" + alterColumnSeparator.ReplaceAllString(m[2], ";\n\nALTER TABLE "+m[1]+"\n$1") + ";"
+		},
+	},
+}
+
+// translate applies the rewrites of the dialect in order
+func (d sqliteDialect) translate(sql string) string {
+	for _, rewrite := range d.rewrites {
+		sql = rewrite.pattern.ReplaceAllStringFunc(sql, func(match string) string {
+			return rewrite.render(rewrite.pattern.FindStringSubmatch(match))
+		})
+	}
+
+	return sql
+}
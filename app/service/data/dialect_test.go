@@ -0,0 +1,63 @@
+package data
+
+import (
+	"testing"
+)
+
+func TestSQLiteTranslate(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected string
+	}{
+		{
+			name:     "serial key",
+			sql:      "id SERIAL PRIMARY KEY,",
+			expected: "id INTEGER PRIMARY KEY AUTOINCREMENT,",
+		},
+		{
+			name:     "bytea",
+			sql:      "content BYTEA NOT NULL,",
+			expected: "content BLOB NOT NULL,",
+		},
+		{
+			name:     "inserted rows",
+			sql:      "RETURNING id, (xmax = 0) AS inserted",
+			expected: "RETURNING id, false AS inserted",
+		},
+		{
+			name:     "truncate",
+			sql:      "TRUNCATE videos, jobs;",
+			expected: "DELETE FROM videos;\nDELETE FROM jobs;",
+		},
+		{
+			name:     "fixed interval",
+			sql:      "extracted_at < NOW() - INTERVAL '48 HOURS';",
+			expected: "extracted_at < strftime('%Y-%m-%d %H:%M:%f+00:00', 'now', '-48 hours');",
+		},
+		{
+			name:     "scaled interval",
+			sql:      "THEN NOW() + INTERVAL '1 minute' * ($5 * POWER(2, audio_attempts)) ELSE NULL END",
+			expected: "THEN strftime('%Y-%m-%d %H:%M:%f+00:00', 'now', '+' || (1 * ($5 * POWER(2, audio_attempts))) || ' minute') ELSE NULL END",
+		},
+		{
+			name:     "several columns",
+			sql:      "ALTER TABLE videos\nADD COLUMN a TEXT,\nADD COLUMN b INT NOT NULL DEFAULT 0;",
+			expected: "ALTER TABLE videos\nADD COLUMN a TEXT;\n\nALTER TABLE videos\nADD COLUMN b INT NOT NULL DEFAULT 0;",
+		},
+		{
+			name:     "one column",
+			sql:      "ALTER TABLE videos\nDROP COLUMN a;",
+			expected: "ALTER TABLE videos\nDROP COLUMN a;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			translated := dialects["sqlite"].translate(test.sql)
+			if translated != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, translated)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed sql/migrations/*.sql sql/migrations/sqlite/*.sql
+var migrationsFS embed.FS
+
+//go:embed sql/createschemamigrations.sql
+var createschemamigrationsSQL string
+
+//go:embed sql/insertschemamigration.sql
+var insertschemamigrationSQL string
+
+// migrationsLockID is the Postgres advisory lock that serializes the migrators (i.e. several app instances)
+const migrationsLockID = 20261019
+
+// migrationFileName matches the migration files i.e. 0001_create_videos_table.up.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// embeddedMigrations returns the migrations embedded in the binary ordered by version.
+// The dialect overrides (i.e. sql/migrations/sqlite) replace the shared migration files of the same name
+// and the dialect translates the others.
+func embeddedMigrations(d dialect) ([]Migration, error) {
+	entries, err := migrationsFS.ReadDir("sql/migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := migrationFileName.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("migration file %s is not named version_name.(up|down).sql", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		content, err := migrationsFS.ReadFile(path.Join("sql/migrations", d.name(), entry.Name()))
+		if err != nil {
+			content, err = migrationsFS.ReadFile(path.Join("sql/migrations", entry.Name()))
+		}
+		if err != nil {
+			return nil, err
+		}
+		content = []byte(d.translate(string(content)))
+
+		migration, ok := migrations[version]
+		if !ok {
+			migration = &Migration{
+				Version: version,
+				Name:    matches[2],
+			}
+			migrations[version] = migration
+		}
+
+		if migration.Name != matches[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.Up = string(content)
+			checksum := sha256.Sum256(content)
+			migration.Checksum = hex.EncodeToString(checksum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	ordered := []Migration{}
+	for _, migration := range migrations {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d (%s) must have both up and down files", migration.Version, migration.Name)
+		}
+		ordered = append(ordered, *migration)
+	}
+	slices.SortFunc(ordered, func(a, b Migration) int {
+		return int(a.Version - b.Version)
+	})
+
+	return ordered, nil
+}
+
+// checkDrift makes sure that the applied migrations are the embedded ones.
+// Migrations that are unknown to the binary or that changed after they were applied are drifts.
+func checkDrift(embedded, applied []Migration) error {
+	for _, a := range applied {
+		i := slices.IndexFunc(embedded, func(e Migration) bool {
+			return e.Version == a.Version
+		})
+		if i < 0 {
+			return fmt.Errorf("schema drift: applied migration %d (%s) is unknown to this binary", a.Version, a.Name)
+		}
+
+		if embedded[i].Checksum != a.Checksum {
+			return fmt.Errorf("schema drift: migration %d (%s) changed after it was applied", a.Version, a.Name)
+		}
+	}
+
+	return nil
+}
+
+// pendingMigrations returns the embedded migrations that are not applied yet
+func pendingMigrations(embedded, applied []Migration) []Migration {
+	pending := []Migration{}
+	for _, e := range embedded {
+		if !slices.ContainsFunc(applied, func(a Migration) bool {
+			return a.Version == e.Version
+		}) {
+			pending = append(pending, e)
+		}
+	}
+
+	return pending
+}
+
+func (svc *dataService) MigrateUp(ctx context.Context) ([]Migration, error) {
+	migrated := []Migration{}
+	err := svc.withMigrationsLock(ctx, func(conn *sqlx.Conn) error {
+		embedded, applied, err := svc.migrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// A database whose schema was applied by hand must be baselined first
+		if len(applied) == 0 {
+			var exists bool
+			err = conn.GetContext(ctx, &exists, svc.dialect.tableExists("videos"))
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				return fmt.Errorf("the database schema predates the schema migrations: baseline it with `migrate baseline <version>`")
+			}
+		}
+
+		for _, migration := range pendingMigrations(embedded, applied) {
+			err = applyMigration(ctx, conn, migration.Up, func(tx *sqlx.Tx) error {
+				now := time.Now()
+				migration.AppliedAt = &now
+				_, err := tx.NamedExecContext(ctx, insertschemamigrationSQL, migration)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+
+			migrated = append(migrated, migration)
+		}
+
+		return nil
+	})
+
+	return migrated, err
+}
+
+func (svc *dataService) MigrateDown(ctx context.Context) (Migration, error) {
+	var reverted Migration
+	err := svc.withMigrationsLock(ctx, func(conn *sqlx.Conn) error {
+		embedded, applied, err := svc.migrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			return fmt.Errorf("there are no migrations to revert")
+		}
+
+		latest := applied[len(applied)-1]
+		i := slices.IndexFunc(embedded, func(e Migration) bool {
+			return e.Version == latest.Version
+		})
+		reverted = embedded[i]
+
+		err = applyMigration(ctx, conn, reverted.Down, func(tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, reverted.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("reverting migration %d (%s) failed: %w", reverted.Version, reverted.Name, err)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+func (svc *dataService) BaselineMigrations(ctx context.Context, version int64) error {
+	return svc.withMigrationsLock(ctx, func(conn *sqlx.Conn) error {
+		embedded, applied, err := svc.migrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if len(applied) > 0 {
+			return fmt.Errorf("the database is already migrated up to version %d", applied[len(applied)-1].Version)
+		}
+
+		// Record the migrations as applied without running them
+		return applyMigration(ctx, conn, "", func(tx *sqlx.Tx) error {
+			now := time.Now()
+			for _, migration := range embedded {
+				if migration.Version > version {
+					break
+				}
+
+				migration.AppliedAt = &now
+				_, err := tx.NamedExecContext(ctx, insertschemamigrationSQL, migration)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+func (svc *dataService) RetrieveMigrations(ctx context.Context) ([]Migration, error) {
+	var migrations []Migration
+	err := svc.withMigrationsLock(ctx, func(conn *sqlx.Conn) error {
+		embedded, applied, err := svc.migrations(ctx, conn)
+		if embedded == nil {
+			return err
+		}
+
+		// The embedded migrations along with when they were applied (if they were)
+		for _, e := range embedded {
+			i := slices.IndexFunc(applied, func(a Migration) bool {
+				return a.Version == e.Version
+			})
+			if i >= 0 {
+				e.AppliedAt = applied[i].AppliedAt
+			}
+			migrations = append(migrations, e)
+		}
+
+		// The applied migrations that are unknown to the binary are reported as well
+		for _, a := range applied {
+			if !slices.ContainsFunc(embedded, func(e Migration) bool {
+				return e.Version == a.Version
+			}) {
+				migrations = append(migrations, a)
+			}
+		}
+
+		// The drift error (if any) is returned along with the migrations
+		return err
+	})
+
+	return migrations, err
+}
+
+func (svc *dataService) CheckMigrations(ctx context.Context) error {
+	return svc.withMigrationsLock(ctx, func(conn *sqlx.Conn) error {
+		embedded, applied, err := svc.migrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		pending := pendingMigrations(embedded, applied)
+		if len(pending) > 0 {
+			return fmt.Errorf("the database schema is behind: migration %d (%s) is pending", pending[0].Version, pending[0].Name)
+		}
+
+		return nil
+	})
+}
+
+// migrations returns the embedded and the applied migrations (ordered by version).
+// It fails if the applied migrations drifted from the embedded ones (i.e. the drift error).
+func (svc *dataService) migrations(ctx context.Context, conn *sqlx.Conn) ([]Migration, []Migration, error) {
+	embedded, err := embeddedMigrations(svc.dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied := []Migration{}
+	err = conn.SelectContext(ctx, &applied, `SELECT * FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return embedded, applied, checkDrift(embedded, applied)
+}
+
+// withMigrationsLock runs the function on a dedicated connection while holding the migrations lock
+func (svc *dataService) withMigrationsLock(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn, err := svc.Db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = svc.dialect.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// The lock is released even if the context is cancelled because the connection goes back to the pool
+		_ = svc.dialect.unlock(context.WithoutCancel(ctx), conn)
+	}()
+
+	_, err = conn.ExecContext(ctx, createschemamigrationsSQL)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// applyMigration runs the migration SQL and records it in one transaction
+func applyMigration(ctx context.Context, conn *sqlx.Conn, migrationSQL string, record func(tx *sqlx.Tx) error) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if migrationSQL != "" {
+		_, err = tx.ExecContext(ctx, migrationSQL)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"testing"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	for name, d := range dialects {
+		migrations, err := embeddedMigrations(d)
+		if err != nil {
+			t.Fatalf("unexpected %s error %v", name, err)
+		}
+
+		// Versions must be contiguous so the order is unambiguous
+		for i, migration := range migrations {
+			if migration.Version != int64(i+1) {
+				t.Errorf("expected %s version %d, got %d (%s)", name, i+1, migration.Version, migration.Name)
+			}
+
+			if migration.Checksum == "" {
+				t.Errorf("expected %s migration %d to have a checksum", name, migration.Version)
+			}
+		}
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	embedded := []Migration{
+		{Version: 1, Name: "one", Checksum: "a"},
+		{Version: 2, Name: "two", Checksum: "b"},
+	}
+
+	err := checkDrift(embedded, []Migration{{Version: 1, Name: "one", Checksum: "a"}})
+	if err != nil {
+		t.Errorf("unexpected drift %v", err)
+	}
+
+	err = checkDrift(embedded, []Migration{{Version: 1, Name: "one", Checksum: "changed"}})
+	if err == nil {
+		t.Errorf("expected a drift for a changed migration")
+	}
+
+	err = checkDrift(embedded, []Migration{{Version: 3, Name: "three", Checksum: "c"}})
+	if err == nil {
+		t.Errorf("expected a drift for an unknown migration")
+	}
+
+	pending := pendingMigrations(embedded, []Migration{{Version: 1, Name: "one", Checksum: "a"}})
+	if len(pending) != 1 || pending[0].Version != 2 {
+		t.Errorf("unexpected pending migrations %v", pending)
+	}
+}
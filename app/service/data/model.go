@@ -1,27 +1,333 @@
 package data
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/khaledhikmat/yt-extractor/service"
+)
+
+// StringList is stored as a JSONB array (i.e. video tags)
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *StringList) Scan(src interface{}) error {
+	return scanJSON(src, l)
+}
+
+// StringMap is stored as a JSONB object (i.e. video thumbnail URLs keyed by size)
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *StringMap) Scan(src interface{}) error {
+	return scanJSON(src, m)
+}
+
+// StageCounts is stored as a JSONB object (i.e. automation job counts keyed by pipeline stage)
+type StageCounts map[string]StageCount
+
+// StageCount is the number of videos that a pipeline stage processed and how many of them errored
+type StageCount struct {
+	Videos int64 `json:"videos"`
+	Errors int64 `json:"errors"`
+}
+
+func (m StageCounts) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *StageCounts) Scan(src interface{}) error {
+	return scanJSON(src, m)
+}
+
+// Vector is stored as the little-endian bytes of its float32 values (i.e. transcript embeddings)
+type Vector []float32
+
+func (v Vector) Value() (driver.Value, error) {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b, nil
+}
+
+func (v *Vector) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok || len(b)%4 != 0 {
+		return fmt.Errorf("unsupported vector column type %T", src)
+	}
+
+	vector := make(Vector, len(b)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	*v = vector
+	return nil
+}
+
+func scanJSON(src interface{}, dest interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("unsupported JSON column type %T", src)
+	}
+}
 
 type Video struct {
-	ID               int64      `json:"id" db:"id"`
-	ChannelID        string     `json:"channelId" db:"channel_id"`
-	VideoID          string     `json:"videoId" db:"video_id"`
-	VideoURL         string     `json:"videoUrl" db:"video_url"`
-	Title            string     `json:"title" db:"title"`
-	PublishedAt      time.Time  `json:"publishedAt" db:"published_at"`
-	Views            int64      `json:"views" db:"views"`
-	Comments         int64      `json:"comments" db:"comments"`
-	Likes            int64      `json:"likes" db:"likes"`
-	Duration         int64      `json:"duration" db:"duration"`
-	Short            bool       `json:"short" db:"short"`
-	UpdatedAt        time.Time  `json:"updatedAt" db:"updated_at"`
-	ExtractionURL    *string    `json:"extractionUrl" db:"extraction_url"`
-	ExtractedAt      *time.Time `json:"extractedAt" db:"extracted_at"`
-	ExternalizedAt   *time.Time `json:"externalizedAt" db:"externalized_at"`
-	AudioURL         *string    `json:"audioUrl" db:"audio_url"`
-	AudioedAt        *time.Time `json:"audioedAt" db:"audioed_at"`
-	TranscriptionURL *string    `json:"transcriptionUrl" db:"transcription_url"`
-	TranscribedAt    *time.Time `json:"transcribedAt" db:"transcribed_at"`
+	ID                         int64                `json:"id" db:"id"`
+	ChannelID                  string               `json:"channelId" db:"channel_id"`
+	VideoID                    string               `json:"videoId" db:"video_id"`
+	VideoURL                   string               `json:"videoUrl" db:"video_url"`
+	Title                      string               `json:"title" db:"title"`
+	PublishedAt                time.Time            `json:"publishedAt" db:"published_at"`
+	Views                      int64                `json:"views" db:"views"`
+	Comments                   int64                `json:"comments" db:"comments"`
+	Likes                      int64                `json:"likes" db:"likes"`
+	Duration                   int64                `json:"duration" db:"duration"`
+	Short                      bool                 `json:"short" db:"short"`
+	ShortClassifiedAt          *time.Time           `json:"shortClassifiedAt" db:"short_classified_at"`
+	Description                string               `json:"description" db:"description"`
+	Tags                       StringList           `json:"tags" db:"tags"`
+	Thumbnails                 StringMap            `json:"thumbnails" db:"thumbnails"`
+	CategoryID                 string               `json:"categoryId" db:"category_id"`
+	DefaultAudioLanguage       string               `json:"defaultAudioLanguage" db:"default_audio_language"`
+	Caption                    bool                 `json:"caption" db:"caption"`
+	LiveBroadcastContent       string               `json:"liveBroadcastContent" db:"live_broadcast_content"`
+	PrivacyStatus              string               `json:"privacyStatus" db:"privacy_status"`
+	Availability               service.Availability `json:"availability" db:"availability"`
+	UpdatedAt                  time.Time            `json:"updatedAt" db:"updated_at"`
+	ExtractionURL              *string              `json:"extractionUrl" db:"extraction_url"`
+	ExtractedAt                *time.Time           `json:"extractedAt" db:"extracted_at"`
+	ExtractionStatus           StageStatus          `json:"extractionStatus" db:"extraction_status"`
+	ExtractionError            *string              `json:"extractionError" db:"extraction_error"`
+	FormatIDs                  *string              `json:"formatIds" db:"format_ids"`
+	ExtractionErrorClass       service.ErrorClass   `json:"extractionErrorClass" db:"extraction_error_class"`
+	ExtractionAttempts         int64                `json:"extractionAttempts" db:"extraction_attempts"`
+	ExtractionAttemptedAt      *time.Time           `json:"extractionAttemptedAt" db:"extraction_attempted_at"`
+	ExtractionNextAttemptAt    *time.Time           `json:"extractionNextAttemptAt" db:"extraction_next_attempt_at"`
+	ExtractionStartedAt        *time.Time           `json:"extractionStartedAt" db:"extraction_started_at"`
+	ExternalizedAt             *time.Time           `json:"externalizedAt" db:"externalized_at"`
+	AudioURL                   *string              `json:"audioUrl" db:"audio_url"`
+	AudioedAt                  *time.Time           `json:"audioedAt" db:"audioed_at"`
+	AudioStatus                StageStatus          `json:"audioStatus" db:"audio_status"`
+	AudioAttempts              int64                `json:"audioAttempts" db:"audio_attempts"`
+	AudioNextAttemptAt         *time.Time           `json:"audioNextAttemptAt" db:"audio_next_attempt_at"`
+	AudioStartedAt             *time.Time           `json:"audioStartedAt" db:"audio_started_at"`
+	AudioError                 *string              `json:"audioError" db:"audio_error"`
+	TranscriptionURL           *string              `json:"transcriptionUrl" db:"transcription_url"`
+	TranscribedAt              *time.Time           `json:"transcribedAt" db:"transcribed_at"`
+	TranscriptionStatus        StageStatus          `json:"transcriptionStatus" db:"transcription_status"`
+	TranscriptionAttempts      int64                `json:"transcriptionAttempts" db:"transcription_attempts"`
+	TranscriptionNextAttemptAt *time.Time           `json:"transcriptionNextAttemptAt" db:"transcription_next_attempt_at"`
+	TranscriptionStartedAt     *time.Time           `json:"transcriptionStartedAt" db:"transcription_started_at"`
+	TranscriptionError         *string              `json:"transcriptionError" db:"transcription_error"`
+	TranscriptionSource        *string              `json:"transcriptionSource" db:"transcription_source"`
+	SubtitlesURL               *string              `json:"subtitlesUrl" db:"subtitles_url"`
+	IndexedAt                  *time.Time           `json:"indexedAt" db:"indexed_at"`
+	CaptionsAttemptedAt        *time.Time           `json:"captionsAttemptedAt" db:"captions_attempted_at"`
+	CaptionsError              *string              `json:"captionsError" db:"captions_error"`
+	EmbeddingStatus            StageStatus          `json:"embeddingStatus" db:"embedding_status"`
+	EmbeddingAttempts          int64                `json:"embeddingAttempts" db:"embedding_attempts"`
+	EmbeddingNextAttemptAt     *time.Time           `json:"embeddingNextAttemptAt" db:"embedding_next_attempt_at"`
+	EmbeddingStartedAt         *time.Time           `json:"embeddingStartedAt" db:"embedding_started_at"`
+	EmbeddingError             *string              `json:"embeddingError" db:"embedding_error"`
+	// PreviousStatus is the status of the stage the video was last moved within (see UpdateVideoStageStatus).
+	// It is not stored: it lets a stage restore the video if its processing is cancelled.
+	PreviousStatus StageStatus `json:"-" db:"-"`
+}
+
+// UpsertedVideo tells whether an upserted video was inserted or updated
+type UpsertedVideo struct {
+	ID        int64  `json:"id" db:"id"`
+	ChannelID string `json:"channelId" db:"channel_id"`
+	VideoID   string `json:"videoId" db:"video_id"`
+	Inserted  bool   `json:"inserted" db:"inserted"`
+}
+
+// IsPublishedSince tells whether the video was published on or after the cutoff date
+func (v Video) IsPublishedSince(cutoff time.Time) bool {
+	return !v.PublishedAt.Before(cutoff)
+}
+
+// StageStatus denotes where a video is in a pipeline stage (i.e. extraction, audio or transcription)
+type StageStatus string
+
+const (
+	StageStatusPending         StageStatus = "pending"
+	StageStatusInProgress      StageStatus = "in_progress"
+	StageStatusWaitingExternal StageStatus = "waiting_external"
+	StageStatusSucceeded       StageStatus = "succeeded"
+	StageStatusFailed          StageStatus = "failed"
+	StageStatusDeadLetter      StageStatus = "dead_letter"
+)
+
+// Stage is a video pipeline stage. It is also the prefix of the stage columns.
+type Stage string
+
+const (
+	StageExtraction    Stage = "extraction"
+	StageAudio         Stage = "audio"
+	StageTranscription Stage = "transcription"
+	StageEmbedding     Stage = "embedding"
+)
+
+// isValid tells whether the stage is one of the pipeline stages
+func (s Stage) isValid() bool {
+	return s == StageExtraction || s == StageAudio || s == StageTranscription || s == StageEmbedding
+}
+
+// Denotes where the video transcription came from
+const (
+	TranscriptionSourceCaptions = "captions"
+	TranscriptionSourceAudio    = "audio"
+)
+
+// VideoFilter selects the channel videos. The zero value of a filter (i.e. nil or empty) does not filter.
+type VideoFilter struct {
+	ChannelID     string
+	Title         string
+	PublishedFrom *time.Time
+	PublishedTo   *time.Time
+	// PublishedBefore excludes the time unlike PublishedTo (i.e. the day after a date-only range end)
+	PublishedBefore     *time.Time
+	MinDuration         *int64
+	MaxDuration         *int64
+	Short               *bool
+	ExtractionStatus    StageStatus
+	AudioStatus         StageStatus
+	TranscriptionStatus StageStatus
+	MinViews            *int64
+	HasTranscript       *bool
+	OrderBy             string
+	OrderDir            string
+	// Cursor is the next cursor of the previous page. It is empty for the first page.
+	Cursor   string
+	PageSize int
+}
+
+// VideoPage is a page of the filtered videos. Total counts all the filtered videos
+// and NextCursor is empty on the last page.
+type VideoPage struct {
+	Videos     []Video `json:"videos"`
+	Total      int64   `json:"total"`
+	NextCursor string  `json:"nextCursor"`
+}
+
+type VideoStatsSnapshot struct {
+	ID         int64     `json:"id" db:"id"`
+	ChannelID  string    `json:"channelId" db:"channel_id"`
+	VideoID    string    `json:"videoId" db:"video_id"`
+	Views      int64     `json:"views" db:"views"`
+	Comments   int64     `json:"comments" db:"comments"`
+	Likes      int64     `json:"likes" db:"likes"`
+	CapturedAt time.Time `json:"capturedAt" db:"captured_at"`
+}
+
+// VideoStats is the time-series of a video statistics along with metrics derived
+// from the snapshots captured within the last 7 days
+type VideoStats struct {
+	VideoID     int64                `json:"videoId"`
+	Snapshots   []VideoStatsSnapshot `json:"snapshots"`
+	ViewsPerDay float64              `json:"viewsPerDay"`
+	GrowthRate  float64              `json:"growthRate"`
+}
+
+// TranscriptSegment is a searchable part of a video transcript. Segments made from captions
+// have the time range that they were said in. Segments made from plain text do not.
+type TranscriptSegment struct {
+	ID        int64  `json:"id" db:"id"`
+	ChannelID string `json:"channelId" db:"channel_id"`
+	VideoID   string `json:"videoId" db:"video_id"`
+	Position  int    `json:"position" db:"position"`
+	StartMs   *int64 `json:"startMs" db:"start_ms"`
+	EndMs     *int64 `json:"endMs" db:"end_ms"`
+	Text      string `json:"text" db:"text"`
+}
+
+// SearchResult is a video whose transcript matches the search along with its best matching snippets
+type SearchResult struct {
+	ID          int64           `json:"id"`
+	ChannelID   string          `json:"channelId"`
+	VideoID     string          `json:"videoId"`
+	Title       string          `json:"title"`
+	VideoURL    string          `json:"videoUrl"`
+	PublishedAt time.Time       `json:"publishedAt"`
+	Rank        float64         `json:"rank"`
+	Snippets    []SearchSnippet `json:"snippets"`
+}
+
+// SearchSnippet is an HTML-escaped transcript snippet whose matches are highlighted (i.e. <b>match</b>).
+// Start (in seconds) and URL jump to the snippet within the video if the transcript has timestamps.
+type SearchSnippet struct {
+	Snippet string `json:"snippet"`
+	Start   *int64 `json:"start"`
+	URL     string `json:"url"`
+}
+
+// TranscriptEmbedding is the vector of a transcript segment according to an embedding model
+type TranscriptEmbedding struct {
+	SegmentID int64  `json:"segmentId" db:"segment_id"`
+	Model     string `json:"model" db:"model"`
+	Vector    Vector `json:"vector" db:"vector"`
+}
+
+// Passage is a transcript segment that is similar to a search (i.e. semantic search) along with its video.
+// Start (in seconds) and URL jump to the passage within the video if the transcript has timestamps.
+type Passage struct {
+	SegmentID   int64     `json:"segmentId" db:"id"`
+	ChannelID   string    `json:"channelId" db:"channel_id"`
+	VideoID     string    `json:"videoId" db:"video_id"`
+	Title       string    `json:"title" db:"title"`
+	VideoURL    string    `json:"videoUrl" db:"video_url"`
+	PublishedAt time.Time `json:"publishedAt" db:"published_at"`
+	Text        string    `json:"text" db:"text"`
+	StartMs     *int64    `json:"-" db:"start_ms"`
+	Start       *int64    `json:"start" db:"-"`
+	URL         string    `json:"url" db:"-"`
+	Score       float64   `json:"score" db:"-"`
+}
+
+// Question is a question about the transcripts of a channel.
+// K is the number of passages that the answer draws on.
+type Question struct {
+	Question string `json:"question"`
+	K        int    `json:"k"`
+}
+
+// Citation is a passage that an answer cites by its number (i.e. [1])
+type Citation struct {
+	Number int `json:"number"`
+	Passage
+}
+
+type Answer struct {
+	Question  string     `json:"question"`
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
 }
 
 type JobState string
@@ -31,6 +337,7 @@ const (
 	JobStateRunning   JobState = "running"
 	JobStateCancelled JobState = "cancelled"
 	JobStateCompleted JobState = "completed"
+	JobStateFailed    JobState = "failed"
 )
 
 type JobType string
@@ -44,33 +351,59 @@ const (
 	JobTypeAudioError         JobType = "audioerror"
 	JobTypeTranscription      JobType = "transcription"
 	JobTypeTranscriptionError JobType = "transcriptionerror"
+	JobTypeCaptions           JobType = "captions"
 	JobTypeAutomation         JobType = "automation"
+	JobTypeShorts             JobType = "shorts"
+	JobTypeIndexing           JobType = "indexing"
+	JobTypeEmbedding          JobType = "embedding"
 )
 
 type Job struct {
-	ID          int64      `json:"id" db:"id"`
-	ChannelID   string     `json:"channelId" db:"channel_id"`
-	Type        JobType    `json:"type" db:"type"`
-	State       JobState   `json:"state" db:"state"`
-	Videos      int64      `json:"videos" db:"videos"`
-	Errors      int64      `json:"errors" db:"errors"`
-	StartedAt   time.Time  `json:"startedAt" db:"started_at"`
-	CompletedAt *time.Time `json:"completedAt" db:"completed_at"`
+	ID          int64       `json:"id" db:"id"`
+	ChannelID   string      `json:"channelId" db:"channel_id"`
+	Type        JobType     `json:"type" db:"type"`
+	State       JobState    `json:"state" db:"state"`
+	Videos      int64       `json:"videos" db:"videos"`
+	Errors      int64       `json:"errors" db:"errors"`
+	Stages      StageCounts `json:"stages" db:"stages"`
+	StartedAt   time.Time   `json:"startedAt" db:"started_at"`
+	CompletedAt *time.Time  `json:"completedAt" db:"completed_at"`
 }
 
+// JobVideo is the ledger entry of a single video processed by a job
 type JobVideo struct {
 	ID          int64      `json:"id" db:"id"`
 	JobID       int64      `json:"jobId" db:"job_id"`
 	ChannelID   string     `json:"channelId" db:"channel_id"`
-	VideolID    string     `json:"videoId" db:"video_id"`
+	VideoID     string     `json:"videoId" db:"video_id"`
 	StartedAt   time.Time  `json:"startedAt" db:"started_at"`
 	Error       *string    `json:"error" db:"error"`
 	CompletedAt *time.Time `json:"completedAt" db:"completed_at"`
 }
 
+// Migration is a versioned schema change embedded in the binary (i.e. sql/migrations)
+type Migration struct {
+	Version   int64      `json:"version" db:"version"`
+	Name      string     `json:"name" db:"name"`
+	Checksum  string     `json:"checksum" db:"checksum"`
+	AppliedAt *time.Time `json:"appliedAt" db:"applied_at"`
+	Up        string     `json:"-" db:"-"`
+	Down      string     `json:"-" db:"-"`
+}
+
 type Error struct {
 	ID         int64     `json:"id" db:"id"`
 	Source     string    `json:"source" db:"source"`
 	Body       string    `json:"body" db:"body"`
 	OccurredAt time.Time `json:"occurredAt" db:"occurred_at"`
 }
+
+// RestoredStatus is the status a video whose processing is cancelled is restored to: the status it had
+// before it went in progress or pending if it was not moved within the stage (i.e. handed over)
+func (v Video) RestoredStatus() StageStatus {
+	if v.PreviousStatus == "" || v.PreviousStatus == StageStatusInProgress {
+		return StageStatusPending
+	}
+
+	return v.PreviousStatus
+}
@@ -0,0 +1,212 @@
+package data
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// videoOrderColumns are the columns that the videos can be ordered by
+var videoOrderColumns = map[string]bool{
+	"published_at": true,
+	"views":        true,
+	"comments":     true,
+	"likes":        true,
+}
+
+// videoQuery builds a video selector. The values are always bind parameters (i.e. ?)
+// and never part of the SQL so a bad value cannot break (or inject into) the query.
+type videoQuery struct {
+	conditions  []string
+	args        []interface{}
+	orderColumn string
+	orderDir    string
+	cursor      string
+	limit       int
+	offset      int
+	err         error
+}
+
+// newVideoQuery selects the videos of the channel ordered by the most recently published
+func newVideoQuery(channelID string) *videoQuery {
+	q := &videoQuery{
+		orderColumn: "published_at",
+		orderDir:    "desc",
+	}
+
+	return q.where("channel_id = ?", channelID)
+}
+
+// where adds a condition whose ? placeholders take the args in order.
+// A slice arg is expanded for an IN (?) condition.
+func (q *videoQuery) where(condition string, args ...interface{}) *videoQuery {
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// orderBy orders the videos by one of the video order columns
+func (q *videoQuery) orderBy(column, dir string) *videoQuery {
+	if !videoOrderColumns[column] {
+		q.err = fmt.Errorf("Invalid order by %s", column)
+		return q
+	}
+
+	if dir != "asc" && dir != "desc" {
+		q.err = fmt.Errorf("Invalid order direction %s", dir)
+		return q
+	}
+
+	q.orderColumn = column
+	q.orderDir = dir
+	return q
+}
+
+// after continues the videos right after the cursor (i.e. keyset pagination).
+// The cursor must come from the same ordering.
+func (q *videoQuery) after(cursor string) *videoQuery {
+	q.cursor = cursor
+	return q
+}
+
+// page limits the videos to a page (i.e. offset)
+func (q *videoQuery) page(limit, offset int) *videoQuery {
+	q.limit = limit
+	q.offset = offset
+	return q
+}
+
+// build returns the SQL with ? placeholders and its args
+func (q *videoQuery) build() (string, []interface{}, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+
+	// Copy so that building does not change the query (i.e. build and buildCount)
+	conditions := append([]string{}, q.conditions...)
+	args := append([]interface{}{}, q.args...)
+	if q.cursor != "" {
+		value, id, err := decodeVideoCursor(q.cursor, q.orderColumn)
+		if err != nil {
+			return "", nil, err
+		}
+
+		// The id breaks the ties so that the videos with the same value are neither skipped nor repeated
+		operator := "<"
+		if q.orderDir == "asc" {
+			operator = ">"
+		}
+
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (?, ?)", q.orderColumn, operator))
+		args = append(args, value, id)
+	}
+
+	dir := strings.ToUpper(q.orderDir)
+	query := fmt.Sprintf("SELECT * FROM videos WHERE %s ORDER BY %s %s, id %s", strings.Join(conditions, " AND "), q.orderColumn, dir, dir)
+	if q.limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.limit, q.offset)
+	}
+
+	return sqlx.In(query, args...)
+}
+
+// buildCount returns the SQL that counts all the videos regardless of the cursor and the page
+func (q *videoQuery) buildCount() (string, []interface{}, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+
+	query := fmt.Sprintf("SELECT COUNT(*) FROM videos WHERE %s", strings.Join(q.conditions, " AND "))
+	return sqlx.In(query, q.args...)
+}
+
+// videoCursor is the position of a video within an ordering
+type videoCursor struct {
+	OrderBy string `json:"o"`
+	Value   string `json:"v"`
+	ID      int64  `json:"i"`
+}
+
+// encodeVideoCursor returns the opaque cursor that continues the ordering after the video
+func encodeVideoCursor(video Video, orderBy string) string {
+	cursor := videoCursor{
+		OrderBy: orderBy,
+		ID:      video.ID,
+	}
+
+	switch orderBy {
+	case "published_at":
+		cursor.Value = video.PublishedAt.UTC().Format(time.RFC3339Nano)
+	case "views":
+		cursor.Value = strconv.FormatInt(video.Views, 10)
+	case "comments":
+		cursor.Value = strconv.FormatInt(video.Comments, 10)
+	case "likes":
+		cursor.Value = strconv.FormatInt(video.Likes, 10)
+	}
+
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeVideoCursor returns the typed order value and the id of the cursor
+func decodeVideoCursor(encoded, orderBy string) (interface{}, int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid cursor %s", encoded)
+	}
+
+	cursor := videoCursor{}
+	err = json.Unmarshal(b, &cursor)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid cursor %s", encoded)
+	}
+
+	if cursor.OrderBy != orderBy {
+		return nil, 0, fmt.Errorf("Cursor is ordered by %s rather than %s", cursor.OrderBy, orderBy)
+	}
+
+	if orderBy == "published_at" {
+		value, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Invalid cursor %s", encoded)
+		}
+
+		return value, cursor.ID, nil
+	}
+
+	value, err := strconv.ParseInt(cursor.Value, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid cursor %s", encoded)
+	}
+
+	return value, cursor.ID, nil
+}
+
+// selectVideos runs the video query with the bind parameters of the database
+func (svc *dataService) selectVideos(ctx context.Context, q *videoQuery) ([]Video, error) {
+	videos := []Video{}
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return videos, err
+	}
+
+	query, args, err := q.build()
+	if err != nil {
+		return videos, err
+	}
+
+	err = svc.Db.SelectContext(ctx, &videos, svc.Db.Rebind(query), args...)
+	if err != nil {
+		return videos, err
+	}
+
+	return videos, nil
+}
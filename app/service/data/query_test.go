@@ -0,0 +1,31 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVideoQuery(t *testing.T) {
+	query, args, err := newVideoQuery("channel' OR '1'='1").
+		where("extraction_status IN (?)", []StageStatus{StageStatusPending, StageStatusFailed}).
+		orderBy("views", "asc").
+		page(10, 20).
+		build()
+	if err != nil {
+		t.Fatalf("build produced %v", err)
+	}
+
+	expected := "SELECT * FROM videos WHERE channel_id = ? AND extraction_status IN (?, ?) ORDER BY views ASC, id ASC LIMIT ? OFFSET ?"
+	if query != expected {
+		t.Errorf("expected %q, got %q", expected, query)
+	}
+
+	if len(args) != 5 || strings.Contains(query, "channel'") {
+		t.Errorf("expected the values to be bind parameters, got %v", args)
+	}
+
+	_, _, err = newVideoQuery("channel").orderBy("title; DROP TABLE videos", "asc").build()
+	if err == nil {
+		t.Errorf("expected an invalid order by")
+	}
+}
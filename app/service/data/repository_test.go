@@ -0,0 +1,671 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khaledhikmat/yt-extractor/service"
+	"github.com/khaledhikmat/yt-extractor/service/config"
+)
+
+// The repository suite runs against SQLite and, if TEST_POSTGRES_DSN is set, against Postgres.
+// Please note that the suite resets the Postgres database.
+func TestSQLiteRepository(t *testing.T) {
+	t.Setenv("DB_PROVIDER", "sqlite")
+	t.Setenv("DB_DSN", "TEST_SQLITE_DSN")
+	t.Setenv("TEST_SQLITE_DSN", filepath.Join(t.TempDir(), "yt-extractor.db"))
+	testRepository(t)
+}
+
+func TestPostgresRepository(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES_DSN") == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	t.Setenv("DB_PROVIDER", "postgres")
+	t.Setenv("DB_DSN", "TEST_POSTGRES_DSN")
+	testRepository(t)
+}
+
+func testRepository(t *testing.T) {
+	t.Setenv("COOKIES_ENCRYPTION_KEY", "secret")
+	t.Setenv("RETRY_BACKOFF_BASE", "1")
+	t.Setenv("MAX_ATTEMPTS", "2")
+
+	ctx := context.Background()
+	svc := New(config.New())
+	defer svc.Finalize()
+
+	_, err := svc.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("migrate up produced %v", err)
+	}
+
+	err = svc.CheckMigrations(ctx)
+	if err != nil {
+		t.Fatalf("check migrations produced %v", err)
+	}
+
+	err = svc.ResetFactory(ctx)
+	if err != nil {
+		t.Fatalf("reset factory produced %v", err)
+	}
+
+	err = svc.Ping(ctx)
+	if err != nil {
+		t.Fatalf("ping produced %v", err)
+	}
+
+	t.Run("videos", func(t *testing.T) {
+		testVideos(ctx, t, svc)
+	})
+
+	t.Run("transcripts", func(t *testing.T) {
+		testTranscripts(ctx, t, svc)
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		testJobs(ctx, t, svc)
+	})
+
+	t.Run("keys, errors and cookies", func(t *testing.T) {
+		testKeysErrorsCookies(ctx, t, svc)
+	})
+
+	t.Run("migrations", func(t *testing.T) {
+		testMigrations(ctx, t, svc)
+	})
+}
+
+func testVideos(ctx context.Context, t *testing.T, svc IService) {
+	published := time.Now().UTC().Add(-24 * time.Hour).Truncate(time.Second)
+	videos := []Video{
+		{ChannelID: "channel", VideoID: "a", VideoURL: "https://a", Title: "A", PublishedAt: published, Views: 10, Tags: StringList{"tag"}, Availability: service.AvailabilityPublic},
+		{ChannelID: "channel", VideoID: "b", VideoURL: "https://b", Title: "B", PublishedAt: published.Add(time.Hour), Views: 20, Availability: service.AvailabilityPublic},
+		// Gone videos are never inserted
+		{ChannelID: "channel", VideoID: "c", VideoURL: "https://c", Title: "C", PublishedAt: published, Availability: service.AvailabilityDeleted},
+	}
+
+	upserted, err := svc.NewVideos(ctx, videos)
+	if err != nil {
+		t.Fatalf("new videos produced %v", err)
+	}
+	if len(upserted) != 2 || !upserted[0].Inserted || !upserted[1].Inserted {
+		t.Fatalf("expected 2 inserted videos, got %+v", upserted)
+	}
+
+	videos[0].Views = 15
+	inserted, id, err := svc.NewVideo(ctx, videos[0])
+	if err != nil {
+		t.Fatalf("new video produced %v", err)
+	}
+	if inserted || id != upserted[0].ID {
+		t.Errorf("expected video %d to be updated, got %d (inserted: %t)", upserted[0].ID, id, inserted)
+	}
+
+	video, err := svc.RetrieveVideoByIDs(ctx, "channel", "a")
+	if err != nil {
+		t.Fatalf("retrieve video produced %v", err)
+	}
+	if video.Views != 15 || len(video.Tags) != 1 || !video.PublishedAt.Equal(published) {
+		t.Errorf("unexpected video %+v", video)
+	}
+
+	// A refresh that does not classify the video keeps its classification
+	video.Short = true
+	err = svc.UpdateVideo(ctx, &video, JobTypeShorts)
+	if err != nil {
+		t.Fatalf("update video produced %v", err)
+	}
+
+	_, _, err = svc.NewVideo(ctx, videos[0])
+	if err != nil {
+		t.Fatalf("new video produced %v", err)
+	}
+
+	video, err = svc.RetrieveVideoByIDs(ctx, "channel", "a")
+	if err != nil {
+		t.Fatalf("retrieve video produced %v", err)
+	}
+	if !video.Short || video.ShortClassifiedAt == nil {
+		t.Errorf("expected a classified short, got short: %t (classified at %v)", video.Short, video.ShortClassifiedAt)
+	}
+
+	stored, err := svc.RetrieveVideosByIDs(ctx, "channel", []string{"a", "b", "z"})
+	if err != nil || len(stored) != 2 {
+		t.Errorf("expected 2 stored videos, got %d (%v)", len(stored), err)
+	}
+
+	page, err := svc.RetrieveVideos(ctx, "channel", 1, 10, "published_at", "desc")
+	if err != nil {
+		t.Fatalf("retrieve videos produced %v", err)
+	}
+	if len(page) != 2 || page[0].VideoID != "b" {
+		t.Errorf("expected videos b and a, got %d videos", len(page))
+	}
+
+	// The keyset pages continue from the cursor and count all the filtered videos
+	filter := VideoFilter{ChannelID: "channel", OrderBy: "views", OrderDir: "desc", PageSize: 1}
+	first, err := svc.QueryVideos(ctx, filter)
+	if err != nil {
+		t.Fatalf("query videos produced %v", err)
+	}
+	if len(first.Videos) != 1 || first.Videos[0].VideoID != "b" || first.Total != 2 || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+
+	filter.Cursor = first.NextCursor
+	second, err := svc.QueryVideos(ctx, filter)
+	if err != nil {
+		t.Fatalf("query videos produced %v", err)
+	}
+	if len(second.Videos) != 1 || second.Videos[0].VideoID != "a" || second.NextCursor != "" {
+		t.Errorf("unexpected second page %+v", second)
+	}
+
+	minViews := int64(16)
+	filtered, err := svc.QueryVideos(ctx, VideoFilter{ChannelID: "channel", Title: "b", MinViews: &minViews, PageSize: 10})
+	if err != nil {
+		t.Fatalf("query videos produced %v", err)
+	}
+	if filtered.Total != 1 || len(filtered.Videos) != 1 || filtered.Videos[0].VideoID != "b" {
+		t.Errorf("unexpected filtered page %+v", filtered)
+	}
+
+	// Video a is a short published a day ago with 15 views and video b is published an hour later with 20 views
+	halfHour := published.Add(30 * time.Minute)
+	hour := published.Add(time.Hour)
+	zero := int64(0)
+	one := int64(1)
+	short := true
+	notShort := false
+	filters := []struct {
+		name     string
+		filter   VideoFilter
+		expected []string
+	}{
+		{name: "title", filter: VideoFilter{Title: "a"}, expected: []string{"a"}},
+		{name: "published from", filter: VideoFilter{PublishedFrom: &halfHour}, expected: []string{"b"}},
+		{name: "published to", filter: VideoFilter{PublishedTo: &hour}, expected: []string{"b", "a"}},
+		{name: "published before", filter: VideoFilter{PublishedBefore: &hour}, expected: []string{"a"}},
+		{name: "min duration", filter: VideoFilter{MinDuration: &one}, expected: []string{}},
+		{name: "max duration", filter: VideoFilter{MaxDuration: &zero}, expected: []string{"b", "a"}},
+		{name: "short", filter: VideoFilter{Short: &short}, expected: []string{"a"}},
+		{name: "not short", filter: VideoFilter{Short: &notShort}, expected: []string{"b"}},
+		{name: "extraction status", filter: VideoFilter{ExtractionStatus: StageStatusFailed}, expected: []string{}},
+		{name: "audio status", filter: VideoFilter{AudioStatus: StageStatusPending}, expected: []string{"b", "a"}},
+		{name: "transcription status", filter: VideoFilter{TranscriptionStatus: StageStatusSucceeded}, expected: []string{}},
+		{name: "min views", filter: VideoFilter{MinViews: &minViews}, expected: []string{"b"}},
+	}
+
+	for _, tc := range filters {
+		tc.filter.ChannelID = "channel"
+		tc.filter.PageSize = 10
+		page, err := svc.QueryVideos(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("query videos (%s) produced %v", tc.name, err)
+		}
+
+		ids := []string{}
+		for _, video := range page.Videos {
+			ids = append(ids, video.VideoID)
+		}
+		if !slices.Equal(ids, tc.expected) || page.Total != int64(len(tc.expected)) {
+			t.Errorf("expected %s videos %v, got %v (total %d)", tc.name, tc.expected, ids, page.Total)
+		}
+	}
+
+	// The captions are attempted once before the videos are extracted
+	captionable, err := svc.RetrieveCaptionableVideos(ctx, "channel", 10)
+	if err != nil {
+		t.Fatalf("retrieve captionable videos produced %v", err)
+	}
+	if len(captionable) != 2 {
+		t.Fatalf("expected 2 captionable videos, got %d", len(captionable))
+	}
+
+	noCaptions := "no captions"
+	captionable[0].CaptionsError = &noCaptions
+	err = svc.UpdateVideo(ctx, &captionable[0], JobTypeCaptions)
+	if err != nil {
+		t.Fatalf("update video produced %v", err)
+	}
+
+	captionable, err = svc.RetrieveCaptionableVideos(ctx, "channel", 10)
+	if err != nil || len(captionable) != 1 {
+		t.Errorf("expected 1 captionable video, got %d (%v)", len(captionable), err)
+	}
+
+	unextracted, err := svc.RetrieveUnextractedVideos(ctx, "channel", 10)
+	if err != nil {
+		t.Fatalf("retrieve unextracted videos produced %v", err)
+	}
+	if len(unextracted) != 2 {
+		t.Errorf("expected 2 unextracted videos, got %d", len(unextracted))
+	}
+
+	// A failed extraction is re-attempted once it is backed off (i.e. RETRY_BACKOFF_BASE) until it runs out of attempts
+	message := "failed"
+	video.ExtractionStatus = StageStatusFailed
+	video.ExtractionError = &message
+	err = svc.UpdateVideo(ctx, &video, JobTypeExtraction)
+	if err != nil {
+		t.Fatalf("update video produced %v", err)
+	}
+
+	errored, err := svc.RetrieveExtractErroredVideos(ctx, "channel", 10)
+	if err != nil || len(errored) != 0 {
+		t.Fatalf("expected the errored video to be backed off, got %+v (%v)", errored, err)
+	}
+
+	_, err = svc.(*dataService).Db.ExecContext(ctx, `UPDATE videos SET extraction_next_attempt_at = $1 WHERE id = $2`, time.Now().UTC().Add(-time.Minute), video.ID)
+	if err != nil {
+		t.Fatalf("backdating the next attempt produced %v", err)
+	}
+
+	errored, err = svc.RetrieveExtractErroredVideos(ctx, "channel", 10)
+	if err != nil {
+		t.Fatalf("retrieve errored videos produced %v", err)
+	}
+	if len(errored) != 1 || errored[0].ExtractionAttempts != 1 || errored[0].ExtractionNextAttemptAt == nil {
+		t.Fatalf("expected 1 errored video, got %+v", errored)
+	}
+
+	err = svc.UpdateVideo(ctx, &video, JobTypeExtraction)
+	if err != nil {
+		t.Fatalf("update video produced %v", err)
+	}
+
+	deadLettered, err := svc.RetrieveDeadLetteredVideos(ctx, "channel", 10)
+	if err != nil {
+		t.Fatalf("retrieve dead-lettered videos produced %v", err)
+	}
+	if len(deadLettered) != 1 || deadLettered[0].ExtractionStatus != StageStatusDeadLetter {
+		t.Fatalf("expected 1 dead-lettered video, got %+v", deadLettered)
+	}
+
+	err = svc.RequeueVideo(ctx, &video, StageExtraction)
+	if err != nil {
+		t.Fatalf("requeue video produced %v", err)
+	}
+
+	video, err = svc.RetrieveVideoByID(ctx, video.ID)
+	if err != nil {
+		t.Fatalf("retrieve video produced %v", err)
+	}
+	if video.ExtractionStatus != StageStatusPending || video.ExtractionAttempts != 0 {
+		t.Errorf("expected a requeued video, got %s after %d attempts", video.ExtractionStatus, video.ExtractionAttempts)
+	}
+
+	// A stage left in progress is released once its lease (i.e. STAGE_LEASE) expires
+	err = svc.UpdateVideoStageStatus(ctx, &video, StageExtraction, StageStatusInProgress)
+	if err != nil {
+		t.Fatalf("update video stage status produced %v", err)
+	}
+	if video.PreviousStatus != StageStatusPending || video.RestoredStatus() != StageStatusPending {
+		t.Errorf("expected the video to be restored to pending, got %s", video.RestoredStatus())
+	}
+
+	released, err := svc.ReleaseStaleVideos(ctx)
+	if err != nil || released != 0 {
+		t.Errorf("expected no stale videos, got %d (%v)", released, err)
+	}
+
+	_, err = svc.(*dataService).Db.ExecContext(ctx, `UPDATE videos SET extraction_started_at = $1 WHERE id = $2`, time.Now().UTC().Add(-3*time.Hour), video.ID)
+	if err != nil {
+		t.Fatalf("backdating the video produced %v", err)
+	}
+
+	released, err = svc.ReleaseStaleVideos(ctx)
+	if err != nil || released != 1 {
+		t.Errorf("expected 1 stale video, got %d (%v)", released, err)
+	}
+
+	video, err = svc.RetrieveVideoByID(ctx, video.ID)
+	if err != nil {
+		t.Fatalf("retrieve video produced %v", err)
+	}
+	if video.ExtractionStatus != StageStatusFailed || video.ExtractionAttempts != 1 || video.ExtractionError == nil {
+		t.Errorf("expected a failed video, got %s after %d attempts", video.ExtractionStatus, video.ExtractionAttempts)
+	}
+
+	err = svc.RequeueVideo(ctx, &video, StageExtraction)
+	if err != nil {
+		t.Fatalf("requeue video produced %v", err)
+	}
+	video.ExtractionStatus = StageStatusPending
+
+	err = svc.UpdateVideo(ctx, &video, JobTypeExternalization)
+	if err != nil {
+		t.Fatalf("update video produced %v", err)
+	}
+
+	updated, err := svc.RetrieveUpdatedVideos(ctx, "channel", 10)
+	if err != nil {
+		t.Fatalf("retrieve updated videos produced %v", err)
+	}
+	if len(updated) != 1 {
+		t.Errorf("expected 1 updated video, got %d", len(updated))
+	}
+
+	err = svc.NewVideoStatsSnapshot(ctx, video)
+	if err != nil {
+		t.Fatalf("new video stats snapshot produced %v", err)
+	}
+
+	stats, err := svc.RetrieveVideoStats(ctx, video.ID, 7)
+	if err != nil {
+		t.Fatalf("retrieve video stats produced %v", err)
+	}
+	if len(stats.Snapshots) != 1 || stats.Snapshots[0].Views != 15 {
+		t.Errorf("expected 1 snapshot, got %+v", stats.Snapshots)
+	}
+
+	// A failed transcription is not a transcript although it is dated
+	transcribed := []struct {
+		videoID string
+		status  StageStatus
+	}{
+		{"a", StageStatusFailed},
+		{"b", StageStatusSucceeded},
+	}
+	for _, tc := range transcribed {
+		video, err := svc.RetrieveVideoByIDs(ctx, "channel", tc.videoID)
+		if err != nil {
+			t.Fatalf("retrieve video produced %v", err)
+		}
+
+		video.TranscriptionStatus = tc.status
+		err = svc.UpdateVideo(ctx, &video, JobTypeTranscription)
+		if err != nil {
+			t.Fatalf("update video produced %v", err)
+		}
+	}
+
+	for _, hasTranscript := range []bool{true, false} {
+		expected := "a"
+		if hasTranscript {
+			expected = "b"
+		}
+
+		page, err := svc.QueryVideos(ctx, VideoFilter{ChannelID: "channel", HasTranscript: &hasTranscript, PageSize: 10})
+		if err != nil {
+			t.Fatalf("query videos produced %v", err)
+		}
+		if page.Total != 1 || len(page.Videos) != 1 || page.Videos[0].VideoID != expected {
+			t.Errorf("expected video %s to have a transcript: %t, got %+v", expected, hasTranscript, page)
+		}
+	}
+}
+
+func testTranscripts(ctx context.Context, t *testing.T, svc IService) {
+	video, err := svc.RetrieveVideoByIDs(ctx, "channel", "b")
+	if err != nil {
+		t.Fatalf("retrieve video produced %v", err)
+	}
+
+	transcriptionURL := "https://transcript"
+	video.TranscriptionURL = &transcriptionURL
+	video.TranscriptionStatus = StageStatusSucceeded
+	err = svc.UpdateVideo(ctx, &video, JobTypeTranscription)
+	if err != nil {
+		t.Fatalf("update video produced %v", err)
+	}
+
+	unindexed, err := svc.RetrieveUnindexedVideos(ctx, "channel", 10)
+	if err != nil || len(unindexed) != 1 || unindexed[0].VideoID != "b" {
+		t.Fatalf("expected video b to be unindexed, got %+v (%v)", unindexed, err)
+	}
+
+	// An empty transcript is indexed once
+	err = svc.NewTranscriptSegments(ctx, video, []TranscriptSegment{})
+	if err != nil {
+		t.Fatalf("new transcript segments produced %v", err)
+	}
+
+	unindexed, err = svc.RetrieveUnindexedVideos(ctx, "channel", 10)
+	if err != nil || len(unindexed) != 0 {
+		t.Errorf("expected no unindexed videos, got %d (%v)", len(unindexed), err)
+	}
+
+	start := int64(65000)
+	segments := []TranscriptSegment{
+		{StartMs: &start, Text: "The patience of the believers is rewarded <script>"},
+		{Text: "الصبر مفتاح الفرج"},
+	}
+
+	// Indexing twice replaces the segments
+	for range 2 {
+		err = svc.NewTranscriptSegments(ctx, video, segments)
+		if err != nil {
+			t.Fatalf("new transcript segments produced %v", err)
+		}
+	}
+
+	results, err := svc.SearchTranscripts(ctx, "channel", "believers", 10)
+	if err != nil {
+		t.Fatalf("search transcripts produced %v", err)
+	}
+	if len(results) != 1 || len(results[0].Snippets) != 1 {
+		t.Fatalf("expected 1 result with 1 snippet, got %+v", results)
+	}
+
+	snippet := results[0].Snippets[0]
+	// The transcript text is escaped so only the highlights are markup
+	if !strings.Contains(snippet.Snippet, "<b>believers</b>") || !strings.Contains(snippet.Snippet, "&lt;script&gt;") || snippet.Start == nil || *snippet.Start != 65 || !strings.Contains(snippet.URL, "t=65s") {
+		t.Errorf("unexpected snippet %+v", snippet)
+	}
+
+	results, err = svc.SearchTranscripts(ctx, "", "الصبر", 10)
+	if err != nil {
+		t.Fatalf("search transcripts produced %v", err)
+	}
+	if len(results) != 1 || results[0].VideoID != "b" || results[0].Snippets[0].Start != nil {
+		t.Errorf("expected 1 result without a timestamp, got %+v", results)
+	}
+
+	unembedded, err := svc.RetrieveUnembeddedVideos(ctx, "channel", "model", 10)
+	if err != nil {
+		t.Fatalf("retrieve unembedded videos produced %v", err)
+	}
+	if len(unembedded) != 1 {
+		t.Fatalf("expected 1 unembedded video, got %d", len(unembedded))
+	}
+
+	stored, err := svc.RetrieveTranscriptSegments(ctx, "channel", "b")
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("expected 2 segments, got %d (%v)", len(stored), err)
+	}
+
+	err = svc.NewTranscriptEmbeddings(ctx, []TranscriptEmbedding{
+		{SegmentID: stored[0].ID, Model: "model", Vector: Vector{1, 0}},
+		{SegmentID: stored[1].ID, Model: "model", Vector: Vector{0, 1}},
+	})
+	if err != nil {
+		t.Fatalf("new transcript embeddings produced %v", err)
+	}
+
+	passages, err := svc.SemanticSearch(ctx, "channel", "model", Vector{0.9, 0.1}, 1)
+	if err != nil {
+		t.Fatalf("semantic search produced %v", err)
+	}
+	if len(passages) != 1 || passages[0].SegmentID != stored[0].ID || passages[0].Start == nil || !strings.Contains(passages[0].URL, "t=65s") {
+		t.Errorf("expected the first segment, got %+v", passages)
+	}
+
+	unembedded, err = svc.RetrieveUnembeddedVideos(ctx, "channel", "model", 10)
+	if err != nil || len(unembedded) != 0 {
+		t.Errorf("expected no unembedded videos, got %d (%v)", len(unembedded), err)
+	}
+
+	// The cached vectors follow the embedding writes
+	err = svc.NewTranscriptEmbeddings(ctx, []TranscriptEmbedding{
+		{SegmentID: stored[1].ID, Model: "model", Vector: Vector{0.9, 0.1}},
+	})
+	if err != nil {
+		t.Fatalf("new transcript embeddings produced %v", err)
+	}
+
+	passages, err = svc.SemanticSearch(ctx, "channel", "model", Vector{0.9, 0.1}, 1)
+	if err != nil || len(passages) != 1 || passages[0].SegmentID != stored[1].ID {
+		t.Errorf("expected the updated second segment, got %+v (%v)", passages, err)
+	}
+
+	// ... and the replaced segments
+	err = svc.NewTranscriptSegments(ctx, video, segments)
+	if err != nil {
+		t.Fatalf("new transcript segments produced %v", err)
+	}
+
+	passages, err = svc.SemanticSearch(ctx, "channel", "model", Vector{0.9, 0.1}, 1)
+	if err != nil || len(passages) != 0 {
+		t.Errorf("expected no passages, got %+v (%v)", passages, err)
+	}
+
+	// A video that fails to embed runs out of attempts (i.e. MAX_ATTEMPTS) and is dead-lettered
+	unembedded, err = svc.RetrieveUnembeddedVideos(ctx, "channel", "model", 10)
+	if err != nil || len(unembedded) != 1 {
+		t.Fatalf("expected 1 unembedded video, got %d (%v)", len(unembedded), err)
+	}
+
+	failed := unembedded[0]
+	message := "embedding failed"
+	for range 2 {
+		failed.EmbeddingStatus = StageStatusFailed
+		failed.EmbeddingError = &message
+		err = svc.UpdateVideo(ctx, &failed, JobTypeEmbedding)
+		if err != nil {
+			t.Fatalf("update video produced %v", err)
+		}
+	}
+
+	embedded, err := svc.RetrieveVideoByID(ctx, failed.ID)
+	if err != nil || embedded.EmbeddingStatus != StageStatusDeadLetter || embedded.EmbeddingAttempts != 2 {
+		t.Errorf("expected a dead-lettered embedding, got %s after %d attempts (%v)", embedded.EmbeddingStatus, embedded.EmbeddingAttempts, err)
+	}
+
+	deadLettered, err := svc.RetrieveDeadLetteredVideos(ctx, "channel", 10)
+	if err != nil || !slices.ContainsFunc(deadLettered, func(video Video) bool { return video.ID == failed.ID }) {
+		t.Errorf("expected the dead-lettered embedding to be listed, got %+v (%v)", deadLettered, err)
+	}
+
+	unembedded, err = svc.RetrieveUnembeddedVideos(ctx, "channel", "model", 10)
+	if err != nil || len(unembedded) != 0 {
+		t.Errorf("expected no unembedded videos, got %d (%v)", len(unembedded), err)
+	}
+}
+
+func testJobs(ctx context.Context, t *testing.T, svc IService) {
+	job := Job{
+		ChannelID: "channel",
+		Type:      JobTypeExtraction,
+		State:     JobStateQueued,
+		StartedAt: time.Now(),
+	}
+
+	id, err := svc.NewJob(ctx, job)
+	if err != nil {
+		t.Fatalf("new job produced %v", err)
+	}
+
+	_, err = svc.NewJob(ctx, job)
+	if !errors.Is(err, ErrJobConflict) {
+		t.Fatalf("expected a job conflict, got %v", err)
+	}
+
+	jobVideoID, err := svc.NewJobVideo(ctx, JobVideo{JobID: id, ChannelID: "channel", VideoID: "a", StartedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("new job video produced %v", err)
+	}
+
+	now := time.Now()
+	err = svc.UpdateJobVideo(ctx, &JobVideo{ID: jobVideoID, CompletedAt: &now})
+	if err != nil {
+		t.Fatalf("update job video produced %v", err)
+	}
+
+	job, err = svc.RetrieveJobByID(ctx, id)
+	if err != nil {
+		t.Fatalf("retrieve job produced %v", err)
+	}
+
+	job.State = JobStateCompleted
+	job.Videos = 1
+	job.Stages = StageCounts{"extraction": {Videos: 1}}
+	job.CompletedAt = &now
+	err = svc.UpdateJob(ctx, &job)
+	if err != nil {
+		t.Fatalf("update job produced %v", err)
+	}
+
+	job, err = svc.RetrieveJobByID(ctx, id)
+	if err != nil {
+		t.Fatalf("retrieve job produced %v", err)
+	}
+	if job.State != JobStateCompleted || job.Stages["extraction"].Videos != 1 {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	// The channel can run the job again once it is completed
+	_, err = svc.NewJob(ctx, Job{ChannelID: "channel", Type: JobTypeExtraction, State: JobStateQueued, StartedAt: time.Now()})
+	if err != nil {
+		t.Errorf("new job produced %v", err)
+	}
+}
+
+func testKeysErrorsCookies(ctx context.Context, t *testing.T, svc IService) {
+	err := svc.NewAPIKey(ctx, "key")
+	if err != nil {
+		t.Fatalf("new api key produced %v", err)
+	}
+
+	valid, err := svc.IsAPIKeyValid(ctx, "key")
+	if err != nil || !valid {
+		t.Errorf("expected a valid api key, got %v", err)
+	}
+
+	err = svc.NewError(ctx, "test", "body")
+	if err != nil {
+		t.Errorf("new error produced %v", err)
+	}
+
+	err = svc.NewCookies(ctx, []byte("cookies"))
+	if err != nil {
+		t.Fatalf("new cookies produced %v", err)
+	}
+
+	cookies, err := svc.RetrieveCookies(ctx)
+	if err != nil || !bytes.Equal(cookies, []byte("cookies")) {
+		t.Errorf("expected the cookies, got %s (%v)", cookies, err)
+	}
+}
+
+func testMigrations(ctx context.Context, t *testing.T, svc IService) {
+	migrations, err := svc.RetrieveMigrations(ctx)
+	if err != nil {
+		t.Fatalf("retrieve migrations produced %v", err)
+	}
+
+	// Every migration must revert cleanly and apply again
+	for range migrations {
+		_, err = svc.MigrateDown(ctx)
+		if err != nil {
+			t.Fatalf("migrate down produced %v", err)
+		}
+	}
+
+	migrated, err := svc.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("migrate up produced %v", err)
+	}
+	if len(migrated) != len(migrations) {
+		t.Errorf("expected %d migrations, got %d", len(migrations), len(migrated))
+	}
+}
@@ -0,0 +1,6 @@
+INSERT INTO cookies (
+    content, created_at
+) VALUES (
+    $1, NOW()
+)
+RETURNING id
@@ -0,0 +1,6 @@
+INSERT INTO job_videos (
+    job_id, channel_id, video_id, started_at, error, completed_at
+) VALUES (
+    :job_id, :channel_id, :video_id, :started_at, :error, :completed_at
+)
+RETURNING id
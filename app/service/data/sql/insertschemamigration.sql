@@ -0,0 +1,5 @@
+INSERT INTO schema_migrations (
+    version, name, checksum, applied_at
+) VALUES (
+    :version, :name, :checksum, :applied_at
+)
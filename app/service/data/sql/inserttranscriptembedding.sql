@@ -0,0 +1,7 @@
+INSERT INTO transcript_embeddings (
+    segment_id, model, vector
+) VALUES (
+    :segment_id, :model, :vector
+)
+ON CONFLICT (segment_id, model) DO UPDATE SET
+    vector = EXCLUDED.vector
@@ -0,0 +1,5 @@
+INSERT INTO transcript_segments (
+    channel_id, video_id, position, start_ms, end_ms, text
+) VALUES (
+    :channel_id, :video_id, :position, :start_ms, :end_ms, :text
+)
@@ -1,10 +1,38 @@
 INSERT INTO videos (
-    channel_id, video_id, video_url, title, published_at, duration, short, updated_at,
+    channel_id, video_id, video_url, title, published_at, duration, short, short_classified_at, updated_at,
     views, comments, likes, 
+    description, tags, thumbnails, category_id, default_audio_language, caption, live_broadcast_content, privacy_status, availability, 
     extraction_url, extracted_at, externalized_at, audio_url, audioed_at, transcription_url, transcribed_at   
 ) VALUES (
-    :channel_id, :video_id, :video_url, :title, :published_at, :duration, :short, NOW(),
+    :channel_id, :video_id, :video_url, :title, :published_at, :duration, :short, :short_classified_at, NOW(),
     :views, :comments, :likes, 
+    :description, :tags, :thumbnails, :category_id, :default_audio_language, :caption, :live_broadcast_content, :privacy_status, :availability, 
     :extraction_url, :extracted_at, :externalized_at, :audio_url, :audioed_at, :transcription_url, :transcribed_at
 )
-RETURNING id
+ON CONFLICT (channel_id, video_id) DO UPDATE 
+SET 
+    updated_at = CASE WHEN (
+        videos.views, videos.comments, videos.likes, 
+        videos.description, videos.tags, videos.thumbnails, videos.category_id, videos.default_audio_language, 
+        videos.caption, videos.live_broadcast_content, videos.privacy_status, videos.availability, videos.short
+    ) IS DISTINCT FROM (
+        EXCLUDED.views, EXCLUDED.comments, EXCLUDED.likes, 
+        EXCLUDED.description, EXCLUDED.tags, EXCLUDED.thumbnails, EXCLUDED.category_id, EXCLUDED.default_audio_language, 
+        EXCLUDED.caption, EXCLUDED.live_broadcast_content, EXCLUDED.privacy_status, EXCLUDED.availability, 
+        CASE WHEN EXCLUDED.short_classified_at IS NULL THEN videos.short ELSE EXCLUDED.short END
+    ) THEN NOW() ELSE videos.updated_at END,
+    views = EXCLUDED.views, 
+    comments = EXCLUDED.comments, 
+    likes = EXCLUDED.likes, 
+    description = EXCLUDED.description, 
+    tags = EXCLUDED.tags, 
+    thumbnails = EXCLUDED.thumbnails, 
+    category_id = EXCLUDED.category_id, 
+    default_audio_language = EXCLUDED.default_audio_language, 
+    caption = EXCLUDED.caption, 
+    live_broadcast_content = EXCLUDED.live_broadcast_content, 
+    privacy_status = EXCLUDED.privacy_status, 
+    availability = EXCLUDED.availability, 
+    short = CASE WHEN EXCLUDED.short_classified_at IS NULL THEN videos.short ELSE EXCLUDED.short END, 
+    short_classified_at = COALESCE(EXCLUDED.short_classified_at, videos.short_classified_at) 
+RETURNING id, channel_id, video_id, (xmax = 0) AS inserted
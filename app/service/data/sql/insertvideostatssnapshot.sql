@@ -0,0 +1,6 @@
+INSERT INTO video_stats_snapshots (
+    channel_id, video_id, views, comments, likes, captured_at
+) VALUES (
+    :channel_id, :video_id, :views, :comments, :likes, NOW()
+)
+RETURNING id
@@ -0,0 +1,3 @@
+ALTER TABLE videos
+DROP COLUMN audio_url,
+DROP COLUMN transcription_url;
@@ -1,3 +1,3 @@
 ALTER TABLE videos
 ADD COLUMN audio_url TEXT,
-ADD COLUMN transcription_url TEXT;
\ No newline at end of file
+ADD COLUMN transcription_url TEXT;
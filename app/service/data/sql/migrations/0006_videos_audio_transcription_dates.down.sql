@@ -0,0 +1,10 @@
+ALTER TABLE videos
+ADD COLUMN processed_at TIMESTAMP;
+
+UPDATE videos 
+SET processed_at = transcribed_at 
+WHERE transcribed_at IS NOT NULL;
+
+ALTER TABLE videos
+DROP COLUMN audioed_at,
+DROP COLUMN transcribed_at;
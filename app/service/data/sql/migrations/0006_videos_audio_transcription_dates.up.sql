@@ -7,4 +7,4 @@ SET audioed_at = processed_at, transcribed_at = processed_at
 WHERE processed_at IS NOT NULL;
 
 ALTER TABLE videos 
-DROP COLUMN processed_at;
\ No newline at end of file
+DROP COLUMN processed_at;
@@ -0,0 +1,9 @@
+ALTER TABLE videos
+DROP COLUMN description,
+DROP COLUMN tags,
+DROP COLUMN thumbnails,
+DROP COLUMN category_id,
+DROP COLUMN default_audio_language,
+DROP COLUMN caption,
+DROP COLUMN live_broadcast_content,
+DROP COLUMN privacy_status;
@@ -0,0 +1,9 @@
+ALTER TABLE videos
+ADD COLUMN description TEXT NOT NULL DEFAULT '',
+ADD COLUMN tags JSONB NOT NULL DEFAULT '[]',
+ADD COLUMN thumbnails JSONB NOT NULL DEFAULT '{}',
+ADD COLUMN category_id TEXT NOT NULL DEFAULT '',
+ADD COLUMN default_audio_language TEXT NOT NULL DEFAULT '',
+ADD COLUMN caption BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN live_broadcast_content TEXT NOT NULL DEFAULT '',
+ADD COLUMN privacy_status TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+CREATE TABLE video_stats_snapshots (
+    id SERIAL PRIMARY KEY,
+    channel_id TEXT NOT NULL,
+    video_id TEXT NOT NULL,
+    views BIGINT NOT NULL,
+    comments BIGINT NOT NULL,
+    likes BIGINT NOT NULL,
+    captured_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX video_stats_snapshots_video_idx ON video_stats_snapshots (channel_id, video_id, captured_at);
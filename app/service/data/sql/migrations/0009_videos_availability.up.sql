@@ -0,0 +1,2 @@
+ALTER TABLE videos
+ADD COLUMN availability TEXT NOT NULL DEFAULT 'public';
@@ -0,0 +1,3 @@
+ALTER TABLE videos
+DROP COLUMN transcription_source,
+DROP COLUMN subtitles_url;
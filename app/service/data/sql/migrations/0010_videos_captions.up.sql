@@ -0,0 +1,8 @@
+ALTER TABLE videos
+ADD COLUMN transcription_source TEXT,
+ADD COLUMN subtitles_url TEXT;
+
+UPDATE videos 
+SET transcription_source = 'audio' 
+WHERE transcribed_at IS NOT NULL 
+AND transcription_url != 'https://www.isitdownrightnow.com';
@@ -0,0 +1,2 @@
+ALTER TABLE videos
+DROP COLUMN format_ids;
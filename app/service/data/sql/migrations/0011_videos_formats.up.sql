@@ -0,0 +1,2 @@
+ALTER TABLE videos
+ADD COLUMN format_ids TEXT;
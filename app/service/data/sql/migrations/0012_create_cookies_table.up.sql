@@ -0,0 +1,5 @@
+CREATE TABLE cookies (
+    id SERIAL PRIMARY KEY,
+    content BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
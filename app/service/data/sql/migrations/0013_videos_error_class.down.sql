@@ -0,0 +1,4 @@
+ALTER TABLE videos
+DROP COLUMN extraction_error_class,
+DROP COLUMN extraction_attempts,
+DROP COLUMN extraction_attempted_at;
@@ -0,0 +1,8 @@
+ALTER TABLE videos
+ADD COLUMN extraction_error_class TEXT NOT NULL DEFAULT '',
+ADD COLUMN extraction_attempts INT NOT NULL DEFAULT 0,
+ADD COLUMN extraction_attempted_at TIMESTAMP;
+
+UPDATE videos 
+SET extraction_attempts = 1, extraction_attempted_at = extracted_at 
+WHERE extracted_at IS NOT NULL;
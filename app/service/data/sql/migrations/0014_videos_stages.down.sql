@@ -0,0 +1,10 @@
+-- The sentinel URLs are not restored
+ALTER TABLE videos
+DROP COLUMN extraction_status,
+DROP COLUMN extraction_error,
+DROP COLUMN audio_status,
+DROP COLUMN audio_attempts,
+DROP COLUMN audio_error,
+DROP COLUMN transcription_status,
+DROP COLUMN transcription_attempts,
+DROP COLUMN transcription_error;
@@ -0,0 +1,43 @@
+ALTER TABLE videos
+ADD COLUMN extraction_status TEXT NOT NULL DEFAULT 'pending',
+ADD COLUMN extraction_error TEXT,
+ADD COLUMN audio_status TEXT NOT NULL DEFAULT 'pending',
+ADD COLUMN audio_attempts INT NOT NULL DEFAULT 0,
+ADD COLUMN audio_error TEXT,
+ADD COLUMN transcription_status TEXT NOT NULL DEFAULT 'pending',
+ADD COLUMN transcription_attempts INT NOT NULL DEFAULT 0,
+ADD COLUMN transcription_error TEXT;
+
+-- Convert the sentinel URLs that used to encode the pipeline state
+-- https://www.isitdownrightnow.com => failed
+-- https://httpstatuses.com/202 => waiting for the CloudConvert webhook
+UPDATE videos 
+SET extraction_status = CASE 
+    WHEN extraction_url = 'https://www.isitdownrightnow.com' THEN 'failed' 
+    ELSE 'succeeded' 
+END 
+WHERE extracted_at IS NOT NULL;
+
+UPDATE videos 
+SET 
+    audio_status = CASE 
+        WHEN audio_url = 'https://www.isitdownrightnow.com' THEN 'failed' 
+        WHEN audio_url = 'https://httpstatuses.com/202' THEN 'waiting_external' 
+        ELSE 'succeeded' 
+    END,
+    audio_attempts = 1 
+WHERE audioed_at IS NOT NULL;
+
+UPDATE videos 
+SET 
+    transcription_status = CASE 
+        WHEN transcription_url = 'https://www.isitdownrightnow.com' THEN 'failed' 
+        ELSE 'succeeded' 
+    END,
+    transcription_attempts = 1 
+WHERE transcribed_at IS NOT NULL;
+
+-- Clients must never see the sentinel URLs
+UPDATE videos SET extraction_url = NULL WHERE extraction_url = 'https://www.isitdownrightnow.com';
+UPDATE videos SET audio_url = NULL WHERE audio_url IN ('https://www.isitdownrightnow.com', 'https://httpstatuses.com/202');
+UPDATE videos SET transcription_url = NULL WHERE transcription_url = 'https://www.isitdownrightnow.com';
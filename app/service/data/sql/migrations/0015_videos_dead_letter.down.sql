@@ -0,0 +1,8 @@
+UPDATE videos SET extraction_status = 'failed' WHERE extraction_status = 'dead_letter';
+UPDATE videos SET audio_status = 'failed' WHERE audio_status = 'dead_letter';
+UPDATE videos SET transcription_status = 'failed' WHERE transcription_status = 'dead_letter';
+
+ALTER TABLE videos
+DROP COLUMN extraction_next_attempt_at,
+DROP COLUMN audio_next_attempt_at,
+DROP COLUMN transcription_next_attempt_at;
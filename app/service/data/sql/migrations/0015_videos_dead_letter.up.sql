@@ -0,0 +1,14 @@
+ALTER TABLE videos
+ADD COLUMN extraction_next_attempt_at TIMESTAMP,
+ADD COLUMN audio_next_attempt_at TIMESTAMP,
+ADD COLUMN transcription_next_attempt_at TIMESTAMP;
+
+-- Failures older than the former reattempt period (48 hours) were abandoned so they are dead-lettered
+UPDATE videos SET extraction_status = 'dead_letter' 
+WHERE extraction_status = 'failed' AND extracted_at < NOW() - INTERVAL '48 HOURS';
+
+UPDATE videos SET audio_status = 'dead_letter' 
+WHERE audio_status = 'failed' AND audioed_at < NOW() - INTERVAL '48 HOURS';
+
+UPDATE videos SET transcription_status = 'dead_letter' 
+WHERE transcription_status = 'failed' AND transcribed_at < NOW() - INTERVAL '48 HOURS';
@@ -0,0 +1 @@
+DROP TABLE job_videos;
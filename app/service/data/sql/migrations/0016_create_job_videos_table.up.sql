@@ -0,0 +1,12 @@
+CREATE TABLE job_videos (
+    id SERIAL PRIMARY KEY,
+    job_id BIGINT NOT NULL,
+    channel_id TEXT NOT NULL,
+    video_id TEXT NOT NULL,
+    started_at TIMESTAMP NOT NULL,
+    error TEXT,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX job_videos_job_idx ON job_videos (job_id);
+CREATE INDEX job_videos_video_idx ON job_videos (channel_id, video_id, started_at);
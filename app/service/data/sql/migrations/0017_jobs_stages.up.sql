@@ -0,0 +1,3 @@
+-- Per-stage counts of the automation (i.e. pipeline) jobs
+ALTER TABLE jobs
+ADD COLUMN stages JSONB NOT NULL DEFAULT '{}';
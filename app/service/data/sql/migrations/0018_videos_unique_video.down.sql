@@ -0,0 +1 @@
+DROP INDEX videos_channel_video_idx;
@@ -0,0 +1,8 @@
+-- Concurrent syncs of the same channel may have inserted a video twice so the first one is kept
+DELETE FROM videos a 
+USING videos b 
+WHERE a.channel_id = b.channel_id 
+AND a.video_id = b.video_id 
+AND a.id > b.id;
+
+CREATE UNIQUE INDEX videos_channel_video_idx ON videos (channel_id, video_id);
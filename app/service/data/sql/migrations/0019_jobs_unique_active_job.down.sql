@@ -0,0 +1 @@
+DROP INDEX jobs_active_type_idx;
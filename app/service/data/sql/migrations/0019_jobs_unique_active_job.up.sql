@@ -0,0 +1,11 @@
+-- Racing requests may have queued the same job twice so only the latest one is kept active
+UPDATE jobs a 
+SET state = 'cancelled', completed_at = NOW() 
+FROM jobs b 
+WHERE a.channel_id = b.channel_id 
+AND a.type = b.type 
+AND a.state IN ('queued', 'running') 
+AND b.state IN ('queued', 'running') 
+AND a.id < b.id;
+
+CREATE UNIQUE INDEX jobs_active_type_idx ON jobs (channel_id, type) WHERE state IN ('queued', 'running');
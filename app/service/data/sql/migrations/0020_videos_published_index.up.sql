@@ -0,0 +1 @@
+CREATE INDEX videos_channel_published_idx ON videos (channel_id, published_at, id);
@@ -0,0 +1 @@
+DROP TABLE transcript_segments;
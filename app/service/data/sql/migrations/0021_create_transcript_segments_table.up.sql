@@ -0,0 +1,13 @@
+CREATE TABLE transcript_segments (
+    id SERIAL PRIMARY KEY,
+    channel_id TEXT NOT NULL,
+    video_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    start_ms BIGINT,
+    end_ms BIGINT,
+    text TEXT NOT NULL,
+    search TSVECTOR GENERATED ALWAYS AS (to_tsvector('arabic', text) || to_tsvector('english', text)) STORED
+);
+
+CREATE INDEX transcript_segments_video_idx ON transcript_segments (channel_id, video_id, position);
+CREATE INDEX transcript_segments_search_idx ON transcript_segments USING GIN (search);
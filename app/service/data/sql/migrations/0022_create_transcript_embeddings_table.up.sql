@@ -0,0 +1,6 @@
+CREATE TABLE transcript_embeddings (
+    segment_id BIGINT NOT NULL,
+    model TEXT NOT NULL,
+    vector BYTEA NOT NULL,
+    PRIMARY KEY (segment_id, model)
+);
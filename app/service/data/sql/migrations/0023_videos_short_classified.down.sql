@@ -0,0 +1 @@
+ALTER TABLE videos DROP COLUMN short_classified_at;
@@ -0,0 +1 @@
+ALTER TABLE videos ADD COLUMN short_classified_at TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE videos DROP COLUMN captions_error;
+
+ALTER TABLE videos DROP COLUMN captions_attempted_at;
@@ -0,0 +1,10 @@
+ALTER TABLE videos
+ADD COLUMN captions_attempted_at TIMESTAMP;
+
+ALTER TABLE videos
+ADD COLUMN captions_error TEXT;
+
+-- The videos transcribed from their captions were attempted
+UPDATE videos 
+SET captions_attempted_at = transcribed_at 
+WHERE transcription_source = 'captions';
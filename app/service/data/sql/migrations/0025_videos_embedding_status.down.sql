@@ -0,0 +1,5 @@
+ALTER TABLE videos
+DROP COLUMN embedding_error,
+DROP COLUMN embedding_next_attempt_at,
+DROP COLUMN embedding_attempts,
+DROP COLUMN embedding_status;
@@ -0,0 +1,5 @@
+ALTER TABLE videos
+ADD COLUMN embedding_status TEXT NOT NULL DEFAULT 'pending',
+ADD COLUMN embedding_attempts INT NOT NULL DEFAULT 0,
+ADD COLUMN embedding_next_attempt_at TIMESTAMP,
+ADD COLUMN embedding_error TEXT;
@@ -0,0 +1,5 @@
+ALTER TABLE videos
+DROP COLUMN embedding_started_at,
+DROP COLUMN transcription_started_at,
+DROP COLUMN audio_started_at,
+DROP COLUMN extraction_started_at;
@@ -0,0 +1,5 @@
+ALTER TABLE videos
+ADD COLUMN extraction_started_at TIMESTAMP,
+ADD COLUMN audio_started_at TIMESTAMP,
+ADD COLUMN transcription_started_at TIMESTAMP,
+ADD COLUMN embedding_started_at TIMESTAMP;
@@ -0,0 +1,7 @@
+ALTER TABLE videos
+ADD COLUMN indexed_at TIMESTAMP;
+
+-- The videos that have transcript segments were indexed
+UPDATE videos 
+SET indexed_at = NOW() 
+WHERE EXISTS (SELECT 1 FROM transcript_segments s WHERE s.channel_id = videos.channel_id AND s.video_id = videos.video_id);
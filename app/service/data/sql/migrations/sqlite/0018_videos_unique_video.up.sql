@@ -0,0 +1,7 @@
+-- Concurrent syncs of the same channel may have inserted a video twice so the first one is kept
+DELETE FROM videos 
+WHERE id NOT IN (
+    SELECT MIN(id) FROM videos GROUP BY channel_id, video_id
+);
+
+CREATE UNIQUE INDEX videos_channel_video_idx ON videos (channel_id, video_id);
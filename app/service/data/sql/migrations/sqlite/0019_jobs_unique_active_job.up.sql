@@ -0,0 +1,9 @@
+-- Racing requests may have queued the same job twice so only the latest one is kept active
+UPDATE jobs 
+SET state = 'cancelled', completed_at = NOW() 
+WHERE state IN ('queued', 'running') 
+AND id NOT IN (
+    SELECT MAX(id) FROM jobs WHERE state IN ('queued', 'running') GROUP BY channel_id, type
+);
+
+CREATE UNIQUE INDEX jobs_active_type_idx ON jobs (channel_id, type) WHERE state IN ('queued', 'running');
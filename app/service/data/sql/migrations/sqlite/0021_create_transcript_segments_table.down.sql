@@ -0,0 +1,2 @@
+DROP TABLE transcript_segments_search;
+DROP TABLE transcript_segments;
@@ -0,0 +1,26 @@
+CREATE TABLE transcript_segments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    channel_id TEXT NOT NULL,
+    video_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    start_ms BIGINT,
+    end_ms BIGINT,
+    text TEXT NOT NULL
+);
+
+CREATE INDEX transcript_segments_video_idx ON transcript_segments (channel_id, video_id, position);
+
+CREATE VIRTUAL TABLE transcript_segments_search USING fts5(
+    text,
+    content = 'transcript_segments',
+    content_rowid = 'id',
+    tokenize = 'porter unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER transcript_segments_search_insert AFTER INSERT ON transcript_segments BEGIN
+    INSERT INTO transcript_segments_search (rowid, text) VALUES (new.id, new.text);
+END;
+
+CREATE TRIGGER transcript_segments_search_delete AFTER DELETE ON transcript_segments BEGIN
+    INSERT INTO transcript_segments_search (transcript_segments_search, rowid, text) VALUES ('delete', old.id, old.text);
+END;
@@ -1 +1 @@
-TRUNCATE videos, jobs, errors;
\ No newline at end of file
+TRUNCATE videos, video_stats_snapshots, jobs, job_videos, errors, transcript_segments, transcript_embeddings;
\ No newline at end of file
@@ -0,0 +1,12 @@
+SELECT
+    v.id, v.channel_id, v.video_id, v.title, v.video_url, v.published_at,
+    s.start_ms,
+    ts_headline(CAST($1 AS regconfig), s.text, q.query, 'StartSel=' || CAST($5 AS text) || ', StopSel=' || CAST($6 AS text) || ', MaxFragments=1, MinWords=10, MaxWords=30') AS snippet,
+    ts_rank(s.search, q.query) AS rank
+FROM transcript_segments s
+JOIN videos v ON v.channel_id = s.channel_id AND v.video_id = s.video_id
+CROSS JOIN websearch_to_tsquery(CAST($1 AS regconfig), $2) AS q(query)
+WHERE s.search @@ q.query
+AND ($3 = '' OR s.channel_id = $3)
+ORDER BY rank DESC, v.published_at DESC, s.position ASC
+LIMIT $4
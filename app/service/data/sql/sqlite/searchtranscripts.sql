@@ -0,0 +1,13 @@
+-- FTS5 does not have text search configurations (i.e. $1) so every language shares the tokenizer
+SELECT
+    v.id, v.channel_id, v.video_id, v.title, v.video_url, v.published_at,
+    s.start_ms,
+    snippet(transcript_segments_search, 0, $5, $6, '...', 30) AS snippet,
+    -bm25(transcript_segments_search) AS rank
+FROM transcript_segments_search
+JOIN transcript_segments s ON s.id = transcript_segments_search.rowid
+JOIN videos v ON v.channel_id = s.channel_id AND v.video_id = s.video_id
+WHERE transcript_segments_search MATCH $2
+AND ($3 = '' OR s.channel_id = $3)
+ORDER BY rank DESC, v.published_at DESC, s.position ASC
+LIMIT $4
@@ -3,5 +3,6 @@ SET
     state = $1, 
     videos = $2, 
     errors = $3, 
-    completed_at = $4
-WHERE id = $5
+    stages = $4, 
+    completed_at = $5
+WHERE id = $6
@@ -0,0 +1,5 @@
+UPDATE job_videos 
+SET 
+    error = $1, 
+    completed_at = $2
+WHERE id = $3
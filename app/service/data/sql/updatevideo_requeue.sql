@@ -0,0 +1,8 @@
+UPDATE videos 
+SET 
+    updated_at = NOW(),
+    %[1]s_status = 'pending',
+    %[1]s_attempts = 0,
+    %[1]s_next_attempt_at = NULL,
+    %[1]s_error = NULL
+WHERE id = $1
@@ -0,0 +1,6 @@
+UPDATE videos 
+SET 
+    updated_at = NOW(),
+    %[1]s_status = $1,
+    %[1]s_started_at = CASE WHEN $1 = 'in_progress' THEN NOW() ELSE %[1]s_started_at END
+WHERE id = $2
@@ -0,0 +1,9 @@
+UPDATE videos 
+SET 
+    updated_at = NOW(),
+    %[1]s_status = CASE WHEN %[1]s_attempts + 1 >= $2 THEN 'dead_letter' ELSE 'failed' END,
+    %[1]s_next_attempt_at = NULL,
+    %[1]s_error = $1,
+    %[1]s_attempts = %[1]s_attempts + 1
+WHERE %[1]s_status = 'in_progress' 
+AND COALESCE(%[1]s_started_at, updated_at) < NOW() - INTERVAL '1 minute' * ($3)
@@ -3,5 +3,15 @@ SET
     updated_at = NOW(),
     views = $1, 
     comments = $2, 
-    likes = $3 
-WHERE id = $4
+    likes = $3, 
+    description = $4, 
+    tags = $5, 
+    thumbnails = $6, 
+    category_id = $7, 
+    default_audio_language = $8, 
+    caption = $9, 
+    live_broadcast_content = $10, 
+    privacy_status = $11, 
+    availability = $12, 
+    short = $13 
+WHERE id = $14
@@ -2,6 +2,9 @@ UPDATE videos
 SET 
     updated_at = NOW(),
     audioed_at = NOW(),
-    audio_url = $1
-WHERE id = $2
-
+    audio_url = $1,
+    audio_status = CASE WHEN $2 = 'failed' AND CASE WHEN audio_status = 'waiting_external' THEN audio_attempts ELSE audio_attempts + 1 END >= $4 THEN 'dead_letter' ELSE $2 END,
+    audio_next_attempt_at = CASE WHEN $2 = 'failed' THEN NOW() + INTERVAL '1 minute' * ($5 * POWER(2, audio_attempts)) ELSE NULL END,
+    audio_error = $3,
+    audio_attempts = CASE WHEN audio_status = 'waiting_external' THEN audio_attempts ELSE audio_attempts + 1 END
+WHERE id = $6
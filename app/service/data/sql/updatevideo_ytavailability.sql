@@ -0,0 +1,5 @@
+UPDATE videos 
+SET 
+    updated_at = NOW(),
+    availability = $1
+WHERE id = $2
@@ -0,0 +1,6 @@
+UPDATE videos 
+SET 
+    updated_at = NOW(),
+    captions_attempted_at = NOW(),
+    captions_error = $1
+WHERE id = $2
@@ -0,0 +1,8 @@
+UPDATE videos 
+SET 
+    updated_at = NOW(),
+    embedding_status = CASE WHEN $1 = 'failed' AND embedding_attempts + 1 >= $3 THEN 'dead_letter' ELSE $1 END,
+    embedding_next_attempt_at = CASE WHEN $1 = 'failed' THEN NOW() + INTERVAL '1 minute' * ($4 * POWER(2, embedding_attempts)) ELSE NULL END,
+    embedding_error = $2,
+    embedding_attempts = CASE WHEN $1 = 'failed' THEN embedding_attempts + 1 ELSE 0 END
+WHERE id = $5
@@ -2,6 +2,12 @@ UPDATE videos
 SET 
     updated_at = NOW(),
     extracted_at = NOW(),
-    extraction_url = $1 
-WHERE id = $2
-
+    extraction_url = $1,
+    extraction_status = CASE WHEN $2 = 'failed' AND extraction_attempts + 1 >= $6 THEN 'dead_letter' ELSE $2 END,
+    extraction_next_attempt_at = CASE WHEN $2 = 'failed' THEN NOW() + INTERVAL '1 minute' * ($7 * POWER(2, extraction_attempts)) ELSE NULL END,
+    extraction_error = $3,
+    format_ids = $4,
+    extraction_error_class = $5,
+    extraction_attempts = extraction_attempts + 1,
+    extraction_attempted_at = NOW() 
+WHERE id = $8
@@ -0,0 +1,8 @@
+UPDATE videos 
+SET 
+    updated_at = NOW(),
+    availability = $1
+WHERE channel_id = $2 
+AND video_id = $3 
+AND availability != $1
+RETURNING id
@@ -0,0 +1,6 @@
+UPDATE videos 
+SET 
+    updated_at = NOW(),
+    short = $1,
+    short_classified_at = NOW()
+WHERE id = $2
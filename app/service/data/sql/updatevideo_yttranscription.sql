@@ -2,6 +2,11 @@ UPDATE videos
 SET 
     updated_at = NOW(),
     transcribed_at = NOW(),
-    transcription_url = $1
-WHERE id = $2
-
+    transcription_url = $1,
+    transcription_status = CASE WHEN $2 = 'failed' AND transcription_attempts + 1 >= $6 THEN 'dead_letter' ELSE $2 END,
+    transcription_next_attempt_at = CASE WHEN $2 = 'failed' THEN NOW() + INTERVAL '1 minute' * ($7 * POWER(2, transcription_attempts)) ELSE NULL END,
+    transcription_error = $3,
+    transcription_attempts = transcription_attempts + 1,
+    transcription_source = $4,
+    subtitles_url = $5
+WHERE id = $8
@@ -1,6 +1,9 @@
 UPDATE videos 
 SET 
     updated_at = NOW(),
-    transcription_url = $1
-WHERE id = $2
-
+    transcription_url = $1,
+    transcription_status = CASE WHEN $2 = 'failed' AND transcription_attempts + 1 >= $4 THEN 'dead_letter' ELSE $2 END,
+    transcription_next_attempt_at = CASE WHEN $2 = 'failed' THEN NOW() + INTERVAL '1 minute' * ($5 * POWER(2, transcription_attempts)) ELSE NULL END,
+    transcription_error = $3,
+    transcription_attempts = transcription_attempts + 1
+WHERE id = $6
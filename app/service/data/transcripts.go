@@ -0,0 +1,371 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// segmentsBatchSize is the number of transcript segments written to the database in one statement
+const segmentsBatchSize = 200
+
+// searchSnippetsPerVideo is the maximum number of snippets returned for every matching video
+const searchSnippetsPerVideo = 3
+
+// The database marks the snippet matches with control characters so the transcript text can be
+// HTML-escaped before the matches are highlighted (i.e. <b>match</b>)
+const (
+	snippetStartSel = "\x02"
+	snippetStopSel  = "\x03"
+)
+
+var snippetHighlighter = strings.NewReplacer(snippetStartSel, "<b>", snippetStopSel, "</b>")
+
+// searchRow is a matching transcript segment along with its video
+type searchRow struct {
+	ID          int64     `db:"id"`
+	ChannelID   string    `db:"channel_id"`
+	VideoID     string    `db:"video_id"`
+	Title       string    `db:"title"`
+	VideoURL    string    `db:"video_url"`
+	PublishedAt time.Time `db:"published_at"`
+	StartMs     *int64    `db:"start_ms"`
+	Snippet     string    `db:"snippet"`
+	Rank        float64   `db:"rank"`
+}
+
+// NewTranscriptSegments replaces the searchable transcript segments of the video and marks it indexed
+func (svc *dataService) NewTranscriptSegments(ctx context.Context, video Video, segments []TranscriptSegment) error {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := svc.Db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	replaced := []int64{}
+	err = tx.SelectContext(ctx, &replaced, `SELECT id FROM transcript_segments WHERE channel_id = $1 AND video_id = $2`, video.ChannelID, video.VideoID)
+	if err != nil {
+		return err
+	}
+
+	// The embeddings of the replaced segments go with them
+	_, err = tx.ExecContext(ctx, `DELETE FROM transcript_embeddings WHERE segment_id IN (SELECT id FROM transcript_segments WHERE channel_id = $1 AND video_id = $2)`, video.ChannelID, video.VideoID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM transcript_segments WHERE channel_id = $1 AND video_id = $2`, video.ChannelID, video.VideoID)
+	if err != nil {
+		return err
+	}
+
+	for i := range segments {
+		segments[i].ChannelID = video.ChannelID
+		segments[i].VideoID = video.VideoID
+		segments[i].Position = i
+	}
+
+	for batch := range slices.Chunk(segments, segmentsBatchSize) {
+		_, err = tx.NamedExecContext(ctx, svc.statement(inserttranscriptsegmentSQL), batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE videos SET indexed_at = NOW() WHERE channel_id = $1 AND video_id = $2`, video.ChannelID, video.VideoID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	svc.vectors.remove(video.ChannelID, replaced)
+	return nil
+}
+
+// RetrieveTranscriptSegments returns the transcript segments of the video in order
+func (svc *dataService) RetrieveTranscriptSegments(ctx context.Context, channelID, videoID string) ([]TranscriptSegment, error) {
+	segments := []TranscriptSegment{}
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return segments, err
+	}
+
+	query := `
+        SELECT * FROM transcript_segments 
+		WHERE channel_id = $1 
+		AND video_id = $2 
+		ORDER BY position ASC 
+    `
+
+	err = svc.Db.SelectContext(ctx, &segments, query, channelID, videoID)
+	if err != nil {
+		return segments, err
+	}
+
+	return segments, nil
+}
+
+// NewTranscriptEmbeddings stores (or replaces) the vectors of the transcript segments
+func (svc *dataService) NewTranscriptEmbeddings(ctx context.Context, embeddings []TranscriptEmbedding) error {
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	for batch := range slices.Chunk(embeddings, segmentsBatchSize) {
+		_, err = svc.Db.NamedExecContext(ctx, svc.statement(inserttranscriptembeddingSQL), batch)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The cached vectors of the models that were searched already are kept up to date
+	ids := []int64{}
+	for _, embedding := range embeddings {
+		if svc.vectors.loaded(embedding.Model) {
+			ids = append(ids, embedding.SegmentID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In(`SELECT id, channel_id FROM transcript_segments WHERE id IN (?)`, ids)
+	if err != nil {
+		return err
+	}
+
+	segments := []TranscriptSegment{}
+	err = svc.Db.SelectContext(ctx, &segments, svc.Db.Rebind(query), args...)
+	if err != nil {
+		return err
+	}
+
+	channels := map[int64]string{}
+	for _, segment := range segments {
+		channels[segment.ID] = segment.ChannelID
+	}
+	svc.vectors.put(embeddings, channels)
+
+	return nil
+}
+
+// SemanticSearch returns the k transcript passages (optionally within a channel) that are the most similar to the vector.
+// The vectors are cached and ranked in-process (i.e. an exhaustive cosine similarity scan) so no database extension is needed.
+func (svc *dataService) SemanticSearch(ctx context.Context, channelID, model string, vector Vector, k int) ([]Passage, error) {
+	passages := []Passage{}
+	if k <= 0 {
+		return passages, fmt.Errorf("Invalid k %d", k)
+	}
+
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return passages, err
+	}
+
+	best, err := svc.vectors.search(ctx, svc.Db, model, channelID, vector, k)
+	if err != nil {
+		return passages, err
+	}
+
+	if len(best) == 0 {
+		return passages, nil
+	}
+
+	scores := map[int64]float64{}
+	ids := []int64{}
+	for _, s := range best {
+		scores[s.segmentID] = s.score
+		ids = append(ids, s.segmentID)
+	}
+
+	query, args, err := sqlx.In(`
+        SELECT s.id, s.channel_id, s.video_id, s.text, s.start_ms, v.title, v.video_url, v.published_at 
+		FROM transcript_segments s 
+		JOIN videos v ON v.channel_id = s.channel_id AND v.video_id = s.video_id 
+		WHERE s.id IN (?)
+    `, ids)
+	if err != nil {
+		return passages, err
+	}
+
+	err = svc.Db.SelectContext(ctx, &passages, svc.Db.Rebind(query), args...)
+	if err != nil {
+		return passages, err
+	}
+
+	for i := range passages {
+		passages[i].Score = scores[passages[i].SegmentID]
+		passages[i].URL = passages[i].VideoURL
+		if passages[i].StartMs != nil {
+			start := *passages[i].StartMs / 1000
+			passages[i].Start = &start
+			passages[i].URL = TimestampURL(passages[i].VideoURL, start)
+		}
+	}
+
+	sort.Slice(passages, func(i, j int) bool {
+		return passages[i].Score > passages[j].Score
+	})
+
+	return passages, nil
+}
+
+// SearchTranscripts returns the videos whose transcripts match the text, best matches first.
+// The channel is optional. The text may quote phrases (i.e. "exact phrase").
+func (svc *dataService) SearchTranscripts(ctx context.Context, channelID, text string, max int) ([]SearchResult, error) {
+	results := []SearchResult{}
+	if strings.TrimSpace(text) == "" {
+		return results, fmt.Errorf("Search text is required")
+	}
+
+	if max <= 0 {
+		return results, fmt.Errorf("Invalid max %d", max)
+	}
+
+	err := svc.dbConnection(ctx)
+	if err != nil {
+		return results, err
+	}
+
+	// Every video contributes a few snippets so more segments than videos are needed
+	rows := []searchRow{}
+	err = svc.Db.SelectContext(ctx, &rows, svc.statement(searchtranscriptsSQL), searchConfig(text), svc.dialect.searchQuery(text), channelID, max*searchSnippetsPerVideo, snippetStartSel, snippetStopSel)
+	if err != nil {
+		return results, err
+	}
+
+	// The rows are sorted by rank so the first row of a video is its best match
+	positions := map[int64]int{}
+	for _, row := range rows {
+		i, ok := positions[row.ID]
+		if !ok {
+			if len(results) == max {
+				continue
+			}
+
+			results = append(results, SearchResult{
+				ID:          row.ID,
+				ChannelID:   row.ChannelID,
+				VideoID:     row.VideoID,
+				Title:       row.Title,
+				VideoURL:    row.VideoURL,
+				PublishedAt: row.PublishedAt,
+				Rank:        row.Rank,
+				Snippets:    []SearchSnippet{},
+			})
+			i = len(results) - 1
+			positions[row.ID] = i
+		}
+
+		if len(results[i].Snippets) == searchSnippetsPerVideo {
+			continue
+		}
+
+		snippet := SearchSnippet{
+			Snippet: highlightSnippet(row.Snippet),
+			URL:     row.VideoURL,
+		}
+		if row.StartMs != nil {
+			start := *row.StartMs / 1000
+			snippet.Start = &start
+			snippet.URL = TimestampURL(row.VideoURL, start)
+		}
+		results[i].Snippets = append(results[i].Snippets, snippet)
+	}
+
+	return results, nil
+}
+
+// highlightSnippet HTML-escapes the transcript text of the snippet and highlights its matches
+func highlightSnippet(snippet string) string {
+	return snippetHighlighter.Replace(html.EscapeString(snippet))
+}
+
+// TimestampURL returns the video URL that starts playing at the time (in seconds)
+func TimestampURL(videoURL string, seconds int64) string {
+	u, err := url.Parse(videoURL)
+	if err != nil {
+		return videoURL
+	}
+
+	query := u.Query()
+	query.Set("t", fmt.Sprintf("%ds", seconds))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// similarity is the cosine similarity of a transcript segment to the search
+type similarity struct {
+	segmentID int64
+	score     float64
+}
+
+// similarities is a min-heap (i.e. container/heap) of similarities
+type similarities []similarity
+
+func (s similarities) Len() int           { return len(s) }
+func (s similarities) Less(i, j int) bool { return s[i].score < s[j].score }
+func (s similarities) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s *similarities) Push(x interface{}) {
+	*s = append(*s, x.(similarity))
+}
+
+func (s *similarities) Pop() interface{} {
+	old := *s
+	x := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return x
+}
+
+// cosineSimilarity is 1 for vectors in the same direction and 0 for unrelated (or mismatched) vectors
+func cosineSimilarity(a, b Vector) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// searchConfig is the Postgres text search configuration of the text language
+func searchConfig(text string) string {
+	for _, r := range text {
+		if unicode.Is(unicode.Arabic, r) {
+			return "arabic"
+		}
+	}
+
+	return "english"
+}
@@ -1,32 +1,66 @@
 package data
 
+import "context"
+
 type IService interface {
-	ResetFactory() error
-
-	NewVideo(video Video) (bool, int64, error)
-	UpdateVideo(video *Video, jobType JobType) error
-
-	RetrieveVideos(channelID string, page, pageSize int, orderBy, orderDir string) ([]Video, error)
-	RetrieveUnextractedVideos(channelID string, max int) ([]Video, error)
-	RetrieveExtractErroredVideos(channelID string, max int) ([]Video, error)
-	RetrieveUnexternalizedVideos(channelID string, max int) ([]Video, error)
-	RetrieveUnaudioedVideos(channelID string, max int) ([]Video, error)
-	RetrieveAudioErroredVideos(channelID string, max int) ([]Video, error)
-	RetrieveUntranscribedVideos(channelID string, max int) ([]Video, error)
-	RetrieveTranscribeErroredVideos(channelID string, max int) ([]Video, error)
-	RetrieveUpdatedVideos(channelID string, max int) ([]Video, error)
-
-	RetrieveVideoByIDs(channelID string, videoID string) (Video, error)
-	RetrieveVideoByID(id int64) (Video, error)
-
-	NewJob(job Job) (int64, error)
-	UpdateJob(job *Job) error
-	RetrieveJobByID(id int64) (Job, error)
-	IsPendingJobsByTypeNChannel(channelID string, jobType JobType) (bool, error)
-
-	NewAPIKey(key string) error
-	IsAPIKeyValid(key string) (bool, error)
-	NewError(source, body string) error
+	ResetFactory(ctx context.Context) error
+
+	MigrateUp(ctx context.Context) ([]Migration, error)
+	MigrateDown(ctx context.Context) (Migration, error)
+	BaselineMigrations(ctx context.Context, version int64) error
+	RetrieveMigrations(ctx context.Context) ([]Migration, error)
+	CheckMigrations(ctx context.Context) error
+
+	NewVideo(ctx context.Context, video Video) (bool, int64, error)
+	NewVideos(ctx context.Context, videos []Video) ([]UpsertedVideo, error)
+	UpdateVideo(ctx context.Context, video *Video, jobType JobType) error
+	UpdateVideoAvailability(ctx context.Context, video *Video) error
+	UpdateVideoStageStatus(ctx context.Context, video *Video, stage Stage, status StageStatus) error
+	ReleaseStaleVideos(ctx context.Context) (int64, error)
+	RequeueVideo(ctx context.Context, video *Video, stage Stage) error
+
+	RetrieveVideos(ctx context.Context, channelID string, page, pageSize int, orderBy, orderDir string) ([]Video, error)
+	QueryVideos(ctx context.Context, filter VideoFilter) (VideoPage, error)
+	RetrieveUnextractedVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveExtractErroredVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveUnexternalizedVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveUnaudioedVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveAudioErroredVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveUntranscribedVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveCaptionableVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveTranscribeErroredVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveDeadLetteredVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveUpdatedVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveUnindexedVideos(ctx context.Context, channelID string, max int) ([]Video, error)
+	RetrieveUnembeddedVideos(ctx context.Context, channelID, model string, max int) ([]Video, error)
+
+	RetrieveVideoByIDs(ctx context.Context, channelID string, videoID string) (Video, error)
+	RetrieveVideosByIDs(ctx context.Context, channelID string, videoIDs []string) ([]Video, error)
+	RetrieveVideoByID(ctx context.Context, id int64) (Video, error)
+
+	NewVideoStatsSnapshot(ctx context.Context, video Video) error
+	RetrieveVideoStats(ctx context.Context, id int64, days int) (VideoStats, error)
+
+	NewTranscriptSegments(ctx context.Context, video Video, segments []TranscriptSegment) error
+	RetrieveTranscriptSegments(ctx context.Context, channelID, videoID string) ([]TranscriptSegment, error)
+	SearchTranscripts(ctx context.Context, channelID, text string, max int) ([]SearchResult, error)
+	NewTranscriptEmbeddings(ctx context.Context, embeddings []TranscriptEmbedding) error
+	SemanticSearch(ctx context.Context, channelID, model string, vector Vector, k int) ([]Passage, error)
+
+	NewJob(ctx context.Context, job Job) (int64, error)
+	UpdateJob(ctx context.Context, job *Job) error
+	RetrieveJobByID(ctx context.Context, id int64) (Job, error)
+	NewJobVideo(ctx context.Context, jobVideo JobVideo) (int64, error)
+	UpdateJobVideo(ctx context.Context, jobVideo *JobVideo) error
+
+	NewAPIKey(ctx context.Context, key string) error
+	IsAPIKeyValid(ctx context.Context, key string) (bool, error)
+	NewError(ctx context.Context, source, body string) error
+
+	NewCookies(ctx context.Context, content []byte) error
+	RetrieveCookies(ctx context.Context) ([]byte, error)
+
+	Ping(ctx context.Context) error
 
 	Finalize()
 }
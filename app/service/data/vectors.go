@@ -0,0 +1,178 @@
+package data
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// vectorsTTL is how long the vectors of a model are cached before they are reloaded so the
+// embeddings written by the other app instances show up as well
+const vectorsTTL = 10 * time.Minute
+
+// modelVectors are the cached vectors of a model by channel and segment
+type modelVectors struct {
+	channels map[string]map[int64]Vector
+	loadedAt time.Time
+}
+
+// vectorIndex caches the transcript embeddings in memory so the semantic search does not
+// read every vector from the database. The embedding writes of the app instance update it.
+type vectorIndex struct {
+	mutex  sync.RWMutex
+	models map[string]*modelVectors
+}
+
+func newVectorIndex() *vectorIndex {
+	return &vectorIndex{
+		models: map[string]*modelVectors{},
+	}
+}
+
+// search returns the k segments (optionally within a channel) whose vectors of the model are
+// the most similar to the vector. The vectors of the model are loaded on the first search.
+func (idx *vectorIndex) search(ctx context.Context, db *sqlx.DB, model, channelID string, vector Vector, k int) (similarities, error) {
+	err := idx.load(ctx, db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	cached, ok := idx.models[model]
+	if !ok {
+		return similarities{}, nil
+	}
+
+	// The best k matches so far are kept in a min-heap so the worst of them is replaced first
+	best := &similarities{}
+	for channel, segments := range cached.channels {
+		if channelID != "" && channel != channelID {
+			continue
+		}
+
+		for segmentID, v := range segments {
+			score := cosineSimilarity(vector, v)
+			if best.Len() < k {
+				heap.Push(best, similarity{segmentID: segmentID, score: score})
+			} else if score > (*best)[0].score {
+				(*best)[0] = similarity{segmentID: segmentID, score: score}
+				heap.Fix(best, 0)
+			}
+		}
+	}
+
+	return *best, nil
+}
+
+// load reads the vectors of the model from the database unless they are cached and fresh
+func (idx *vectorIndex) load(ctx context.Context, db *sqlx.DB, model string) error {
+	idx.mutex.RLock()
+	cached, ok := idx.models[model]
+	fresh := ok && time.Since(cached.loadedAt) < vectorsTTL
+	idx.mutex.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	query := `
+        SELECT s.channel_id, e.segment_id, e.vector FROM transcript_embeddings e
+		JOIN transcript_segments s ON s.id = e.segment_id
+		WHERE e.model = $1
+    `
+
+	rows, err := db.QueryxContext(ctx, query, model)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	loaded := &modelVectors{
+		channels: map[string]map[int64]Vector{},
+		loadedAt: time.Now(),
+	}
+	for rows.Next() {
+		var channelID string
+		embedding := TranscriptEmbedding{}
+		err = rows.Scan(&channelID, &embedding.SegmentID, &embedding.Vector)
+		if err != nil {
+			return err
+		}
+
+		loaded.put(channelID, embedding.SegmentID, embedding.Vector)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.models[model] = loaded
+	return nil
+}
+
+// loaded tells whether the vectors of the model are cached
+func (idx *vectorIndex) loaded(model string) bool {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	_, ok := idx.models[model]
+	return ok
+}
+
+// put caches the stored embeddings of the cached models. The channels are keyed by segment ID.
+func (idx *vectorIndex) put(embeddings []TranscriptEmbedding, channels map[int64]string) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	for _, embedding := range embeddings {
+		cached, ok := idx.models[embedding.Model]
+		if !ok {
+			continue
+		}
+
+		channelID, ok := channels[embedding.SegmentID]
+		if !ok {
+			continue
+		}
+
+		cached.put(channelID, embedding.SegmentID, embedding.Vector)
+	}
+}
+
+// remove drops the vectors of the channel segments from every cached model
+func (idx *vectorIndex) remove(channelID string, segmentIDs []int64) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	for _, cached := range idx.models {
+		for _, id := range segmentIDs {
+			delete(cached.channels[channelID], id)
+		}
+	}
+}
+
+// reset drops the cached vectors
+func (idx *vectorIndex) reset() {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	idx.models = map[string]*modelVectors{}
+}
+
+func (m *modelVectors) put(channelID string, segmentID int64, vector Vector) {
+	segments, ok := m.channels[channelID]
+	if !ok {
+		segments = map[int64]Vector{}
+		m.channels[channelID] = segments
+	}
+
+	segments[segmentID] = vector
+}
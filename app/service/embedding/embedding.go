@@ -0,0 +1,42 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/khaledhikmat/yt-extractor/service/config"
+)
+
+var providers map[string]IService
+
+type embeddingService struct {
+	ConfigSvc config.IService
+}
+
+func New(cfgsvc config.IService) IService {
+	providers = map[string]IService{
+		"openai": newOpenai(cfgsvc),
+		"local":  newLocal(cfgsvc),
+	}
+	return &embeddingService{
+		ConfigSvc: cfgsvc,
+	}
+}
+
+func (svc *embeddingService) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	r, ok := providers[svc.ConfigSvc.GetEmbeddingProvider()]
+	if !ok {
+		return nil, fmt.Errorf("embedding provider %s not found", svc.ConfigSvc.GetEmbeddingProvider())
+	}
+
+	return r.Embed(ctx, texts)
+}
+
+func (svc *embeddingService) Model() string {
+	r, ok := providers[svc.ConfigSvc.GetEmbeddingProvider()]
+	if !ok {
+		return ""
+	}
+
+	return r.Model()
+}
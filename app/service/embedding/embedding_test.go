@@ -0,0 +1,45 @@
+package embedding
+
+import (
+	"context"
+	"testing"
+
+	"github.com/khaledhikmat/yt-extractor/service/config"
+)
+
+func TestLocalEmbedding(t *testing.T) {
+	t.Setenv("EMBEDDING_PROVIDER", "local")
+	t.Setenv("EMBEDDING_DIMENSIONS", "64")
+
+	svc := New(config.New())
+	vectors, err := svc.Embed(context.Background(), []string{"Fasting in Ramadan", "fasting, ramadan", "الصبر مفتاح الفرج"})
+	if err != nil {
+		t.Fatalf("embed produced %v", err)
+	}
+
+	if len(vectors) != 3 || len(vectors[0]) != 64 {
+		t.Fatalf("expected 3 vectors of 64 dimensions, got %d", len(vectors))
+	}
+
+	// Case and punctuation do not matter: "in" is the only difference
+	if dot(vectors[0], vectors[1]) <= dot(vectors[0], vectors[2]) {
+		t.Errorf("expected texts that share words to be more similar")
+	}
+
+	again, _ := svc.Embed(context.Background(), []string{"Fasting in Ramadan"})
+	if dot(vectors[0], again[0]) < 0.999 {
+		t.Errorf("expected the embedding to be deterministic")
+	}
+
+	if svc.Model() != "local-64" {
+		t.Errorf("unexpected model %s", svc.Model())
+	}
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
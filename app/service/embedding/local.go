@@ -0,0 +1,75 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/khaledhikmat/yt-extractor/service/config"
+)
+
+// localService is a deterministic embedding that hashes the words of the text into the vector
+// dimensions (i.e. feature hashing). It has no notion of meaning: texts are similar if they share words.
+// It needs no API so it serves tests and offline runs.
+type localService struct {
+	ConfigSvc config.IService
+}
+
+func newLocal(cfgsvc config.IService) IService {
+	return &localService{
+		ConfigSvc: cfgsvc,
+	}
+}
+
+func (svc *localService) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	dimensions := svc.ConfigSvc.GetEmbeddingDimensions()
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		vector := make([]float32, dimensions)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, word := range words {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(word))
+			sum := h.Sum32()
+
+			// The sign bit spreads the collisions so they cancel out rather than add up
+			sign := float32(1)
+			if sum&(1<<31) != 0 {
+				sign = -1
+			}
+			vector[int(sum%uint32(dimensions))] += sign
+		}
+
+		vectors = append(vectors, normalize(vector))
+	}
+
+	return vectors, nil
+}
+
+func (svc *localService) Model() string {
+	return fmt.Sprintf("local-%d", svc.ConfigSvc.GetEmbeddingDimensions())
+}
+
+// normalize scales the vector to unit length so the dot product is the cosine similarity
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+
+	if sum == 0 {
+		return vector
+	}
+
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+
+	return vector
+}
@@ -0,0 +1,100 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/khaledhikmat/yt-extractor/service/config"
+	"github.com/khaledhikmat/yt-extractor/service/lgr"
+)
+
+type embeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// openaiService calls an OpenAI-compatible embeddings API (i.e. OpenAI or a self-hosted server)
+type openaiService struct {
+	ConfigSvc config.IService
+}
+
+func newOpenai(cfgsvc config.IService) IService {
+	return &openaiService{
+		ConfigSvc: cfgsvc,
+	}
+}
+
+func (svc *openaiService) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	lgr.Logger.Debug("Embed",
+		slog.Int("texts", len(texts)),
+	)
+
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+
+	body, err := json.Marshal(embeddingRequest{
+		Model:      svc.ConfigSvc.GetEmbeddingModel(),
+		Input:      texts,
+		Dimensions: svc.ConfigSvc.GetEmbeddingDimensions(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", svc.ConfigSvc.GetEmbeddingBaseURL()+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+svc.ConfigSvc.GetEmbeddingAPIKey())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, respBody)
+	}
+
+	var embeddingResponse embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResponse); err != nil {
+		return nil, fmt.Errorf("could not decode response: %w", err)
+	}
+
+	if len(embeddingResponse.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, received %d", len(texts), len(embeddingResponse.Data))
+	}
+
+	// The embeddings are not guaranteed to come back in the order of the texts
+	vectors := make([][]float32, len(texts))
+	for _, data := range embeddingResponse.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, fmt.Errorf("unexpected embedding index %d", data.Index)
+		}
+		vectors[data.Index] = data.Embedding
+	}
+
+	return vectors, nil
+}
+
+func (svc *openaiService) Model() string {
+	return fmt.Sprintf("%s-%d", svc.ConfigSvc.GetEmbeddingModel(), svc.ConfigSvc.GetEmbeddingDimensions())
+}
@@ -0,0 +1,10 @@
+package embedding
+
+import "context"
+
+type IService interface {
+	// Embed returns the vectors of the texts in the same order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model identifies the vectors (i.e. model and dimensions) so vectors of different models are never compared
+	Model() string
+}
@@ -0,0 +1,28 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// fakeService answers without an LLM: it cites the first source of the prompt (i.e. [1])
+// if there is one. It serves tests and offline runs.
+type fakeService struct{}
+
+func newFake() IService {
+	return &fakeService{}
+}
+
+func (svc *fakeService) Chat(_ context.Context, messages []Message) (string, error) {
+	if len(messages) == 0 {
+		return "", fmt.Errorf("messages are required")
+	}
+
+	prompt := messages[len(messages)-1].Content
+	if !strings.Contains(prompt, "[1]") {
+		return NoAnswer, nil
+	}
+
+	return "This is what the first passage says [1].", nil
+}
@@ -0,0 +1,33 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/khaledhikmat/yt-extractor/service/config"
+)
+
+var providers map[string]IService
+
+type llmService struct {
+	ConfigSvc config.IService
+}
+
+func New(cfgsvc config.IService) IService {
+	providers = map[string]IService{
+		"openai": newOpenai(cfgsvc),
+		"fake":   newFake(),
+	}
+	return &llmService{
+		ConfigSvc: cfgsvc,
+	}
+}
+
+func (svc *llmService) Chat(ctx context.Context, messages []Message) (string, error) {
+	r, ok := providers[svc.ConfigSvc.GetLLMProvider()]
+	if !ok {
+		return "", fmt.Errorf("llm provider %s not found", svc.ConfigSvc.GetLLMProvider())
+	}
+
+	return r.Chat(ctx, messages)
+}
@@ -0,0 +1,43 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/khaledhikmat/yt-extractor/service/config"
+)
+
+func TestFakeAnswer(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "fake")
+
+	svc := New(config.New())
+	messages := AnswerPrompt("What breaks the fast?", []Source{
+		{Title: "Fasting", Label: "1:05", Text: "Eating and drinking break the fast."},
+	})
+	if !strings.Contains(messages[1].Content, "[1] Fasting (1:05)") {
+		t.Errorf("unexpected prompt %s", messages[1].Content)
+	}
+
+	answer, err := svc.Chat(context.Background(), messages)
+	if err != nil {
+		t.Fatalf("chat produced %v", err)
+	}
+
+	cited := CitedSources(answer, 1)
+	if len(cited) != 1 || cited[0] != 0 {
+		t.Errorf("expected the first source to be cited, got %v", cited)
+	}
+
+	answer, _ = svc.Chat(context.Background(), AnswerPrompt("What breaks the fast?", nil))
+	if answer != NoAnswer {
+		t.Errorf("expected no answer, got %s", answer)
+	}
+}
+
+func TestCitedSources(t *testing.T) {
+	cited := CitedSources("See [2] and [1], again [2], not [0] nor [7].", 3)
+	if len(cited) != 2 || cited[0] != 1 || cited[1] != 0 {
+		t.Errorf("unexpected citations %v", cited)
+	}
+}
@@ -0,0 +1,14 @@
+package llm
+
+// Message is a chat message. The role is `system`, `user` or `assistant`.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Source is a passage that an answer can cite by its number (i.e. [1])
+type Source struct {
+	Title string
+	Label string
+	Text  string
+}
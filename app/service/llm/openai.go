@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/khaledhikmat/yt-extractor/service/config"
+	"github.com/khaledhikmat/yt-extractor/service/lgr"
+)
+
+type chatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+}
+
+// openaiService calls an OpenAI-compatible chat completions API (i.e. OpenAI or a self-hosted server)
+type openaiService struct {
+	ConfigSvc config.IService
+}
+
+func newOpenai(cfgsvc config.IService) IService {
+	return &openaiService{
+		ConfigSvc: cfgsvc,
+	}
+}
+
+func (svc *openaiService) Chat(ctx context.Context, messages []Message) (string, error) {
+	lgr.Logger.Debug("Chat",
+		slog.String("model", svc.ConfigSvc.GetLLMModel()),
+		slog.Int("messages", len(messages)),
+	)
+
+	// A low temperature keeps the answers close to the passages
+	body, err := json.Marshal(chatRequest{
+		Model:       svc.ConfigSvc.GetLLMModel(),
+		Messages:    messages,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", svc.ConfigSvc.GetLLMBaseURL()+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("could not create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+svc.ConfigSvc.GetLLMAPIKey())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status code: %d, response: %s", resp.StatusCode, respBody)
+	}
+
+	var chatResponse chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResponse); err != nil {
+		return "", fmt.Errorf("could not decode response: %w", err)
+	}
+
+	if len(chatResponse.Choices) == 0 {
+		return "", fmt.Errorf("response has no choices")
+	}
+
+	return chatResponse.Choices[0].Message.Content, nil
+}
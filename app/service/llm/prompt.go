@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NoAnswer is what the model replies if the sources do not answer the question
+const NoAnswer = "The transcripts do not answer this question."
+
+var citationRegex = regexp.MustCompile(`\[(\d+)\]`)
+
+// AnswerPrompt returns the messages that have the model answer the question from the numbered sources only
+func AnswerPrompt(question string, sources []Source) []Message {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Question: %s\n\nPassages:\n", question)
+	for i, source := range sources {
+		fmt.Fprintf(&sb, "\n[%d] %s", i+1, source.Title)
+		if source.Label != "" {
+			fmt.Fprintf(&sb, " (%s)", source.Label)
+		}
+		fmt.Fprintf(&sb, "\n%s\n", source.Text)
+	}
+
+	return []Message{
+		{
+			Role: "system",
+			Content: "You answer questions about a Youtube channel from passages of its video transcripts. " +
+				"Only use the passages. Cite every passage you use by its number in square brackets (i.e. [1]). " +
+				"Answer in the language of the question. " +
+				fmt.Sprintf("If the passages do not answer the question, reply with: %s", NoAnswer),
+		},
+		{
+			Role:    "user",
+			Content: sb.String(),
+		},
+	}
+}
+
+// CitedSources returns the (zero-based) indexes of the sources that the answer cites, in citation order
+func CitedSources(answer string, sources int) []int {
+	cited := []int{}
+	seen := map[int]bool{}
+	for _, match := range citationRegex.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > sources || seen[n] {
+			continue
+		}
+
+		seen[n] = true
+		cited = append(cited, n-1)
+	}
+
+	return cited
+}
@@ -0,0 +1,8 @@
+package llm
+
+import "context"
+
+type IService interface {
+	// Chat returns the reply of the model to the messages
+	Chat(ctx context.Context, messages []Message) (string, error)
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
@@ -70,6 +71,26 @@ func (svc *s3Service) NewFile(ctx context.Context, folder, filePath, identifier
 	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucketName, svc.ConfigSvc.GetStorageRegion(), keyName), nil
 }
 
+func (svc *s3Service) RetrieveFile(ctx context.Context, folder, identifier string) ([]byte, error) {
+	bucketName := svc.ConfigSvc.GetStorageBucket()
+	keyName := fmt.Sprintf("%s/%s", folder, identifier)
+	lgr.Logger.Debug("S3.RetrieveFile",
+		slog.String("bucket", bucketName),
+		slog.String("key", keyName),
+	)
+
+	output, err := svc.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(keyName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return io.ReadAll(output.Body)
+}
+
 func (svc *s3Service) makeS3Client(ctx context.Context) error {
 	cfg, err := awsconfig.LoadDefaultConfig(ctx,
 		awsconfig.WithRegion(svc.ConfigSvc.GetStorageRegion()),
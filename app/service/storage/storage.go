@@ -30,3 +30,12 @@ func (svc *storageService) NewFile(ctx context.Context, folder, filePath, identi
 
 	return r.NewFile(ctx, folder, filePath, identifier)
 }
+
+func (svc *storageService) RetrieveFile(ctx context.Context, folder, identifier string) ([]byte, error) {
+	r, ok := providers[svc.ConfigSvc.GetStorageProvider()]
+	if !ok {
+		return nil, fmt.Errorf("storage provider %s not found", svc.ConfigSvc.GetStorageProvider())
+	}
+
+	return r.RetrieveFile(ctx, folder, identifier)
+}
@@ -6,4 +6,5 @@ import (
 
 type IService interface {
 	NewFile(ctx context.Context, folder, filePath, identifier string) (string, error)
+	RetrieveFile(ctx context.Context, folder, identifier string) ([]byte, error)
 }
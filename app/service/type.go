@@ -1,6 +1,82 @@
 package service
 
+// Availability denotes whether a video can still be viewed (and extracted) on Youtube
+type Availability string
+
+const (
+	AvailabilityPublic        Availability = "public"
+	AvailabilityPrivate       Availability = "private"
+	AvailabilityDeleted       Availability = "deleted"
+	AvailabilityAgeRestricted Availability = "age_restricted"
+	AvailabilityMembersOnly   Availability = "members_only"
+)
+
+// IsAvailable indicates that the video can be processed by the pipeline
+func (a Availability) IsAvailable() bool {
+	return a == "" || a == AvailabilityPublic
+}
+
+// IsGone indicates that Youtube no longer reports the video statistics
+func (a Availability) IsGone() bool {
+	return a == AvailabilityPrivate || a == AvailabilityDeleted
+}
+
+// ErrorClass denotes why a video could not be extracted
+type ErrorClass string
+
 const (
-	InvalidURL  = "https://www.isitdownrightnow.com"
-	AcceptedURL = "https://httpstatuses.com/202"
+	ErrorClassBotCheck          ErrorClass = "bot_check"
+	ErrorClassGeoBlocked        ErrorClass = "geo_blocked"
+	ErrorClassPrivate           ErrorClass = "private"
+	ErrorClassRemoved           ErrorClass = "removed"
+	ErrorClassNetwork           ErrorClass = "network"
+	ErrorClassFormatUnavailable ErrorClass = "format_unavailable"
+	ErrorClassDiskFull          ErrorClass = "disk_full"
+	ErrorClassUnknown           ErrorClass = "unknown"
 )
+
+// RetryPolicy denotes when a failed extraction is re-attempted
+type RetryPolicy string
+
+const (
+	RetryPolicyNever              RetryPolicy = "never"
+	RetryPolicyBackoff            RetryPolicy = "backoff"
+	RetryPolicyAfterCookieRefresh RetryPolicy = "after_cookie_refresh"
+)
+
+// ErrorClasses lists all the error classes
+var ErrorClasses = []ErrorClass{
+	ErrorClassBotCheck,
+	ErrorClassGeoBlocked,
+	ErrorClassPrivate,
+	ErrorClassRemoved,
+	ErrorClassNetwork,
+	ErrorClassFormatUnavailable,
+	ErrorClassDiskFull,
+	ErrorClassUnknown,
+}
+
+// RetryPolicy returns the retry policy of the error class.
+// Errors that retrying cannot fix are never retried.
+func (c ErrorClass) RetryPolicy() RetryPolicy {
+	switch c {
+	case ErrorClassBotCheck:
+		return RetryPolicyAfterCookieRefresh
+	case ErrorClassGeoBlocked, ErrorClassPrivate, ErrorClassRemoved, ErrorClassFormatUnavailable:
+		return RetryPolicyNever
+	default:
+		return RetryPolicyBackoff
+	}
+}
+
+// ErrorClassesByRetryPolicy returns the error classes that are re-attempted according to the policy
+func ErrorClassesByRetryPolicy(policy RetryPolicy) []ErrorClass {
+	classes := []ErrorClass{}
+	for _, class := range ErrorClasses {
+		if class.RetryPolicy() == policy {
+			classes = append(classes, class)
+		}
+	}
+
+	return classes
+}
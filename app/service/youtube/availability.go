@@ -0,0 +1,60 @@
+package youtube
+
+import (
+	"strings"
+
+	"github.com/khaledhikmat/yt-extractor/service"
+)
+
+// yt-dlp error output fragments that denote an unavailable video.
+// The first matching fragment wins so more specific ones must come first.
+var availabilityFragments = []struct {
+	fragment     string
+	availability service.Availability
+}{
+	{"members-only", service.AvailabilityMembersOnly},
+	{"join this channel to get access", service.AvailabilityMembersOnly},
+	{"sign in to confirm your age", service.AvailabilityAgeRestricted},
+	{"age-restricted", service.AvailabilityAgeRestricted},
+	{"private video", service.AvailabilityPrivate},
+	{"video has been removed", service.AvailabilityDeleted},
+	{"no longer available", service.AvailabilityDeleted},
+	{"account associated with this video has been terminated", service.AvailabilityDeleted},
+	{"video unavailable", service.AvailabilityDeleted},
+}
+
+// classifyAvailability inspects yt-dlp error output and returns the video availability.
+// An empty availability is returned if the error does not denote an unavailable video.
+func classifyAvailability(output string) service.Availability {
+	output = strings.ToLower(output)
+	for _, f := range availabilityFragments {
+		if strings.Contains(output, f.fragment) {
+			return f.availability
+		}
+	}
+
+	return ""
+}
+
+// statisticsAvailability derives the availability from the video statistics response
+func statisticsAvailability(privacyStatus, ytRating string) service.Availability {
+	if privacyStatus == "private" {
+		return service.AvailabilityPrivate
+	}
+
+	if ytRating == "ytAgeRestricted" {
+		return service.AvailabilityAgeRestricted
+	}
+
+	return service.AvailabilityPublic
+}
+
+// missingAvailability derives the availability of a playlist video that the statistics response omitted.
+// Youtube keeps such videos in the uploads playlist with a placeholder title.
+func missingAvailability(title string) service.Availability {
+	if title == "Private video" {
+		return service.AvailabilityPrivate
+	}
+
+	return service.AvailabilityDeleted
+}
@@ -0,0 +1,69 @@
+package youtube
+
+import (
+	"testing"
+
+	"github.com/khaledhikmat/yt-extractor/service"
+)
+
+func TestClassifyAvailability(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected service.Availability
+	}{
+		{"ERROR: [youtube] abc: Join this channel to get access to members-only content like this video", service.AvailabilityMembersOnly},
+		{"ERROR: [youtube] abc: Sign in to confirm your age. This video may be inappropriate for some users.", service.AvailabilityAgeRestricted},
+		{"ERROR: [youtube] abc: Private video. Sign in if you've been granted access to this video", service.AvailabilityPrivate},
+		{"ERROR: [youtube] abc: This video has been removed by the uploader", service.AvailabilityDeleted},
+		{"ERROR: [youtube] abc: This video is no longer available because the YouTube account associated with this video has been terminated.", service.AvailabilityDeleted},
+		{"ERROR: [youtube] abc: Video unavailable", service.AvailabilityDeleted},
+		{"ERROR: [youtube] abc: HTTP Error 503: Service Unavailable", ""},
+		{"ERROR: [youtube] abc: Sign in to confirm you’re not a bot", ""},
+	}
+
+	for _, test := range tests {
+		availability := classifyAvailability(test.output)
+		if availability != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.output, test.expected, availability)
+		}
+	}
+}
+
+func TestStatisticsAvailability(t *testing.T) {
+	tests := []struct {
+		privacyStatus string
+		ytRating      string
+		expected      service.Availability
+	}{
+		{"public", "", service.AvailabilityPublic},
+		{"unlisted", "", service.AvailabilityPublic},
+		{"private", "", service.AvailabilityPrivate},
+		{"private", "ytAgeRestricted", service.AvailabilityPrivate},
+		{"public", "ytAgeRestricted", service.AvailabilityAgeRestricted},
+	}
+
+	for _, test := range tests {
+		availability := statisticsAvailability(test.privacyStatus, test.ytRating)
+		if availability != test.expected {
+			t.Errorf("%s/%s: expected %s, got %s", test.privacyStatus, test.ytRating, test.expected, availability)
+		}
+	}
+}
+
+func TestMissingAvailability(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected service.Availability
+	}{
+		{"Private video", service.AvailabilityPrivate},
+		{"Deleted video", service.AvailabilityDeleted},
+		{"", service.AvailabilityDeleted},
+	}
+
+	for _, test := range tests {
+		availability := missingAvailability(test.title)
+		if availability != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.title, test.expected, availability)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package youtube
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoCaptions is returned when a video does not have an acceptable caption track
+var ErrNoCaptions = errors.New("no acceptable captions")
+
+var (
+	captionTimestampRegex = regexp.MustCompile(`^(?:(\d+):)?(\d{2}):(\d{2})[.,](\d{3})$`)
+	captionTagRegex       = regexp.MustCompile(`<[^>]*>`)
+)
+
+func (svc *youtubService) ExtractCaptions(ctx context.Context, videoID, videoURL string) ([]Caption, error) {
+	folder := svc.ConfigSvc.GetLocalTranscriptionFolder()
+	err := os.MkdirAll(folder, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	languages := svc.ConfigSvc.GetCaptionLanguages()
+	args := []string{"--skip-download", "--write-subs", "--sub-langs", strings.Join(languages, ","), "--sub-format", "vtt"}
+	if svc.ConfigSvc.IsAutoCaptionsAllowed() {
+		args = append(args, "--write-auto-subs")
+	}
+	args = append(cookiesArgs(svc.cookiesFile()), args...)
+	args = append(args, "-o", filepath.Join(folder, fmt.Sprintf("%s.%%(ext)s", videoID)), videoURL)
+
+	// yt-dlp writes one file per language i.e. <videoID>.<lang>.vtt
+	defer func() {
+		files, _ := filepath.Glob(filepath.Join(folder, fmt.Sprintf("%s.*.vtt", videoID)))
+		for _, file := range files {
+			// Ignore errors because they may have been deleted already
+			_ = os.Remove(file)
+		}
+	}()
+
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, ytdlpError(videoURL, stderr.String(), err)
+	}
+
+	// Pick the first language (in order of preference) that has captions
+	for _, language := range languages {
+		file, err := os.Open(filepath.Join(folder, fmt.Sprintf("%s.%s.vtt", videoID, language)))
+		if err != nil {
+			continue
+		}
+
+		captions, err := ParseCaptions(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(captions) > 0 {
+			return captions, nil
+		}
+	}
+
+	return nil, ErrNoCaptions
+}
+
+// ParseCaptions parses WebVTT (or SRT) captions into segments.
+// Styling tags are removed and lines repeated from the previous cue (which is how
+// Youtube auto-generated captions roll) are dropped.
+func ParseCaptions(r io.Reader) ([]Caption, error) {
+	captions := []Caption{}
+
+	var current *Caption
+	lastLine := ""
+	flush := func() {
+		if current != nil && current.Text != "" {
+			captions = append(captions, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Auto-generated cues contain whitespace-only lines so only truly empty lines separate cues
+		if strings.TrimRight(scanner.Text(), "\r") == "" {
+			flush()
+			continue
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if strings.Contains(line, "-->") {
+			flush()
+			start, end, err := parseCueTimings(line)
+			if err != nil {
+				return nil, err
+			}
+			current = &Caption{
+				Start: start,
+				End:   end,
+			}
+			continue
+		}
+
+		// Headers, notes and cue identifiers are outside of cues
+		if current == nil {
+			continue
+		}
+
+		text := strings.TrimSpace(html.UnescapeString(captionTagRegex.ReplaceAllString(line, "")))
+		if text == "" || text == lastLine {
+			continue
+		}
+		lastLine = text
+
+		if current.Text != "" {
+			current.Text += " "
+		}
+		current.Text += text
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading captions: %v", err)
+	}
+
+	return captions, nil
+}
+
+// FormatCaptionsText joins the caption segments into plain text
+func FormatCaptionsText(captions []Caption) string {
+	texts := make([]string, 0, len(captions))
+	for _, caption := range captions {
+		texts = append(texts, caption.Text)
+	}
+
+	return strings.Join(texts, " ")
+}
+
+// FormatCaptionsSRT renders the caption segments in SRT format
+func FormatCaptionsSRT(captions []Caption) string {
+	var sb strings.Builder
+	for i, caption := range captions {
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, formatSRTTimestamp(caption.Start), formatSRTTimestamp(caption.End), caption.Text)
+	}
+
+	return sb.String()
+}
+
+// parseCueTimings parses a cue timings line i.e. `00:00:01.000 --> 00:00:04.000 align:start position:0%`
+func parseCueTimings(line string) (time.Duration, time.Duration, error) {
+	parts := strings.Fields(line)
+	if len(parts) < 3 || parts[1] != "-->" {
+		return 0, 0, fmt.Errorf("invalid cue timings %s", line)
+	}
+
+	start, err := parseCaptionTimestamp(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := parseCaptionTimestamp(parts[2])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return start, end, nil
+}
+
+func parseCaptionTimestamp(timestamp string) (time.Duration, error) {
+	match := captionTimestampRegex.FindStringSubmatch(timestamp)
+	if match == nil {
+		return 0, fmt.Errorf("invalid caption timestamp %s", timestamp)
+	}
+
+	hours := 0
+	if match[1] != "" {
+		hours, _ = strconv.Atoi(match[1])
+	}
+	minutes, _ := strconv.Atoi(match[2])
+	seconds, _ := strconv.Atoi(match[3])
+	millis, _ := strconv.Atoi(match[4])
+
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second +
+		time.Duration(millis)*time.Millisecond, nil
+}
+
+func formatSRTTimestamp(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}
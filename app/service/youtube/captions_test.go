@@ -0,0 +1,61 @@
+package youtube
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const autoGeneratedVTT = `WEBVTT
+Kind: captions
+Language: en
+
+00:00:00.080 --> 00:00:02.310 align:start position:0%
+ 
+peace<00:00:00.480><c> be</c><00:00:00.880><c> upon</c><00:00:01.120><c> you</c>
+
+00:00:02.310 --> 00:00:02.320 align:start position:0%
+peace be upon you
+ 
+
+00:00:02.320 --> 00:00:05.150 align:start position:0%
+peace be upon you
+today<00:00:03.000><c> we</c><00:00:03.400><c> talk</c>
+`
+
+func TestParseCaptions(t *testing.T) {
+	captions, err := ParseCaptions(strings.NewReader(autoGeneratedVTT))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(captions) != 2 {
+		t.Fatalf("expected 2 captions, got %d: %v", len(captions), captions)
+	}
+
+	if captions[0].Text != "peace be upon you" || captions[0].Start != 80*time.Millisecond {
+		t.Errorf("unexpected first caption %v", captions[0])
+	}
+
+	if captions[1].Text != "today we talk" || captions[1].End != 5150*time.Millisecond {
+		t.Errorf("unexpected second caption %v", captions[1])
+	}
+
+	if text := FormatCaptionsText(captions); text != "peace be upon you today we talk" {
+		t.Errorf("unexpected text %s", text)
+	}
+
+	srt := FormatCaptionsSRT(captions)
+	if !strings.HasPrefix(srt, "1\n00:00:00,080 --> 00:00:02,310\npeace be upon you\n\n2\n") {
+		t.Errorf("unexpected srt %s", srt)
+	}
+
+	// The SRT output must parse back into the same captions
+	reparsed, err := ParseCaptions(strings.NewReader(srt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reparsed) != len(captions) || reparsed[1] != captions[1] {
+		t.Errorf("unexpected reparsed captions %v", reparsed)
+	}
+}
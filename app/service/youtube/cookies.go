@@ -0,0 +1,127 @@
+package youtube
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BotCheckError denotes that Youtube refused to serve a video until the requester
+// signs in to confirm it is not a bot. It usually means that the cookies must be rotated.
+type BotCheckError struct {
+	VideoURL string
+}
+
+func (e *BotCheckError) Error() string {
+	return fmt.Sprintf("youtube bot check triggered for %s: the cookies must be rotated", e.VideoURL)
+}
+
+// isBotCheck inspects yt-dlp error output for Youtube's "Sign in to confirm you're not a bot"
+func isBotCheck(output string) bool {
+	output = strings.ToLower(output)
+	return strings.Contains(output, "sign in to confirm") && strings.Contains(output, "not a bot")
+}
+
+// cookiesArgs returns the yt-dlp arguments that pass the cookies file if there is one
+func cookiesArgs(cookiesFile string) []string {
+	if cookiesFile == "" {
+		return []string{}
+	}
+
+	return []string{"--cookies", cookiesFile}
+}
+
+// cookiesFile returns the cookies file to pass to yt-dlp or empty if there are no cookies
+func (svc *youtubService) cookiesFile() string {
+	file := svc.ConfigSvc.GetCookiesFile()
+	if _, err := os.Stat(file); err != nil {
+		return ""
+	}
+
+	return file
+}
+
+func (svc *youtubService) SetCookies(content []byte) error {
+	file := svc.ConfigSvc.GetCookiesFile()
+	err := os.MkdirAll(filepath.Dir(file), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	// Cookies are credentials so they must only be readable by the owner
+	return os.WriteFile(file, content, 0600)
+}
+
+// loginCookies are the Youtube session cookies that only a signed-in account has
+var loginCookies = []string{"SAPISID", "__Secure-3PAPISID"}
+
+// hasLoginCookies makes sure that the cookies (i.e. the Netscape cookies.txt format) carry an
+// unexpired Youtube session. Cookies exported from a signed-out browser still fetch public videos.
+func hasLoginCookies(content []byte, now time.Time) error {
+	for _, line := range strings.Split(string(content), "\n") {
+		// The HttpOnly cookies are prefixed and the other # lines are comments
+		line = strings.TrimPrefix(strings.TrimSpace(line), "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 || !strings.HasSuffix(fields[0], "youtube.com") || !slices.Contains(loginCookies, fields[5]) {
+			continue
+		}
+
+		// Session cookies do not expire
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil || (expiry != 0 && time.Unix(expiry, 0).Before(now)) {
+			continue
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("cookies do not have a signed-in Youtube session (i.e. %s)", strings.Join(loginCookies, " or "))
+}
+
+// ValidateCookies makes sure that the cookies are signed in: they must carry a Youtube session and
+// fetch the test URL, which defaults to a feed (i.e. the watch history) that requires signing in
+func (svc *youtubService) ValidateCookies(ctx context.Context, content []byte) error {
+	err := hasLoginCookies(content, time.Now())
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "cookies-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create cookies file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write cookies file: %v", err)
+	}
+
+	// Fetch the first entry of the test URL without downloading it
+	testURL := svc.ConfigSvc.GetCookiesTestURL()
+	cmd := exec.CommandContext(ctx, "yt-dlp", "--cookies", file.Name(), "--flat-playlist", "--playlist-items", "1", "--skip-download", "--simulate", testURL)
+
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+
+	err = cmd.Run()
+	if err != nil {
+		return ytdlpError(testURL, stderr.String(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package youtube
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHasLoginCookies(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{
+			name:     "signed in",
+			content:  "# Netscape HTTP Cookie File\n.youtube.com\tTRUE\t/\tTRUE\t1893456000\tSAPISID\tvalue\n",
+			expected: true,
+		},
+		{
+			name:     "http only session",
+			content:  "#HttpOnly_.youtube.com\tTRUE\t/\tTRUE\t0\t__Secure-3PAPISID\tvalue\n",
+			expected: true,
+		},
+		{
+			name:     "signed out",
+			content:  ".youtube.com\tTRUE\t/\tTRUE\t1893456000\tVISITOR_INFO1_LIVE\tvalue\n",
+			expected: false,
+		},
+		{
+			name:     "expired",
+			content:  ".youtube.com\tTRUE\t/\tTRUE\t1577836800\tSAPISID\tvalue\n",
+			expected: false,
+		},
+		{
+			name:     "another domain",
+			content:  ".google.com\tTRUE\t/\tTRUE\t1893456000\tSAPISID\tvalue\n",
+			expected: false,
+		},
+		{
+			name:     "not a cookies file",
+			content:  "SAPISID=value",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		err := hasLoginCookies([]byte(test.content), now)
+		if (err == nil) != test.expected {
+			t.Errorf("%s: expected signed in %t, got %v", test.name, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/khaledhikmat/yt-extractor/service"
+)
+
+// yt-dlp error output fragments that denote an error class.
+// The first matching fragment wins so more specific ones must come first.
+var errorClassFragments = []struct {
+	fragment string
+	class    service.ErrorClass
+}{
+	{"no space left on device", service.ErrorClassDiskFull},
+	{"not made this video available in your country", service.ErrorClassGeoBlocked},
+	{"not available in your country", service.ErrorClassGeoBlocked},
+	{"geo restriction", service.ErrorClassGeoBlocked},
+	{"requested format is not available", service.ErrorClassFormatUnavailable},
+	{"no video formats found", service.ErrorClassFormatUnavailable},
+	{"unable to download webpage", service.ErrorClassNetwork},
+	{"unable to download video data", service.ErrorClassNetwork},
+	{"connection reset", service.ErrorClassNetwork},
+	{"connection refused", service.ErrorClassNetwork},
+	{"timed out", service.ErrorClassNetwork},
+	{"temporary failure in name resolution", service.ErrorClassNetwork},
+	{"network is unreachable", service.ErrorClassNetwork},
+	{"http error 5", service.ErrorClassNetwork},
+}
+
+// YTDLPError is a yt-dlp failure along with its error class
+type YTDLPError struct {
+	Class service.ErrorClass
+	Err   error
+}
+
+func (e *YTDLPError) Error() string {
+	return fmt.Sprintf("error executing yt-dlp (%s): %v", e.Class, e.Err)
+}
+
+func (e *YTDLPError) Unwrap() error {
+	return e.Err
+}
+
+// ytdlpError classifies a yt-dlp failure from its error output.
+// It returns a bot check error if the error output denotes one.
+func ytdlpError(videoURL, output string, err error) error {
+	if isBotCheck(output) {
+		return &BotCheckError{VideoURL: videoURL}
+	}
+
+	return &YTDLPError{
+		Class: classifyError(output),
+		Err:   err,
+	}
+}
+
+// classifyError inspects yt-dlp error output and returns the error class
+func classifyError(output string) service.ErrorClass {
+	if isBotCheck(output) {
+		return service.ErrorClassBotCheck
+	}
+
+	// Videos that can no longer be viewed
+	switch classifyAvailability(output) {
+	case service.AvailabilityDeleted:
+		return service.ErrorClassRemoved
+	case service.AvailabilityPrivate, service.AvailabilityMembersOnly, service.AvailabilityAgeRestricted:
+		return service.ErrorClassPrivate
+	}
+
+	output = strings.ToLower(output)
+	for _, f := range errorClassFragments {
+		if strings.Contains(output, f.fragment) {
+			return f.class
+		}
+	}
+
+	return service.ErrorClassUnknown
+}
+
+// errorClassOf returns the error class of an extraction error
+func errorClassOf(err error) service.ErrorClass {
+	var botCheckErr *BotCheckError
+	if errors.As(err, &botCheckErr) {
+		return service.ErrorClassBotCheck
+	}
+
+	var ytdlpErr *YTDLPError
+	if errors.As(err, &ytdlpErr) {
+		return ytdlpErr.Class
+	}
+
+	if errors.Is(err, syscall.ENOSPC) {
+		return service.ErrorClassDiskFull
+	}
+
+	return service.ErrorClassUnknown
+}
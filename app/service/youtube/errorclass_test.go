@@ -0,0 +1,48 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/khaledhikmat/yt-extractor/service"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected service.ErrorClass
+	}{
+		{"ERROR: [youtube] abc: Sign in to confirm you’re not a bot. Use --cookies-from-browser", service.ErrorClassBotCheck},
+		{"ERROR: [youtube] abc: The uploader has not made this video available in your country", service.ErrorClassGeoBlocked},
+		{"ERROR: [youtube] abc: Private video. Sign in if you've been granted access to this video", service.ErrorClassPrivate},
+		{"ERROR: [youtube] abc: This video has been removed by the uploader", service.ErrorClassRemoved},
+		{"ERROR: [youtube] abc: Unable to download webpage: <urlopen error [Errno -3] Temporary failure in name resolution>", service.ErrorClassNetwork},
+		{"ERROR: [youtube] abc: Requested format is not available. Use --list-formats for a list of available formats", service.ErrorClassFormatUnavailable},
+		{"ERROR: unable to write data: [Errno 28] No space left on device", service.ErrorClassDiskFull},
+		{"ERROR: something unexpected", service.ErrorClassUnknown},
+	}
+
+	for _, test := range tests {
+		class := classifyError(test.output)
+		if class != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.output, test.expected, class)
+		}
+	}
+}
+
+func TestErrorClassOf(t *testing.T) {
+	err := fmt.Errorf("extracting: %w", ytdlpError("url", "HTTP Error 503: Service Unavailable", errors.New("exit status 1")))
+	if class := errorClassOf(err); class != service.ErrorClassNetwork {
+		t.Errorf("expected %s, got %s", service.ErrorClassNetwork, class)
+	}
+
+	err = ytdlpError("url", "Sign in to confirm you're not a bot", errors.New("exit status 1"))
+	if class := errorClassOf(err); class != service.ErrorClassBotCheck {
+		t.Errorf("expected %s, got %s", service.ErrorClassBotCheck, class)
+	}
+
+	if class := errorClassOf(errors.New("boom")); class != service.ErrorClassUnknown {
+		t.Errorf("expected %s, got %s", service.ErrorClassUnknown, class)
+	}
+}
@@ -0,0 +1,104 @@
+package youtube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// selectFormats picks the best video and audio formats that satisfy the policy
+// and returns their IDs in yt-dlp format selector notation (i.e. 137+140).
+// The audio-only channels do not select formats (see ExtractionModeAudio).
+func selectFormats(formats []YTDLPFormat, policy FormatPolicy) (string, error) {
+	audios := []YTDLPFormat{}
+	videos := []YTDLPFormat{}
+	for _, format := range formats {
+		hasVideo := format.VCodec != "" && format.VCodec != "none"
+		hasAudio := format.ACodec != "" && format.ACodec != "none"
+
+		if hasAudio && !hasVideo {
+			audios = append(audios, format)
+		}
+
+		if hasVideo && !hasAudio && (policy.MaxHeight <= 0 || format.Height <= policy.MaxHeight) {
+			videos = append(videos, format)
+		}
+	}
+
+	if len(audios) == 0 {
+		return "", fmt.Errorf("no audio format available")
+	}
+
+	// Prefer m4a audio as it merges into mp4 without re-encoding, then the highest bitrate
+	sort.SliceStable(audios, func(i, j int) bool {
+		if (audios[i].Ext == "m4a") != (audios[j].Ext == "m4a") {
+			return audios[i].Ext == "m4a"
+		}
+		return bitrate(audios[i]) > bitrate(audios[j])
+	})
+
+	// Prefer the preferred codec, then the highest resolution, then the highest bitrate
+	sort.SliceStable(videos, func(i, j int) bool {
+		iPreferred := isPreferredCodec(videos[i], policy.PreferredCodec)
+		jPreferred := isPreferredCodec(videos[j], policy.PreferredCodec)
+		if iPreferred != jPreferred {
+			return iPreferred
+		}
+		if videos[i].Height != videos[j].Height {
+			return videos[i].Height > videos[j].Height
+		}
+		return bitrate(videos[i]) > bitrate(videos[j])
+	})
+
+	// Pick the first video format that fits the maximum file size along with the best audio.
+	// Formats of unknown size are assumed to fit.
+	audio := audios[0]
+	for _, video := range videos {
+		if policy.MaxFileSize > 0 && fileSize(video)+fileSize(audio) > policy.MaxFileSize {
+			continue
+		}
+
+		return video.FormatID + "+" + audio.FormatID, nil
+	}
+
+	return "", fmt.Errorf("no video format satisfies the policy")
+}
+
+// codecPrefixes maps the codec families to the codec prefixes that yt-dlp reports (i.e. vp09.00.40.08)
+var codecPrefixes = map[string]string{
+	"h264": "avc1",
+	"avc":  "avc1",
+	"vp9":  "vp09",
+	"av1":  "av01",
+}
+
+// isPreferredCodec tells whether the format is of the codec family (i.e. vp9) or the yt-dlp codec prefix (i.e. vp09)
+func isPreferredCodec(format YTDLPFormat, codec string) bool {
+	if codec == "" {
+		return false
+	}
+
+	codec = strings.ToLower(codec)
+	vcodec := strings.ToLower(format.VCodec)
+	if prefix, ok := codecPrefixes[codec]; ok && strings.HasPrefix(vcodec, prefix) {
+		return true
+	}
+
+	return strings.HasPrefix(vcodec, codec)
+}
+
+func bitrate(format YTDLPFormat) float64 {
+	if format.ABR > 0 && format.VCodec == "none" {
+		return format.ABR
+	}
+
+	return format.TBR
+}
+
+func fileSize(format YTDLPFormat) int64 {
+	if format.FileSize > 0 {
+		return format.FileSize
+	}
+
+	return format.FileSizeApprox
+}
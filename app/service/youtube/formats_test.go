@@ -0,0 +1,79 @@
+package youtube
+
+import "testing"
+
+var testFormats = []YTDLPFormat{
+	{FormatID: "sb0", Ext: "mhtml", VCodec: "none", ACodec: "none"},
+	{FormatID: "139", Ext: "m4a", VCodec: "none", ACodec: "mp4a.40.5", ABR: 48, FileSize: 1_000_000},
+	{FormatID: "140", Ext: "m4a", VCodec: "none", ACodec: "mp4a.40.2", ABR: 129, FileSize: 3_000_000},
+	{FormatID: "251", Ext: "webm", VCodec: "none", ACodec: "opus", ABR: 140, FileSize: 3_200_000},
+	{FormatID: "18", Ext: "mp4", VCodec: "avc1.42001E", ACodec: "mp4a.40.2", Height: 360, TBR: 500},
+	{FormatID: "136", Ext: "mp4", VCodec: "avc1.4d401f", ACodec: "none", Height: 720, TBR: 1200, FileSize: 40_000_000},
+	{FormatID: "137", Ext: "mp4", VCodec: "avc1.640028", ACodec: "none", Height: 1080, TBR: 2500, FileSize: 90_000_000},
+	{FormatID: "399", Ext: "mp4", VCodec: "av01.0.08M.08", ACodec: "none", Height: 1080, TBR: 1800, FileSize: 60_000_000},
+	{FormatID: "401", Ext: "mp4", VCodec: "av01.0.12M.08", ACodec: "none", Height: 2160, TBR: 9000},
+	{FormatID: "248", Ext: "webm", VCodec: "vp09.00.40.08", ACodec: "none", Height: 1080, TBR: 1500, FileSize: 50_000_000},
+}
+
+func TestIsPreferredCodec(t *testing.T) {
+	tests := []struct {
+		vcodec   string
+		codec    string
+		expected bool
+	}{
+		{"vp09.00.40.08", "vp9", true},
+		{"vp9", "vp9", true},
+		{"avc1.640028", "h264", true},
+		{"avc1.640028", "avc1", true},
+		{"av01.0.08M.08", "av1", true},
+		{"av01.0.08M.08", "vp9", false},
+		{"avc1.640028", "", false},
+	}
+
+	for _, test := range tests {
+		preferred := isPreferredCodec(YTDLPFormat{VCodec: test.vcodec}, test.codec)
+		if preferred != test.expected {
+			t.Errorf("%s as %s: expected %t, got %t", test.vcodec, test.codec, test.expected, preferred)
+		}
+	}
+}
+
+func TestSelectFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   FormatPolicy
+		expected string
+	}{
+		{"preferred codec", FormatPolicy{MaxHeight: 1080, PreferredCodec: "avc1"}, "137+140"},
+		{"other codec", FormatPolicy{MaxHeight: 1080, PreferredCodec: "av01"}, "399+140"},
+		{"no maximum height", FormatPolicy{PreferredCodec: "av01"}, "401+140"},
+		{"codec family", FormatPolicy{MaxHeight: 1080, PreferredCodec: "vp9"}, "248+140"},
+		{"h264 family", FormatPolicy{MaxHeight: 1080, PreferredCodec: "h264"}, "137+140"},
+		{"av1 family", FormatPolicy{MaxHeight: 1080, PreferredCodec: "AV1"}, "399+140"},
+		{"vp9 prefix", FormatPolicy{MaxHeight: 1080, PreferredCodec: "vp09"}, "248+140"},
+		{"maximum file size", FormatPolicy{MaxHeight: 1080, PreferredCodec: "avc1", MaxFileSize: 50_000_000}, "136+140"},
+	}
+
+	for _, test := range tests {
+		formatIDs, err := selectFormats(testFormats, test.policy)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if formatIDs != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, formatIDs)
+		}
+	}
+
+	// Nothing fits
+	_, err := selectFormats(testFormats, FormatPolicy{MaxHeight: 1080, MaxFileSize: 1})
+	if err == nil {
+		t.Error("expected an error when no video format fits the maximum file size")
+	}
+
+	// Empty formats must not panic
+	_, err = selectFormats(nil, FormatPolicy{})
+	if err == nil {
+		t.Error("expected an error when there are no formats")
+	}
+}
@@ -0,0 +1,60 @@
+package youtube
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// hostLimiter spaces out the requests made to the same host
+type hostLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+// newHostLimiter allows up to rate requests per minute per host. A zero rate disables the limiter.
+func newHostLimiter(rate int) *hostLimiter {
+	interval := time.Duration(0)
+	if rate > 0 {
+		interval = time.Minute / time.Duration(rate)
+	}
+
+	return &hostLimiter{
+		interval: interval,
+		next:     map[string]time.Time{},
+	}
+}
+
+// wait blocks until a request to the URL host is allowed or the context is cancelled
+func (l *hostLimiter) wait(ctx context.Context, rawURL string) error {
+	if l.interval == 0 {
+		return ctx.Err()
+	}
+
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	// Reserve the next slot for this host
+	l.mutex.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mutex.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,101 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter(t *testing.T) {
+	// 1200 requests per minute space out the requests to the same host by 50ms
+	limiter := newHostLimiter(1200)
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 3 {
+		err := limiter.wait(ctx, "https://www.youtube.com/watch?v=a")
+		if err != nil {
+			t.Fatalf("wait produced %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected 3 requests to the same host to take at least 100ms, took %s", elapsed)
+	}
+
+	// Another host has its own slots
+	start = time.Now()
+	err := limiter.wait(ctx, "https://rr1.googlevideo.com/videoplayback")
+	if err != nil {
+		t.Fatalf("wait produced %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("expected the first request to another host not to wait, took %s", elapsed)
+	}
+}
+
+func TestHostLimiterConcurrent(t *testing.T) {
+	limiter := newHostLimiter(1200)
+	ctx := context.Background()
+
+	// The concurrent requests to the same host reserve distinct slots so they are spaced out
+	mutex := sync.Mutex{}
+	allowed := []time.Time{}
+	wg := sync.WaitGroup{}
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := limiter.wait(ctx, "https://www.youtube.com/watch?v=b")
+			if err != nil {
+				t.Errorf("wait produced %v", err)
+			}
+
+			mutex.Lock()
+			allowed = append(allowed, time.Now())
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(allowed, func(a, b time.Time) int {
+		return a.Compare(b)
+	})
+	for i := 1; i < len(allowed); i++ {
+		// Timers may fire a little early or late so the spacing is checked loosely
+		if gap := allowed[i].Sub(allowed[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("expected the requests to be spaced out by 50ms, got %s", gap)
+		}
+	}
+}
+
+func TestHostLimiterDisabledAndCancelled(t *testing.T) {
+	// A zero rate does not wait
+	limiter := newHostLimiter(0)
+	start := time.Now()
+	for range 10 {
+		err := limiter.wait(context.Background(), "https://www.youtube.com/watch?v=c")
+		if err != nil {
+			t.Fatalf("wait produced %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("expected a disabled limiter not to wait, took %s", elapsed)
+	}
+
+	// A wait for a later slot ends with its context
+	limiter = newHostLimiter(1)
+	err := limiter.wait(context.Background(), "https://www.youtube.com/watch?v=d")
+	if err != nil {
+		t.Fatalf("wait produced %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = limiter.wait(ctx, "https://www.youtube.com/watch?v=d")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+}
@@ -1,16 +1,30 @@
 package youtube
 
+import (
+	"time"
+
+	"github.com/khaledhikmat/yt-extractor/service"
+)
+
 type Video struct {
-	ID             string `json:"id"`
-	Title          string `json:"title"`
-	PublishedAt    string `json:"publishedAt"`
-	URL            string `json:"url"`
-	Views          string `json:"views"`
-	Comments       string `json:"comments"`
-	Likes          string `json:"likes"`
-	Duration       string `json:"duration"`
-	Short          bool   `json:"short"`
-	LocalReference string `json:"localReference"`
+	ID                   string               `json:"id"`
+	Title                string               `json:"title"`
+	PublishedAt          string               `json:"publishedAt"`
+	URL                  string               `json:"url"`
+	Views                string               `json:"views"`
+	Comments             string               `json:"comments"`
+	Likes                string               `json:"likes"`
+	Duration             string               `json:"duration"`
+	Description          string               `json:"description"`
+	Tags                 []string             `json:"tags"`
+	Thumbnails           map[string]string    `json:"thumbnails"`
+	CategoryID           string               `json:"categoryId"`
+	DefaultAudioLanguage string               `json:"defaultAudioLanguage"`
+	Caption              bool                 `json:"caption"`
+	LiveBroadcastContent string               `json:"liveBroadcastContent"`
+	PrivacyStatus        string               `json:"privacyStatus"`
+	Availability         service.Availability `json:"availability"`
+	LocalReference       string               `json:"localReference"`
 }
 
 type PlaylistItemsResponse struct {
@@ -26,17 +40,106 @@ type PlaylistItemsResponse struct {
 	} `json:"items"`
 }
 
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
 type VideoStatisticsResponse struct {
 	Items []struct {
-		ID             string `json:"id"`
-		URL            string `json:"url"`
+		ID      string `json:"id"`
+		URL     string `json:"url"`
+		Snippet struct {
+			Description          string               `json:"description"`
+			Tags                 []string             `json:"tags"`
+			Thumbnails           map[string]Thumbnail `json:"thumbnails"`
+			CategoryID           string               `json:"categoryId"`
+			DefaultAudioLanguage string               `json:"defaultAudioLanguage"`
+			LiveBroadcastContent string               `json:"liveBroadcastContent"`
+		} `json:"snippet"`
 		ContentDetails struct {
-			Duration string `json:"duration"`
+			Duration      string `json:"duration"`
+			Caption       string `json:"caption"`
+			ContentRating struct {
+				YtRating string `json:"ytRating"`
+			} `json:"contentRating"`
 		} `json:"contentDetails"`
 		Statistics struct {
 			ViewCount    string `json:"viewCount"`
 			CommentCount string `json:"commentCount"`
 			LikeCount    string `json:"likeCount"`
 		} `json:"statistics"`
+		Status struct {
+			PrivacyStatus string `json:"privacyStatus"`
+		} `json:"status"`
 	} `json:"items"`
 }
+
+// ExtractionMode determines what is downloaded from Youtube
+type ExtractionMode string
+
+const (
+	// ExtractionModeVideo downloads the video (mp4)
+	ExtractionModeVideo ExtractionMode = "video"
+	// ExtractionModeAudio downloads the best audio stream only (mp3)
+	ExtractionModeAudio ExtractionMode = "audio"
+)
+
+// ExtractionResult is the outcome of extracting a single video
+type ExtractionResult struct {
+	URL            string
+	LocalReference string
+	FormatIDs      string
+	Availability   service.Availability
+	ErrorClass     service.ErrorClass
+	Err            error
+}
+
+// ExtractionProgress is the download progress of a video being extracted
+type ExtractionProgress struct {
+	VideoID   string    `json:"videoId"`
+	Percent   float64   `json:"percent"`
+	Speed     float64   `json:"speed"` // bytes per second
+	ETA       int64     `json:"eta"`   // seconds
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// YTDLPMetadata is the subset of the yt-dlp JSON output (i.e. yt-dlp -J) that we use
+type YTDLPMetadata struct {
+	ID       string        `json:"id"`
+	Duration float64       `json:"duration"`
+	Width    int           `json:"width"`
+	Height   int           `json:"height"`
+	Formats  []YTDLPFormat `json:"formats"`
+}
+
+// YTDLPFormat is a single downloadable format. Codecs are "none" if the format lacks the stream.
+type YTDLPFormat struct {
+	FormatID       string  `json:"format_id"`
+	Ext            string  `json:"ext"`
+	VCodec         string  `json:"vcodec"`
+	ACodec         string  `json:"acodec"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	FPS            float64 `json:"fps"`
+	TBR            float64 `json:"tbr"`
+	ABR            float64 `json:"abr"`
+	FileSize       int64   `json:"filesize"`
+	FileSizeApprox int64   `json:"filesize_approx"`
+	Protocol       string  `json:"protocol"`
+}
+
+// FormatPolicy drives the selection of the formats to download
+type FormatPolicy struct {
+	MaxHeight      int
+	PreferredCodec string
+	MaxFileSize    int64
+}
+
+// Caption is a single timestamped caption segment
+type Caption struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	Text  string        `json:"text"`
+}
@@ -0,0 +1,129 @@
+package youtube
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressPrefix marks the yt-dlp progress lines so they can be told apart from the rest of the output
+	progressPrefix = "[progress]"
+	// progressTemplate makes yt-dlp report the percent, speed (bytes/sec) and ETA (sec) of the download
+	progressTemplate = "download:" + progressPrefix + " %(progress._percent)s %(progress.speed)s %(progress.eta)s"
+)
+
+// progressTracker keeps the extraction progress of the videos in memory per job
+type progressTracker struct {
+	mutex sync.Mutex
+	jobs  map[int64]map[string]ExtractionProgress
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		jobs: map[int64]map[string]ExtractionProgress{},
+	}
+}
+
+func (t *progressTracker) update(jobID int64, progress ExtractionProgress) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.jobs[jobID]; !ok {
+		t.jobs[jobID] = map[string]ExtractionProgress{}
+	}
+	t.jobs[jobID][progress.VideoID] = progress
+}
+
+func (t *progressTracker) remove(jobID int64, videoID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.jobs[jobID], videoID)
+	if len(t.jobs[jobID]) == 0 {
+		delete(t.jobs, jobID)
+	}
+}
+
+// retrieve returns the progress of the job videos ordered by video ID
+func (t *progressTracker) retrieve(jobID int64) []ExtractionProgress {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	progresses := []ExtractionProgress{}
+	for _, progress := range t.jobs[jobID] {
+		progresses = append(progresses, progress)
+	}
+	slices.SortFunc(progresses, func(a, b ExtractionProgress) int {
+		return strings.Compare(a.VideoID, b.VideoID)
+	})
+
+	return progresses
+}
+
+// progressWriter reports the yt-dlp progress lines and forwards all other lines to the output
+type progressWriter struct {
+	videoID    string
+	output     io.Writer
+	onProgress func(ExtractionProgress)
+	pending    []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := string(w.pending[:i])
+		w.pending = w.pending[i+1:]
+
+		progress, ok := parseProgress(line)
+		if !ok {
+			_, _ = io.WriteString(w.output, line+"\n")
+			continue
+		}
+
+		progress.VideoID = w.videoID
+		progress.UpdatedAt = time.Now()
+		w.onProgress(progress)
+	}
+
+	return len(p), nil
+}
+
+// parseProgress parses a progress line produced by yt-dlp with the progress template.
+// Values that yt-dlp does not know yet (i.e. NA) are left as zero.
+func parseProgress(line string) (ExtractionProgress, bool) {
+	progress := ExtractionProgress{}
+
+	line = strings.TrimSpace(strings.TrimRight(line, "\r"))
+	if !strings.HasPrefix(line, progressPrefix) {
+		return progress, false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, progressPrefix))
+	if len(fields) != 3 {
+		return progress, false
+	}
+
+	if percent, err := strconv.ParseFloat(fields[0], 64); err == nil {
+		progress.Percent = percent
+	}
+
+	if speed, err := strconv.ParseFloat(fields[1], 64); err == nil {
+		progress.Speed = speed
+	}
+
+	if eta, err := strconv.ParseFloat(fields[2], 64); err == nil {
+		progress.ETA = int64(eta)
+	}
+
+	return progress, true
+}
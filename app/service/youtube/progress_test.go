@@ -0,0 +1,63 @@
+package youtube
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		line     string
+		ok       bool
+		expected ExtractionProgress
+	}{
+		{"[progress] 42.5 1048576.0 12", true, ExtractionProgress{Percent: 42.5, Speed: 1048576, ETA: 12}},
+		{"[progress] 0.0 NA NA\r", true, ExtractionProgress{}},
+		{"[download] Destination: videos/abc.mp4", false, ExtractionProgress{}},
+		{"[progress] 42.5", false, ExtractionProgress{}},
+	}
+
+	for _, test := range tests {
+		progress, ok := parseProgress(test.line)
+		if ok != test.ok {
+			t.Errorf("%q: expected ok %t, got %t", test.line, test.ok, ok)
+			continue
+		}
+
+		if progress != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.line, test.expected, progress)
+		}
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	output := bytes.Buffer{}
+	progresses := []ExtractionProgress{}
+	w := &progressWriter{
+		videoID: "abc",
+		output:  &output,
+		onProgress: func(progress ExtractionProgress) {
+			progresses = append(progresses, progress)
+		},
+	}
+
+	// Lines may be split across writes
+	_, _ = w.Write([]byte("[download] Destination: abc.mp4\n[progress] 10.0 "))
+	_, _ = w.Write([]byte("500.0 20\n[progress] 100.0 NA NA\n"))
+
+	if output.String() != "[download] Destination: abc.mp4\n" {
+		t.Errorf("unexpected output %q", output.String())
+	}
+
+	if len(progresses) != 2 {
+		t.Fatalf("expected 2 progresses, got %d", len(progresses))
+	}
+
+	if progresses[0].VideoID != "abc" || progresses[0].Percent != 10 || progresses[0].ETA != 20 {
+		t.Errorf("unexpected progress %+v", progresses[0])
+	}
+
+	if progresses[1].Percent != 100 {
+		t.Errorf("unexpected progress %+v", progresses[1])
+	}
+}
@@ -0,0 +1,101 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/khaledhikmat/yt-extractor/service/lgr"
+)
+
+// shortsURL is the Youtube Shorts URL of a video ID
+var shortsURL = "https://www.youtube.com/shorts/%s"
+
+// probeTimeout bounds a Shorts URL probe so that a slow Youtube response does not hold up the refresh
+const probeTimeout = 10 * time.Second
+
+// probeClient does not follow the redirects so that the redirect of a regular video can be told apart
+var probeClient = &http.Client{
+	Timeout: probeTimeout,
+	CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// probeShort requests the video Shorts URL without following redirects.
+// Youtube serves Shorts at this URL and redirects regular videos to the watch URL.
+func probeShort(ctx context.Context, videoID string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, fmt.Sprintf(shortsURL, videoID), nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return false, nil
+	default:
+		return false, fmt.Errorf("probing short %s produced unexpected status code: %d", videoID, resp.StatusCode)
+	}
+}
+
+// isShortByHeuristic classifies a video as a Short if it is not longer than the configured maximum
+// duration and, if configured, if its yt-dlp metadata denotes a vertical video.
+// If the metadata cannot be retrieved, the video is classified by its duration only.
+func (svc *youtubService) isShortByHeuristic(ctx context.Context, videoID string, duration int64) (bool, error) {
+	if !svc.ConfigSvc.IsShortsAspectRatioCheck() {
+		return isShortByDimensions(duration, svc.ConfigSvc.GetShortsMaxDuration(), nil), nil
+	}
+
+	metadata, _, err := runYTDLPMetadata(ctx, fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID), svc.cookiesFile())
+	if err != nil {
+		lgr.Logger.Debug("isShortByHeuristic",
+			slog.String("event", "metadataFailed"),
+			slog.String("videoId", videoID),
+			slog.String("error", err.Error()),
+		)
+		return isShortByDimensions(duration, svc.ConfigSvc.GetShortsMaxDuration(), nil), nil
+	}
+
+	return isShortByDimensions(duration, svc.ConfigSvc.GetShortsMaxDuration(), &metadata), nil
+}
+
+// isShortByDimensions tells whether a video of the duration is a Short. The metadata,
+// if there is one, must also denote a vertical video.
+func isShortByDimensions(duration, maxDuration int64, metadata *YTDLPMetadata) bool {
+	if duration <= 0 || duration > maxDuration {
+		return false
+	}
+
+	if metadata == nil {
+		return true
+	}
+
+	return metadata.Height > metadata.Width
+}
+
+func (svc *youtubService) IsShort(ctx context.Context, videoID string, duration int64) (bool, error) {
+	if svc.ConfigSvc.GetShortsDetection() != "heuristic" {
+		short, err := probeShort(ctx, videoID)
+		if err == nil {
+			return short, nil
+		}
+
+		lgr.Logger.Debug("IsShort",
+			slog.String("event", "probeFailed"),
+			slog.String("videoId", videoID),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return svc.isShortByHeuristic(ctx, videoID, duration)
+}
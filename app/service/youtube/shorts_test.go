@@ -0,0 +1,65 @@
+package youtube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbeShort(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/shorts/short":
+			w.WriteHeader(http.StatusOK)
+		case "/shorts/regular":
+			http.Redirect(w, r, "/watch?v=regular", http.StatusSeeOther)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defer func(url string) { shortsURL = url }(shortsURL)
+	shortsURL = server.URL + "/shorts/%s"
+
+	tests := []struct {
+		videoID  string
+		expected bool
+		err      bool
+	}{
+		{"short", true, false},
+		{"regular", false, false},
+		{"missing", false, true},
+	}
+
+	for _, test := range tests {
+		short, err := probeShort(context.Background(), test.videoID)
+		if short != test.expected || (err != nil) != test.err {
+			t.Errorf("%s: expected %t (error: %t), got %t (%v)", test.videoID, test.expected, test.err, short, err)
+		}
+	}
+}
+
+func TestIsShortByDimensions(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration int64
+		metadata *YTDLPMetadata
+		expected bool
+	}{
+		{"unknown duration", 0, nil, false},
+		{"too long", 181, nil, false},
+		{"duration only", 180, nil, true},
+		{"vertical", 60, &YTDLPMetadata{Width: 1080, Height: 1920}, true},
+		{"horizontal", 60, &YTDLPMetadata{Width: 1920, Height: 1080}, false},
+		{"long vertical", 600, &YTDLPMetadata{Width: 1080, Height: 1920}, false},
+	}
+
+	for _, test := range tests {
+		short := isShortByDimensions(test.duration, 180, test.metadata)
+		if short != test.expected {
+			t.Errorf("%s: expected %t, got %t", test.name, test.expected, short)
+		}
+	}
+}
@@ -5,7 +5,12 @@ import "context"
 type IService interface {
 	PrintExtractorVersion() error
 	RetrieveVideos(channelID string, max int) ([]Video, error)
-	ExtractVideos(ctx context.Context, errorStream chan error, videoURLs []string) (map[string]string, error)
+	IsShort(ctx context.Context, videoID string, duration int64) (bool, error)
+	ExtractCaptions(ctx context.Context, videoID, videoURL string) ([]Caption, error)
+	ExtractVideo(ctx context.Context, jobID int64, videoURL string, mode ExtractionMode) ExtractionResult
+	RetrieveExtractionProgress(jobID int64) []ExtractionProgress
+	SetCookies(content []byte) error
+	ValidateCookies(ctx context.Context, content []byte) error
 
 	Finalize()
 }
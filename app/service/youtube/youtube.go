@@ -1,9 +1,10 @@
 package youtube
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,26 +12,31 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
-	"regexp"
 	"strings"
 
 	"github.com/khaledhikmat/yt-extractor/service"
 	"github.com/khaledhikmat/yt-extractor/service/config"
 	"github.com/khaledhikmat/yt-extractor/service/lgr"
-	"github.com/khaledhikmat/yt-extractor/utils"
 )
 
 const (
-	defaultCodecIDs = "137+140"
+	// yt-dlp format selector used if no format satisfies the policy
+	fallbackFormatSelector = "bv*+ba/b"
+	// yt-dlp format selector used in audio extraction mode
+	audioFormatSelector = "bestaudio"
 )
 
 type youtubService struct {
 	ConfigSvc config.IService
+	Limiter   *hostLimiter
+	Progress  *progressTracker
 }
 
 func New(cfgsvc config.IService) IService {
 	return &youtubService{
 		ConfigSvc: cfgsvc,
+		Limiter:   newHostLimiter(cfgsvc.GetExtractionHostRate()),
+		Progress:  newProgressTracker(),
 	}
 }
 
@@ -75,34 +81,74 @@ func (svc *youtubService) RetrieveVideos(channelID string, max int) ([]Video, er
 	return results, nil
 }
 
-func (svc *youtubService) ExtractVideos(ctx context.Context, errorStream chan error, videoURLs []string) (map[string]string, error) {
-	results := map[string]string{}
+// ExtractVideo extracts a single video URL (or only its audio stream in audio mode) once the host
+// limiter allows it and tracks its download progress under the job until it is done. The result
+// carries the local reference or the error along with its class and the availability of the video.
+func (svc *youtubService) ExtractVideo(ctx context.Context, jobID int64, videoURL string, mode ExtractionMode) ExtractionResult {
+	// The video progress is no longer needed once it is extracted
+	defer svc.Progress.remove(jobID, extractVideoID(videoURL))
 
-	for _, URL := range videoURLs {
-		// If the context is cancelled, exit the loop
-		select {
-		case <-ctx.Done():
-			return results, fmt.Errorf("context cancelled")
-		default:
+	// Throttle the requests made to the same host
+	err := svc.Limiter.wait(ctx, videoURL)
+	if err != nil {
+		return ExtractionResult{
+			URL:        videoURL,
+			ErrorClass: service.ErrorClassUnknown,
+			Err:        err,
 		}
+	}
 
-		lgr.Logger.Debug("Extracting video",
-			slog.String("URL", URL),
-		)
+	return svc.extractVideo(ctx, jobID, videoURL, mode)
+}
 
-		// Run yt-dlp to extract the video and save it to an output file
-		mp4File, err := runYTDLPExtractor(extractVideoID(URL), URL, svc.ConfigSvc.GetLocalVideosFolder(), svc.ConfigSvc.GetLocalCodecsFolder(), svc.ConfigSvc.IsProduction())
-		if err != nil {
-			errorStream <- fmt.Errorf("error extracting video %s: %v", URL, err)
-			// Indicate an error by mapping the video URL to not available extraction URL
-			results[URL] = service.InvalidURL
-			continue
-		}
+func (svc *youtubService) RetrieveExtractionProgress(jobID int64) []ExtractionProgress {
+	return svc.Progress.retrieve(jobID)
+}
 
-		results[URL] = mp4File
+func (svc *youtubService) extractVideo(ctx context.Context, jobID int64, URL string, mode ExtractionMode) ExtractionResult {
+	lgr.Logger.Debug("Extracting video",
+		slog.String("URL", URL),
+		slog.String("mode", string(mode)),
+	)
+
+	outputFolder := svc.ConfigSvc.GetLocalVideosFolder()
+	if mode == ExtractionModeAudio {
+		outputFolder = svc.ConfigSvc.GetLocalAudioFolder()
 	}
 
-	return results, nil
+	onProgress := func(progress ExtractionProgress) {
+		svc.Progress.update(jobID, progress)
+	}
+
+	// Run yt-dlp to extract the video and save it to an output file
+	// Errors are indicated by an empty local reference along with the error and its class
+	result, err := runYTDLPExtractor(ctx, extractVideoID(URL), URL, outputFolder, mode, svc.formatPolicy(), svc.cookiesFile(), onProgress)
+	if err != nil {
+		result.Err = err
+		result.ErrorClass = errorClassOf(err)
+	}
+
+	if err != nil && ctx.Err() == nil {
+		// The bot check blocks all extractions until the cookies are rotated so it must stand out
+		var botCheckErr *BotCheckError
+		if errors.As(err, &botCheckErr) {
+			lgr.Logger.Error("extractVideo",
+				slog.String("event", "botCheck"),
+				slog.String("URL", URL),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+
+	return result
+}
+
+func (svc *youtubService) formatPolicy() FormatPolicy {
+	return FormatPolicy{
+		MaxHeight:      svc.ConfigSvc.GetFormatMaxHeight(),
+		PreferredCodec: svc.ConfigSvc.GetFormatPreferredCodec(),
+		MaxFileSize:    svc.ConfigSvc.GetFormatMaxFileSize() * 1024 * 1024,
+	}
 }
 
 func (svc *youtubService) Finalize() {
@@ -195,11 +241,27 @@ func getVideosFromPlaylist(apiKey, playlistID string, maxVideos int) ([]Video, e
 		// Get video details
 		for _, video := range pageVideos {
 			video.ID = extractVideoID(video.URL)
+
+			// Youtube omits private and deleted videos from the statistics response
+			if _, ok := statistics[video.ID]; !ok {
+				video.Availability = missingAvailability(video.Title)
+				videos = append(videos, video)
+				continue
+			}
+
 			video.Views = statistics[extractVideoID(video.URL)].Views
 			video.Comments = statistics[extractVideoID(video.URL)].Comments
 			video.Likes = statistics[extractVideoID(video.URL)].Likes
 			video.Duration = statistics[extractVideoID(video.URL)].Duration
-			video.Short = statistics[extractVideoID(video.URL)].Short
+			video.Description = statistics[extractVideoID(video.URL)].Description
+			video.Tags = statistics[extractVideoID(video.URL)].Tags
+			video.Thumbnails = statistics[extractVideoID(video.URL)].Thumbnails
+			video.CategoryID = statistics[extractVideoID(video.URL)].CategoryID
+			video.DefaultAudioLanguage = statistics[extractVideoID(video.URL)].DefaultAudioLanguage
+			video.Caption = statistics[extractVideoID(video.URL)].Caption
+			video.LiveBroadcastContent = statistics[extractVideoID(video.URL)].LiveBroadcastContent
+			video.PrivacyStatus = statistics[extractVideoID(video.URL)].PrivacyStatus
+			video.Availability = statistics[extractVideoID(video.URL)].Availability
 
 			videos = append(videos, video)
 		}
@@ -225,7 +287,7 @@ func extractVideoID(url string) string {
 }
 
 func getVideoStatistics(apiKey string, videoIDs []string) (map[string]Video, error) {
-	apiURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/videos?part=contentDetails,statistics&id=%s&key=%s", url.QueryEscape(strings.Join(videoIDs, ",")), apiKey)
+	apiURL := fmt.Sprintf("https://www.googleapis.com/youtube/v3/videos?part=snippet,contentDetails,statistics,status&id=%s&key=%s", url.QueryEscape(strings.Join(videoIDs, ",")), apiKey)
 	//fmt.Printf("Statistics API URL: %s\n", apiURL)
 
 	resp, err := http.Get(apiURL)
@@ -247,193 +309,133 @@ func getVideoStatistics(apiKey string, videoIDs []string) (map[string]Video, err
 
 	stats := make(map[string]Video)
 	for _, item := range statsResponse.Items {
+		// Keep only the thumbnail URLs keyed by their size (i.e. default, medium, high, standard, maxres)
+		thumbnails := map[string]string{}
+		for size, thumbnail := range item.Snippet.Thumbnails {
+			thumbnails[size] = thumbnail.URL
+		}
+
 		stats[item.ID] = Video{
-			Views:    item.Statistics.ViewCount,
-			Comments: item.Statistics.CommentCount,
-			Likes:    item.Statistics.LikeCount,
-			Duration: item.ContentDetails.Duration,
-			Short:    utils.ExtractDurationInSecs(item.ContentDetails.Duration) < 100,
+			Views:                item.Statistics.ViewCount,
+			Comments:             item.Statistics.CommentCount,
+			Likes:                item.Statistics.LikeCount,
+			Duration:             item.ContentDetails.Duration,
+			Description:          item.Snippet.Description,
+			Tags:                 item.Snippet.Tags,
+			Thumbnails:           thumbnails,
+			CategoryID:           item.Snippet.CategoryID,
+			DefaultAudioLanguage: item.Snippet.DefaultAudioLanguage,
+			Caption:              item.ContentDetails.Caption == "true",
+			LiveBroadcastContent: item.Snippet.LiveBroadcastContent,
+			PrivacyStatus:        item.Status.PrivacyStatus,
+			Availability:         statisticsAvailability(item.Status.PrivacyStatus, item.ContentDetails.ContentRating.YtRating),
 		}
 	}
 
 	return stats, nil
 }
 
-func extractFirstID(line string) string {
-	// Define a regular expression to match the first number at the beginning of the line
-	re := regexp.MustCompile(`^\s*(\d+)\s+`)
-
-	// Find the first match
-	match := re.FindStringSubmatch(line)
-	if len(match) > 1 {
-		return match[1] // Return the captured group (the number)
-	}
-
-	return "" // Return an empty string if no match is found
-}
+// runYTDLPMetadata returns the availability of the video (classified from the yt-dlp error output)
+// if the metadata cannot be retrieved because the video can no longer be viewed
+func runYTDLPMetadata(ctx context.Context, videoURL, cookiesFile string) (YTDLPMetadata, service.Availability, error) {
+	var metadata YTDLPMetadata
 
-func scrapeCodecIDs(videoURL, codecsFolder string, isProd bool) (string, error) {
-	codecIDs := ""
+	args := append(cookiesArgs(cookiesFile), "-J", "--skip-download", videoURL)
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
 
-	// Generate codec file
-	codecFile := fmt.Sprintf("./%s/%s.txt", codecsFolder, extractVideoID(videoURL))
+	// Capture the error output so failures can be classified
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 
-	// Run yt-dlp to fetch available formats
-	err := runYTDLPWithOutput(videoURL, codecFile, isProd)
+	output, err := cmd.Output()
 	if err != nil {
-		return "", err
+		return metadata, classifyAvailability(stderr.String()), ytdlpError(videoURL, stderr.String(), err)
 	}
 
-	defer os.Remove(codecFile)
-
-	lgr.Logger.Debug("Produced codec file",
-		slog.String("file", codecFile),
-	)
-
-	// Process the codec file to produce codec IDs
-	codecIDs, err = processCodecFile(codecFile)
+	err = json.Unmarshal(output, &metadata)
 	if err != nil {
-		return "", err
+		return metadata, "", fmt.Errorf("error parsing yt-dlp metadata: %v", err)
 	}
 
-	return codecIDs, nil
+	return metadata, service.AvailabilityPublic, nil
 }
 
-func processCodecFile(filePath string) (string, error) {
-	// Open the file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", err
+// runYTDLPExtractor selects the formats to download from the yt-dlp metadata according to the policy.
+// In audio mode, only the best audio stream is downloaded and converted to mp3.
+// The result carries the availability of the video (classified from the yt-dlp error output)
+// if the extraction fails because the video can no longer be viewed.
+// The download progress is reported to onProgress as yt-dlp makes progress.
+func runYTDLPExtractor(ctx context.Context, videoID, videoURL, outputFolder string, mode ExtractionMode, policy FormatPolicy, cookiesFile string, onProgress func(ExtractionProgress)) (ExtractionResult, error) {
+	result := ExtractionResult{
+		URL: videoURL,
 	}
-	defer file.Close()
 
-	// Define slices to hold the matching lines
-	var audioLines []string
-	var videoLines []string
+	fmt.Printf("runYTDLPExtractor - cookies: %s - mode: %s - videoId: %s - videoURL: %s\n", cookiesFile, mode, videoID, videoURL)
 
-	// Regular expressions to match the desired conditions
-	//audioRegex := regexp.MustCompile(`\s+m4a\s+audio only\s+`)
-	//videoRegex := regexp.MustCompile(`\s+mp4\s+\S+\s+\d+p\s+.*video only.*https`)
-
-	// Read the file line by line
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Check for audio-only match
-		// if audioRegex.MatchString(line) {
-		// 	audioLines = append(audioLines, line)
-		// }
-
-		if strings.Contains(line, "m4a") &&
-			strings.Contains(line, "audio only") {
-			audioLines = append(audioLines, line)
+	var args []string
+	var outputFile string
+	if mode == ExtractionModeAudio {
+		// yt-dlp names the converted file after the audio format
+		outputFile = fmt.Sprintf("./%s/%s.mp3", outputFolder, videoID)
+		result.FormatIDs = audioFormatSelector
+		args = []string{"-f", audioFormatSelector, "-x", "--audio-format", "mp3", videoURL, "-o", fmt.Sprintf("./%s/%s.%%(ext)s", outputFolder, videoID)}
+	} else {
+		metadata, availability, err := runYTDLPMetadata(ctx, videoURL, cookiesFile)
+		if err != nil {
+			result.Availability = availability
+			return result, err
 		}
 
-		// Check for video-only match
-		// if videoRegex.MatchString(line) {
-		// 	videoLines = append(videoLines, line)
-		// }
-
-		if strings.Contains(line, "mp4") &&
-			strings.Contains(line, "https") &&
-			strings.Contains(line, "video only") {
-			videoLines = append(videoLines, line)
+		// Let yt-dlp pick the formats if none satisfies the policy
+		formatIDs, err := selectFormats(metadata.Formats, policy)
+		if err != nil {
+			lgr.Logger.Debug("runYTDLPExtractor",
+				slog.String("event", "formatSelectionFailed"),
+				slog.String("videoId", videoID),
+				slog.String("error", err.Error()),
+			)
+			formatIDs = fallbackFormatSelector
 		}
-	}
-
-	// Check for errors during scanning
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("Error reading file: %v", err)
-	}
-
-	return extractFirstID(videoLines[len(videoLines)-1]) + "+" + extractFirstID(audioLines[len(audioLines)-1]), nil
-}
+		result.FormatIDs = formatIDs
 
-func runYTDLPWithOutput(videoURL, outputFile string, isProd bool) error {
-	// Define video URL and output file name
-	// videoURL := "https://www.youtube.com/watch?v=wQlek65Hp2w"
-	// outputFile := "wQlek65Hp2w.txt"
+		lgr.Logger.Debug("runYTDLPExtractor",
+			slog.String("event", "formatsSelected"),
+			slog.String("videoId", videoID),
+			slog.String("formatIds", formatIDs),
+		)
 
-	// Create or open the output file
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+		outputFile = fmt.Sprintf("./%s/%s.mp4", outputFolder, videoID)
+		args = []string{"-f", formatIDs, "--merge-output-format", "mp4", videoURL, "-o", outputFile}
 	}
-	defer file.Close()
 
-	// Construct the command
-	var cmd *exec.Cmd
-	fmt.Printf("runYTDLPWithOutput - prod: %t - cookies: %s\n", isProd, "./cookies.txt")
-	if isProd {
-		cmd = exec.Command("yt-dlp", "--cookies", "./cookies.txt", "-F", videoURL)
-	} else {
-		cmd = exec.Command("yt-dlp", "-F", videoURL)
+	// Construct the extract command
+	// In production running in Docker, we must pass cookies to prevent triggering YouTube's anti-bot measures
+	// Documented in https://github.com/yt-dlp/yt-dlp/wiki/FAQ#how-do-i-pass-cookies-to-yt-dlp
+	// Spoofing the user agent (i.e. --user-agent) was tried but it is not enough
+	args = append(cookiesArgs(cookiesFile), args...)
+	args = append([]string{"--newline", "--progress-template", progressTemplate}, args...)
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+
+	// Capture the error output so failures can be classified
+	var stderr bytes.Buffer
+
+	// Set command output to the standard output (for debugging/logging) except for the progress lines
+	cmd.Stdout = &progressWriter{
+		videoID:    videoID,
+		output:     os.Stdout,
+		onProgress: onProgress,
 	}
-
-	// Redirect the command's output to the file
-	cmd.Stdout = file
-	cmd.Stderr = os.Stderr // Optional: log errors to stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
 
 	// Run the command
-	fmt.Println("Running yt-dlp to fetch available formats...")
-	err = cmd.Run()
-	if err != nil {
-		return fmt.Errorf("error executing yt-dlp: %v", err)
-	}
-
-	return nil
-}
-
-func runYTDLPExtractor(videoID, videoURL, videosFolder, codecsFolder string, isProd bool) (string, error) {
-	outputFile := fmt.Sprintf("./%s/%s.mp4", videosFolder, videoID)
-
-	// Two attempts to extract the video:
-	// 1. first with the default codec IDs,
-	// 2. with scraped codec IDs
-
-	scrapedCodecIDs, err := scrapeCodecIDs(videoURL, codecsFolder, isProd)
+	fmt.Println("Running yt-dlp to extract video...")
+	err := cmd.Run()
 	if err != nil {
-		scrapedCodecIDs = ""
-	}
-
-	fmt.Printf("runYTDLPExtractor - prod: %t - videoId: %s - videoURL: %s\n", isProd, videoID, videoURL)
-
-	codecIDsList := []string{defaultCodecIDs, scrapedCodecIDs}
-	for _, codecIDs := range codecIDsList {
-		if codecIDs == "" {
-			continue
-		}
-
-		// Construct the extract command using default codec IDs
-		var cmd *exec.Cmd
-		if isProd {
-			// In production running in Docker, we must spoof headers and user agent to prevent
-			// triggering YouTube's anti-bot measures
-			// Option1: Try using a realistic browser User-Agent to make requests look more like a human browsing.
-			// userAgent := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
-			// lgr.Logger.Debug("runYTDLPExtractor",
-			// 	slog.String("userAgent", userAgent),
-			// )
-			// cmd = exec.Command("yt-dlp", "--user-agent", userAgent, "-f", codecIDs, "--merge-output-format", "mp4", videoURL, "-o", outputFile)
-			// Option2: Documented in https://github.com/yt-dlp/yt-dlp/wiki/FAQ#how-do-i-pass-cookies-to-yt-dlp
-			fmt.Printf("runYTDLPExtractor - prod: %t - cookies: %s\n", isProd, "./cookies.txt")
-			cmd = exec.Command("yt-dlp", "--cookies", "./cookies.txt", "-f", codecIDs, "--merge-output-format", "mp4", videoURL, "-o", outputFile)
-		} else {
-			cmd = exec.Command("yt-dlp", "-f", codecIDs, "--merge-output-format", "mp4", videoURL, "-o", outputFile)
-		}
-
-		// Set command output to the standard output (for debugging/logging)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		// Run the command
-		fmt.Println("Running yt-dlp to extract video...")
-		err := cmd.Run()
-		if err == nil {
-			return outputFile, nil
-		}
+		result.Availability = classifyAvailability(stderr.String())
+		return result, ytdlpError(videoURL, stderr.String(), err)
 	}
 
-	return "", fmt.Errorf("error executing yt-dlp: %v", err)
+	result.LocalReference = outputFile
+	result.Availability = service.AvailabilityPublic
+	return result, nil
 }
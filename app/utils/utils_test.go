@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	plain := []byte("# Netscape HTTP Cookie File")
+	cipherText, err := Encrypt("secret", plain)
+	if err != nil {
+		t.Fatalf("encrypt produced %v", err)
+	}
+
+	if bytes.Contains(cipherText, plain) {
+		t.Errorf("expected the cipher text not to contain the plain text")
+	}
+
+	decrypted, err := Decrypt("secret", cipherText)
+	if err != nil {
+		t.Fatalf("decrypt produced %v", err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Errorf("expected %q, got %q", plain, decrypted)
+	}
+
+	// Every encryption has its own nonce
+	again, err := Encrypt("secret", plain)
+	if err != nil || bytes.Equal(again, cipherText) {
+		t.Errorf("expected a different cipher text (%v)", err)
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	cipherText, err := Encrypt("secret", []byte("plain"))
+	if err != nil {
+		t.Fatalf("encrypt produced %v", err)
+	}
+
+	_, err = Decrypt("wrong", cipherText)
+	if err == nil {
+		t.Errorf("expected the wrong secret to fail")
+	}
+
+	tampered := bytes.Clone(cipherText)
+	tampered[len(tampered)-1] ^= 1
+	_, err = Decrypt("secret", tampered)
+	if err == nil {
+		t.Errorf("expected the tampered cipher text to fail")
+	}
+
+	_, err = Decrypt("secret", []byte("short"))
+	if err == nil {
+		t.Errorf("expected the short cipher text to fail")
+	}
+
+	_, err = Encrypt("", []byte("plain"))
+	if err == nil {
+		t.Errorf("expected the empty secret to fail")
+	}
+}
@@ -10,12 +10,12 @@ SELECT * from videos where published_at >= '2025-01-01 00:00:00' AND audio_url i
 select count(*) from jobs where state = 'completed';
 select * from videos order by published_at desc limit 50;
 select * from videos where video_url IN ('https://www.youtube.com/watch?v=aUSJG8AI05g', 'https://www.youtube.com/watch?v=J2f8LUZFcD8');
-select * from videos where extraction_url = 'https://www.isitdownrightnow.com';
+select * from videos where extraction_status = 'failed';
 SELECT * from videos where published_at >= '2025-01-01 00:00:00' ORDER BY published_at DESC;
 SELECT title, extraction_url, extracted_at, audio_url, audioed_at, transcription_url, transcribed_at from videos WHERE id = 42;
 SELECT * from videos where externalized_at is null;
 select * from videos where id = 43;
--- update videos SET extraction_url = 'https://www.isitdownrightnow.com' WHERE id = 1219; 
+-- update videos SET extraction_url = null, extraction_status = 'failed' WHERE id = 1219; 
 -- update videos SET processed_at = null, externalized_at = null;
 select * from jobs where state = 'running';
 --delete from jobs where state = 'running';
@@ -35,13 +35,15 @@ select * from videos where id = 42;
 UPDATE videos 
 SET 
 audioed_at = null, 
-audio_url = null
+audio_url = null, 
+audio_status = 'pending'
 WHERE ID = 42;
 
 UPDATE videos 
 SET 
 audioed_at = null, 
 audio_url = null, 
+audio_status = 'pending', 
 published_at = '2025-02-01 00:00:00' 
 WHERE ID = xxx;
 
@@ -54,9 +56,8 @@ WHERE ID = xxx;
 SELECT * FROM videos 
 WHERE channel_id = 'UCP-PfkMcOKriSxFMH7pTxfA' 
 AND externalized_at is not null 
-AND extracted_at is not null 
-AND extraction_url != 'https://www.isitdownrightnow.com' 
-AND audioed_at is null 
+AND extraction_status = 'succeeded' 
+AND audio_status = 'pending' 
 AND published_at >= '2025-01-01 00:00:00'
 ORDER BY published_at DESC 
 LIMIT 10
@@ -65,12 +66,9 @@ LIMIT 10
 SELECT * FROM videos 
 WHERE channel_id = 'UCP-PfkMcOKriSxFMH7pTxfA' 
 AND externalized_at is not null 
-AND extracted_at is not null 
-AND extraction_url != 'https://www.isitdownrightnow.com'  
-AND audioed_at is not null 
-AND audio_url != 'https://www.isitdownrightnow.com' 
-AND audio_url != 'https://httpstatuses.com/202' 
-AND transcribed_at is null 
+AND extraction_status = 'succeeded' 
+AND audio_status = 'succeeded' 
+AND transcription_status = 'pending' 
 AND published_at >= '2025-01-01 00:00:00'
 ORDER BY published_at DESC 
 LIMIT 10